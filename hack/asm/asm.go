@@ -0,0 +1,333 @@
+// Package asm translates the Hack assembly language to machine code and
+// back. It is the assembler of project 6 as a library, with the same
+// symbol table: the predefined symbols, then the labels, and the
+// variables from address 16 on in the order of their first use.
+package asm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"hack"
+)
+
+// Program is an assembled program.
+type Program struct {
+	Code    []uint16
+	Lines   []int          // source line of each instruction, starting at 1
+	Symbols map[string]int // predefined symbols, labels and variables
+	Labels  map[string]int // ROM address of each label
+}
+
+// Predefined returns the predefined symbols of the Hack assembly language.
+func Predefined() map[string]int {
+	symbols := map[string]int{
+		"SP":     0,
+		"LCL":    1,
+		"ARG":    2,
+		"THIS":   3,
+		"THAT":   4,
+		"SCREEN": 16384,
+		"KBD":    24576,
+	}
+	for i := 0; i < 16; i++ {
+		symbols["R"+strconv.Itoa(i)] = i
+	}
+
+	return symbols
+}
+
+// Assemble translates src. Errors give the line of the instruction.
+func Assemble(src []byte) (*Program, error) {
+	p := &Program{Symbols: Predefined(), Labels: make(map[string]int)}
+
+	// first pass: the labels
+	type instruction struct {
+		text string
+		line int
+	}
+	var instructions []instruction
+	for i, line := range strings.Split(string(src), "\n") {
+		if j := strings.Index(line, "//"); j >= 0 {
+			line = line[:j]
+		}
+		line = strings.Join(strings.Fields(line), "")
+		switch {
+		case line == "":
+		case line[0] == '(':
+			label := strings.TrimSuffix(line[1:], ")")
+			if !strings.HasSuffix(line, ")") || !isSymbol(label) {
+				return nil, fmt.Errorf("line %d: invalid label %v", i+1, line)
+			}
+			if _, ok := p.Symbols[label]; ok {
+				return nil, fmt.Errorf("line %d: symbol %v is already defined", i+1, label)
+			}
+			p.Symbols[label] = len(instructions)
+			p.Labels[label] = len(instructions)
+		default:
+			if len(instructions) == hack.ROMSize {
+				return nil, fmt.Errorf("line %d: program longer than the %d words of the ROM", i+1, hack.ROMSize)
+			}
+			instructions = append(instructions, instruction{line, i + 1})
+		}
+	}
+
+	// second pass: the variables and the code
+	next := 16
+	for _, inst := range instructions {
+		word, err := p.encode(inst.text, &next)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", inst.line, err)
+		}
+		p.Code = append(p.Code, word)
+		p.Lines = append(p.Lines, inst.line)
+	}
+
+	return p, nil
+}
+
+func (p *Program) encode(inst string, next *int) (uint16, error) {
+	if inst[0] == '@' {
+		value := inst[1:]
+		if n, err := strconv.Atoi(value); err == nil {
+			if n < 0 || n > 1<<15-1 {
+				return 0, fmt.Errorf("constant %v out of range", value)
+			}
+			return uint16(n), nil
+		}
+		if !isSymbol(value) {
+			return 0, fmt.Errorf("invalid symbol %v", value)
+		}
+		n, ok := p.Symbols[value]
+		if !ok {
+			n = *next
+			p.Symbols[value] = n
+			*next++
+		}
+		return uint16(n), nil
+	}
+
+	dest, comp, jump := "", inst, ""
+	if i := strings.Index(comp, "="); i >= 0 {
+		dest, comp = comp[:i], comp[i+1:]
+	}
+	if i := strings.Index(comp, ";"); i >= 0 {
+		comp, jump = comp[:i], comp[i+1:]
+	}
+	c, ok := comps[comp]
+	if !ok {
+		return 0, fmt.Errorf("invalid computation %q", comp)
+	}
+	d, ok := dests[dest]
+	if !ok {
+		return 0, fmt.Errorf("invalid destination %q", dest)
+	}
+	j, ok := jumps[jump]
+	if !ok {
+		return 0, fmt.Errorf("invalid jump %q", jump)
+	}
+
+	return 0b111<<13 | c<<6 | d<<3 | j, nil
+}
+
+// isSymbol reports whether s is a symbol: letters, digits, _, ., $ and :,
+// not starting with a digit.
+func isSymbol(s string) bool {
+	for i, ch := range s {
+		switch {
+		case 'a' <= ch && ch <= 'z', 'A' <= ch && ch <= 'Z', strings.ContainsRune("_.$:", ch):
+		case '0' <= ch && ch <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return s != ""
+}
+
+// comps are the computations with the a bit, the fourth bit from the left.
+var comps = map[string]uint16{
+	"0":   0b0101010,
+	"1":   0b0111111,
+	"-1":  0b0111010,
+	"D":   0b0001100,
+	"A":   0b0110000,
+	"!D":  0b0001101,
+	"!A":  0b0110001,
+	"-D":  0b0001111,
+	"-A":  0b0110011,
+	"D+1": 0b0011111,
+	"A+1": 0b0110111,
+	"D-1": 0b0001110,
+	"A-1": 0b0110010,
+	"D+A": 0b0000010,
+	"D-A": 0b0010011,
+	"A-D": 0b0000111,
+	"D&A": 0b0000000,
+	"D|A": 0b0010101,
+	"M":   0b1110000,
+	"!M":  0b1110001,
+	"-M":  0b1110011,
+	"M+1": 0b1110111,
+	"M-1": 0b1110010,
+	"D+M": 0b1000010,
+	"D-M": 0b1010011,
+	"M-D": 0b1000111,
+	"D&M": 0b1000000,
+	"D|M": 0b1010101,
+}
+
+var dests = map[string]uint16{
+	"":    0b000,
+	"M":   0b001,
+	"D":   0b010,
+	"MD":  0b011,
+	"DM":  0b011,
+	"A":   0b100,
+	"AM":  0b101,
+	"MA":  0b101,
+	"AD":  0b110,
+	"DA":  0b110,
+	"ADM": 0b111,
+	"AMD": 0b111,
+}
+
+var jumps = map[string]uint16{
+	"":    0b000,
+	"JGT": 0b001,
+	"JEQ": 0b010,
+	"JGE": 0b011,
+	"JLT": 0b100,
+	"JNE": 0b101,
+	"JLE": 0b110,
+	"JMP": 0b111,
+}
+
+var (
+	compNames = make(map[uint16]string)
+	destNames = [...]string{"", "M", "D", "MD", "A", "AM", "AD", "AMD"}
+	jumpNames = [...]string{"", "JGT", "JEQ", "JGE", "JLT", "JNE", "JLE", "JMP"}
+)
+
+func init() {
+	for name, c := range comps {
+		compNames[c] = name
+	}
+}
+
+// Disassemble returns the instruction of a word of machine code. A C
+// instruction with a computation that has no mnemonic is shown in binary.
+func Disassemble(word uint16) string {
+	if word&(1<<15) == 0 {
+		return "@" + strconv.Itoa(int(word))
+	}
+
+	comp, ok := compNames[word>>6&0b1111111]
+	if !ok {
+		return fmt.Sprintf("%016b", word)
+	}
+	inst := comp
+	if dest := destNames[word>>3&0b111]; dest != "" {
+		inst = dest + "=" + inst
+	}
+	if jump := jumpNames[word&0b111]; jump != "" {
+		inst += ";" + jump
+	}
+
+	return inst
+}
+
+// ParseHack reads machine code in the text format of .hack files: one
+// instruction per line, as 16 binary digits.
+func ParseHack(src []byte) ([]uint16, error) {
+	var code []uint16
+	for i, line := range strings.Split(string(src), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		word, err := strconv.ParseUint(line, 2, 16)
+		if err != nil || len(line) != 16 {
+			return nil, fmt.Errorf("line %d: invalid instruction %q", i+1, line)
+		}
+		if len(code) == hack.ROMSize {
+			return nil, fmt.Errorf("line %d: program longer than the %d words of the ROM", i+1, hack.ROMSize)
+		}
+		code = append(code, uint16(word))
+	}
+
+	return code, nil
+}
+
+// FormatHack returns code in the text format of .hack files.
+func FormatHack(code []uint16) []byte {
+	var b strings.Builder
+	for _, word := range code {
+		fmt.Fprintf(&b, "%016b\n", word)
+	}
+
+	return []byte(b.String())
+}
+
+const (
+	dEqualsA = 0b1110110000010000 // D=A
+	jmp      = 0b1110101010000111 // 0;JMP
+)
+
+// Calls returns the calls of VM functions in code, as the address where
+// each starts and its return address. The VM translator calls a function
+// with
+//
+//	@RETURN_ADDRESS
+//	D=A
+//	... push the frame
+//	@function
+//	0;JMP
+//	(RETURN_ADDRESS)
+//
+// with no other jump before its own, which tells it from the address of a
+// function given in D to a routine of calls.
+func Calls(code []uint16) map[uint16]uint16 {
+	calls := make(map[uint16]uint16)
+	for i := 0; i+1 < len(code); i++ {
+		ret := int(code[i])
+		if code[i]&(1<<15) != 0 || code[i+1] != dEqualsA || ret <= i+1 || ret >= len(code) || code[ret-1] != jmp {
+			continue
+		}
+		j := i + 2
+		for j < ret-1 && code[j] != jmp {
+			j++
+		}
+		if j == ret-1 {
+			calls[uint16(i)] = uint16(ret)
+		}
+	}
+
+	return calls
+}
+
+// Functions returns the VM functions that p was translated from, by the
+// address where each starts. A label of the VM translator names a function,
+// as Main.main, rather than a label in a function, as Main.main$LOOP, or
+// one of its own, as RETURN_ADDR_Main.main_0. Of labels at the same
+// address, the first in order is kept.
+func (p *Program) Functions() map[int]string {
+	functions := make(map[int]string)
+	for label, addr := range p.Labels {
+		if old, ok := functions[addr]; isFunction(label) && (!ok || label < old) {
+			functions[addr] = label
+		}
+	}
+
+	return functions
+}
+
+func isFunction(label string) bool {
+	if !strings.Contains(label, ".") || strings.Contains(label, "$") {
+		return false
+	}
+	// an upper case prefix, such as RETURN_ADDR_ or LOOP_
+	prefix, _, ok := strings.Cut(label, "_")
+	return !ok || strings.ToUpper(prefix) != prefix
+}
@@ -0,0 +1,103 @@
+package asm_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"hack/asm"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssemble(t *testing.T) {
+	p, err := asm.Assemble([]byte("@2 // test\nD=A\n@3\nD=D+A\n@0\nM=D\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "0000000000000010\n1110110000010000\n0000000000000011\n1110000010010000\n0000000000000000\n1110001100001000\n",
+		string(asm.FormatHack(p.Code)))
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6}, p.Lines)
+}
+
+// TestAssemble_Symbols assembles the programs of project 6 with symbols
+// and without them, the L versions, which must give the same code.
+func TestAssemble_Symbols(t *testing.T) {
+	for _, name := range []string{"Max", "Rect", "Pong"} {
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile("../../project6/tests/" + name + ".asm")
+			assert.NoError(t, err)
+			p, err := asm.Assemble(src)
+			assert.NoError(t, err)
+			src, err = os.ReadFile("../../project6/tests/" + name + "L.asm")
+			assert.NoError(t, err)
+			want, err := asm.Assemble(src)
+			assert.NoError(t, err)
+			assert.Equal(t, want.Code, p.Code)
+		})
+	}
+
+	src, err := os.ReadFile("../../project6/tests/Rect.asm")
+	assert.NoError(t, err)
+	p, err := asm.Assemble(src)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"LOOP": 10, "END": 23}, p.Labels)
+	assert.Equal(t, 16, p.Symbols["n"])
+	assert.Equal(t, 17, p.Symbols["addr"])
+}
+
+func TestAssemble_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"1. invalid computation", "@1\nD=D*A", `line 2: invalid computation "D*A"`},
+		{"2. invalid destination", "X=1", `line 1: invalid destination "X"`},
+		{"3. invalid jump", "0;JUMP", `line 1: invalid jump "JUMP"`},
+		{"4. constant out of range", "@32768", "line 1: constant 32768 out of range"},
+		{"5. invalid symbol", "@1x", "line 1: invalid symbol 1x"},
+		{"6. label defined twice", "(L)\n@L\n(L)", "line 3: symbol L is already defined"},
+		{"7. predefined label", "(SP)", "line 1: symbol SP is already defined"},
+		{"8. program too long", "(L)\n" + strings.Repeat("D=0\n", 32769), "line 32770: program longer than the 32768 words of the ROM"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := asm.Assemble([]byte(tt.src))
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+// TestDisassemble disassembles Pong and assembles it again.
+func TestDisassemble(t *testing.T) {
+	src, err := os.ReadFile("../../project6/tests/Pong.asm")
+	assert.NoError(t, err)
+	p, err := asm.Assemble(src)
+	assert.NoError(t, err)
+
+	var out []byte
+	for _, word := range p.Code {
+		out = append(out, asm.Disassemble(word)+"\n"...)
+	}
+	again, err := asm.Assemble(out)
+	assert.NoError(t, err)
+	assert.Equal(t, p.Code, again.Code)
+
+	assert.Equal(t, "AM=M-1", asm.Disassemble(0b1111110010101000))
+	assert.Equal(t, "0;JMP", asm.Disassemble(0b1110101010000111))
+	assert.Equal(t, "1111111111111111", asm.Disassemble(0xffff))
+}
+
+func TestParseHack(t *testing.T) {
+	code, err := asm.ParseHack([]byte("0000000000000010\n\n1110110000010000\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{2, 0b1110110000010000}, code)
+
+	_, err = asm.ParseHack([]byte("0000000000000010\n111011000001000\n"))
+	assert.EqualError(t, err, `line 2: invalid instruction "111011000001000"`)
+
+	code, err = asm.ParseHack([]byte(strings.Repeat("1110101010000111\n", 32768)))
+	assert.NoError(t, err)
+	assert.Len(t, code, 32768)
+	_, err = asm.ParseHack([]byte(strings.Repeat("1110101010000111\n", 32769)))
+	assert.EqualError(t, err, "line 32769: program longer than the 32768 words of the ROM")
+}
@@ -0,0 +1,167 @@
+// Package check runs the emulators of package hack/cpu and hack/vmemu with
+// their accesses to the RAM checked against its layout by the VM
+// translator, and stops at the first that breaks it:
+//
+//   - the stack grows past 2047, into the heap, or shrinks below 256;
+//   - a write to the keyboard, or to the RAM past it, which is not mapped;
+//   - a read of a word that was never written, other than of the screen
+//     and the keyboard;
+//   - an access to the heap outside the blocks allocated by Memory.alloc
+//     and not yet freed by Memory.deAlloc, other than by the functions of
+//     Memory, if the program has a Memory.alloc function.
+//
+// The words that are not 0 when the check starts count as written, as do
+// the pointers of the VM segments, and the stack of the machine. Machine
+// code is checked for the stack only if it was translated from VM code,
+// with the labels of its functions.
+package check
+
+import (
+	"fmt"
+	"strings"
+
+	"hack"
+)
+
+// memory checks the accesses to the RAM.
+type memory struct {
+	ram     *[hack.RAMSize]int16
+	written [hack.RAMSize]bool
+	stack   bool                          // the RAM is laid out by the VM translator
+	heap    bool                          // the program allocates with Memory.alloc
+	os      bool                          // a function of Memory runs
+	blocks  map[int]int                   // size of each allocated block, by address
+	used    [hack.Screen - hack.Heap]bool // words of the heap in blocks
+	allocs  []alloc                       // calls of Memory.alloc that have not returned
+}
+
+// alloc is a call of Memory.alloc.
+type alloc struct {
+	ret  int // return address
+	arg  int // address of the argument, where the block is returned
+	size int
+}
+
+func newMemory(ram *[hack.RAMSize]int16, stack, heap bool) *memory {
+	m := &memory{ram: ram, stack: stack, heap: heap, blocks: make(map[int]int)}
+	for addr, word := range ram {
+		m.written[addr] = word != 0 || stack && addr <= hack.THAT
+	}
+
+	return m
+}
+
+// isMemory reports whether a function is one of Memory, as Memory.alloc
+// or memory.alloc.
+func isMemory(function string) bool {
+	return len(function) > 7 && strings.EqualFold(function[:7], "Memory.")
+}
+
+func (m *memory) read(addr int) error {
+	switch {
+	case addr < 0 || addr >= hack.RAMSize:
+		// the emulator fails, or wraps the address
+		return nil
+	case addr > hack.KBD:
+		return fmt.Errorf("read of RAM[%d], past the keyboard", addr)
+	case addr >= hack.Screen:
+		return nil
+	case !m.written[addr]:
+		return fmt.Errorf("read of RAM[%d], never written", addr)
+	}
+
+	return m.inHeap("read of", addr)
+}
+
+// write checks a write of v to addr, and counts the word as written.
+func (m *memory) write(addr int, v int16) error {
+	switch {
+	case addr < 0 || addr >= hack.RAMSize:
+		return nil
+	case addr == hack.KBD:
+		return fmt.Errorf("write to the keyboard, RAM[%d]", addr)
+	case addr > hack.KBD:
+		return fmt.Errorf("write to RAM[%d], past the keyboard", addr)
+	case addr == hack.SP && m.stack:
+		if v > hack.Heap {
+			return fmt.Errorf("stack overflow, SP %d", v)
+		}
+		if v < hack.Stack {
+			return fmt.Errorf("stack underflow, SP %d", v)
+		}
+	}
+	if err := m.inHeap("write to", addr); err != nil {
+		return err
+	}
+	m.written[addr] = true
+
+	return nil
+}
+
+// push checks a write of the stack at addr, which grows it past addr.
+func (m *memory) push(addr int) error {
+	if addr >= hack.Heap {
+		return fmt.Errorf("stack overflow, SP %d", addr+1)
+	}
+
+	return m.write(addr, 0)
+}
+
+// pop checks a read of the stack at addr, which shrinks it to addr.
+func (m *memory) pop(addr int) error {
+	if addr < hack.Stack {
+		return fmt.Errorf("stack underflow, SP %d", addr)
+	}
+
+	return m.read(addr)
+}
+
+func (m *memory) inHeap(access string, addr int) error {
+	if !m.heap || m.os || addr < hack.Heap || addr >= hack.Screen || m.used[addr-hack.Heap] {
+		return nil
+	}
+
+	return fmt.Errorf("%v RAM[%d] in the heap, outside the allocated blocks", access, addr)
+}
+
+// alloc records a call of Memory.alloc, which returns to ret with the
+// argument at arg.
+func (m *memory) alloc(ret, arg int) {
+	if arg >= 0 && arg < hack.RAMSize {
+		m.allocs = append(m.allocs, alloc{ret, arg, int(m.ram[arg])})
+	}
+}
+
+// returned records the block of the call of Memory.alloc that returns to
+// pc, if any.
+func (m *memory) returned(pc int) {
+	n := len(m.allocs)
+	if n == 0 {
+		return
+	}
+	a := m.allocs[n-1]
+	if pc != a.ret || int(m.ram[hack.SP]) != a.arg+1 {
+		return
+	}
+	m.allocs = m.allocs[:n-1]
+	addr := int(m.ram[a.arg])
+	if addr < hack.Heap || a.size <= 0 || addr+a.size > hack.Screen {
+		return
+	}
+	m.blocks[addr] = a.size
+	for i := addr; i < addr+a.size; i++ {
+		m.used[i-hack.Heap] = true
+	}
+}
+
+// free records the block at addr as freed by Memory.deAlloc.
+func (m *memory) free(addr int) {
+	size, ok := m.blocks[addr]
+	if !ok {
+		return
+	}
+	delete(m.blocks, addr)
+	for i := addr; i < addr+size; i++ {
+		m.used[i-hack.Heap] = false
+	}
+}
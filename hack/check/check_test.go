@@ -0,0 +1,173 @@
+package check_test
+
+import (
+	"os"
+	"testing"
+
+	"hack"
+	"hack/asm"
+	"hack/check"
+	"hack/cpu"
+	"hack/vmemu"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memory allocates blocks from 2048 on, and frees none.
+const memory = `
+	function Memory.init 0
+	push constant 0
+	pop static 0
+	push constant 0
+	return
+	function Memory.alloc 0
+	push static 0
+	push constant 2048
+	add
+	push static 0
+	push argument 0
+	add
+	pop static 0
+	return
+	function Memory.deAlloc 0
+	push constant 0
+	return`
+
+func TestVM_Step(t *testing.T) {
+	tests := []struct {
+		name string
+		main string // Main.main, with Memory
+		want string
+	}{
+		{"1. blocks", `
+			push constant 2
+			call Memory.alloc 1
+			pop pointer 1
+			push constant 7
+			pop that 1
+			push that 1
+			return`, ""},
+		{"2. stack overflow", `
+			call Main.main 0
+			return`, "Main.vm:2 (Main.main), step 722: stack overflow, SP 2049"},
+		{"3. keyboard", `
+			push constant 24576
+			pop pointer 1
+			push constant 1
+			pop that 0`, "Main.vm:5 (Main.main), step 13: write to the keyboard, RAM[24576]"},
+		{"4. never written", `
+			push static 3
+			return`, "Main.vm:2 (Main.main), step 10: read of RAM[19], never written"},
+		{"5. out of the block", `
+			push constant 2
+			call Memory.alloc 1
+			pop pointer 1
+			push constant 7
+			pop that 2`, "Main.vm:6 (Main.main), step 23: write to RAM[2050] in the heap, outside the allocated blocks"},
+		{"6. freed block", `
+			push constant 2
+			call Memory.alloc 1
+			pop pointer 1
+			push constant 7
+			pop that 0
+			push pointer 1
+			call Memory.deAlloc 1
+			pop temp 0
+			push that 0`, "Main.vm:10 (Main.main), step 30: read of RAM[2048] in the heap, outside the allocated blocks"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := vmemu.Load(
+				vmemu.File{Name: "Sys", Src: []byte("function Sys.init 0\ncall Memory.init 0\npop temp 0\ncall Main.main 0\nreturn")},
+				vmemu.File{Name: "Main", Src: []byte("function Main.main 0" + tt.main)},
+				vmemu.File{Name: "Memory", Src: []byte(memory)},
+			)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			m := vmemu.New(p)
+			ch := check.NewVM(m)
+			for !m.Halted() && err == nil {
+				err = ch.Step()
+			}
+			if tt.want == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.want)
+			}
+		})
+	}
+
+	// without functions, the stack is that of the first command
+	p, err := vmemu.Load(vmemu.File{Name: "Main", Src: []byte("push constant 1\npop temp 0\npop temp 0")})
+	assert.NoError(t, err)
+	m := vmemu.New(p)
+	ch := check.NewVM(m)
+	assert.NoError(t, ch.Step())
+	assert.NoError(t, ch.Step())
+	assert.EqualError(t, ch.Step(), "Main.vm:3, step 2: stack underflow, SP 255")
+}
+
+func TestCPU_Step(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		ram  map[int]int16
+		want string
+	}{
+		{"1. keyboard", "@KBD\nD=M\nM=D", nil, "line 3, cycle 2: write to the keyboard, RAM[24576]"},
+		{"2. past the keyboard", "@24577\nD=M", nil, "line 2, cycle 1: read of RAM[24577], past the keyboard"},
+		{"3. never written", "@R0\nD=M\n@R1\nD=D+M", map[int]int16{0: 5}, "line 4, cycle 3: read of RAM[1], never written"},
+		{"4. screen", "@SCREEN\nD=M\nM=!D", nil, ""},
+		{"5. stack overflow", "(Main.main)\n@2049\nD=A\n@SP\nM=D", nil, "line 5 (Main.main), cycle 3: stack overflow, SP 2049"},
+		{"6. stack underflow", "(Main.main)\n@SP\nM=M-1", nil, "line 3 (Main.main), cycle 1: stack underflow, SP -1"},
+		{"7. not VM code", "@SP\nM=M-1", map[int]int16{0: 5}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := asm.Assemble([]byte(tt.src))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			c := cpu.New(p.Code)
+			for addr, v := range tt.ram {
+				c.RAM[addr] = v
+			}
+			ch := check.NewCPU(c, p)
+			for !c.Halted() && err == nil {
+				err = ch.Step()
+			}
+			if tt.want == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCPU_Pong(t *testing.T) {
+	// Pong allocates its objects, and frees some
+	src, err := os.ReadFile("../../project6/tests/Pong.asm")
+	assert.NoError(t, err)
+	p, err := asm.Assemble(src)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	c := cpu.New(p.Code)
+	ch := check.NewCPU(c, p)
+	for i := 0; i < 2_000_000; i++ {
+		if !assert.NoError(t, ch.Step()) {
+			return
+		}
+	}
+
+	// a method of the ball, on the header of a block: at its entry,
+	// argument 0 is this
+	c.PC = uint16(p.Labels["ball.getleft"])
+	c.RAM[c.RAM[hack.ARG]] = 2049
+	for i := 0; i < 100 && err == nil; i++ {
+		err = ch.Step()
+	}
+	assert.ErrorContains(t, err, "(ball.getleft), cycle 2000014: read of RAM[2049] in the heap, outside the allocated blocks")
+}
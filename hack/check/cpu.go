@@ -0,0 +1,105 @@
+package check
+
+import (
+	"fmt"
+	"strings"
+
+	"hack"
+	"hack/asm"
+	"hack/cpu"
+)
+
+// CPU checks a computer.
+type CPU struct {
+	c         *cpu.Computer
+	p         *asm.Program
+	mem       *memory
+	functions []string // function of each address, "" if none
+	alloc     int      // address of Memory.alloc, or -1
+	deAlloc   int      // address of Memory.deAlloc, or -1
+}
+
+// NewCPU returns a checker of c. p is the assembled source of the program
+// of c, or nil. Without it, only the keyboard and the words never written
+// are checked.
+func NewCPU(c *cpu.Computer, p *asm.Program) *CPU {
+	ch := &CPU{c: c, p: p, functions: make([]string, c.Size), alloc: -1, deAlloc: -1}
+	var functions map[int]string
+	if p != nil {
+		functions = p.Functions()
+	}
+	for addr, name := range functions {
+		switch {
+		case strings.EqualFold(name, "Memory.alloc"):
+			ch.alloc = addr
+		case strings.EqualFold(name, "Memory.deAlloc"):
+			ch.deAlloc = addr
+		}
+	}
+	function := ""
+	for addr := range ch.functions {
+		if name, ok := functions[addr]; ok {
+			function = name
+		}
+		ch.functions[addr] = function
+	}
+	ch.mem = newMemory(&c.RAM, len(functions) > 0, ch.alloc >= 0)
+
+	return ch
+}
+
+// Step runs the next instruction of the computer if its accesses are
+// allowed, and fails otherwise.
+func (ch *CPU) Step() error {
+	c := ch.c
+	pc := int(c.PC)
+	if pc >= c.Size {
+		c.Step()
+		return nil
+	}
+
+	mem := ch.mem
+	mem.returned(pc)
+	switch pc {
+	case ch.alloc:
+		// the return address is in the frame, below the locals
+		mem.alloc(int(c.RAM[(int(c.RAM[hack.LCL])-5)&(hack.RAMSize-1)]), int(c.RAM[hack.ARG]))
+	case ch.deAlloc:
+		mem.free(int(c.RAM[int(uint16(c.RAM[hack.ARG]))&(hack.RAMSize-1)]))
+	}
+	mem.os = isMemory(ch.functions[pc])
+
+	inst := c.ROM[pc]
+	if inst&(1<<15) != 0 {
+		addr := int(uint16(c.A) & (hack.RAMSize - 1))
+		var err error
+		if inst&(1<<12) != 0 {
+			err = mem.read(addr)
+		}
+		if err == nil && inst&(1<<3) != 0 {
+			y := c.A
+			if inst&(1<<12) != 0 {
+				y = c.RAM[addr]
+			}
+			err = mem.write(addr, cpu.ALU(c.D, y, inst>>6))
+		}
+		if err != nil {
+			return ch.error(pc, err)
+		}
+	}
+	c.Step()
+
+	return nil
+}
+
+func (ch *CPU) error(pc int, err error) error {
+	where := fmt.Sprintf("ROM[%d]", pc)
+	if ch.p != nil && pc < len(ch.p.Lines) {
+		where = fmt.Sprintf("line %d", ch.p.Lines[pc])
+	}
+	if function := ch.functions[pc]; function != "" {
+		where += " (" + function + ")"
+	}
+
+	return fmt.Errorf("%v, cycle %d: %v", where, ch.c.Cycles, err)
+}
@@ -0,0 +1,142 @@
+package check
+
+import (
+	"fmt"
+
+	"hack"
+	"hack/vmemu"
+)
+
+// VM checks a machine.
+type VM struct {
+	m         *vmemu.Machine
+	mem       *memory
+	functions []string // function of each command, "" if none
+}
+
+// NewVM returns a checker of m.
+func NewVM(m *vmemu.Machine) *VM {
+	p := m.Program
+	_, heap := p.Functions["Memory.alloc"]
+	ch := &VM{m: m, mem: newMemory(&m.RAM, true, heap), functions: make([]string, len(p.Code))}
+	for addr := hack.Stack; addr < int(m.RAM[hack.SP]) && addr < hack.RAMSize; addr++ {
+		ch.mem.written[addr] = true
+	}
+	for i, cmd := range p.Code {
+		if cmd.Op == vmemu.Function {
+			ch.functions[i] = cmd.Name
+		} else if i > 0 && p.Code[i-1].File == cmd.File {
+			ch.functions[i] = ch.functions[i-1]
+		}
+	}
+
+	return ch
+}
+
+// Step runs the next command of the machine if its accesses are allowed,
+// and fails otherwise, or if the machine fails.
+func (ch *VM) Step() error {
+	m := ch.m
+	if m.Halted() {
+		return m.Step()
+	}
+
+	pc := m.PC
+	cmd := m.Next()
+	sp := int(m.RAM[hack.SP])
+	ch.mem.os = isMemory(ch.functions[pc])
+	if err := ch.check(cmd, sp); err != nil {
+		return fmt.Errorf("%v, step %d: %v", ch.where(pc), m.Steps, err)
+	}
+	if cmd.Op == vmemu.Call && sp > 0 {
+		switch cmd.Name {
+		case "Memory.alloc":
+			ch.mem.alloc(pc+1, sp-cmd.Index)
+		case "Memory.deAlloc":
+			ch.mem.free(int(m.RAM[sp-1]))
+		}
+	}
+	if err := m.Step(); err != nil {
+		return err
+	}
+	ch.mem.returned(m.PC)
+
+	return nil
+}
+
+// check checks the accesses of cmd, with the stack at sp. Those that the
+// machine fails on are left to it.
+func (ch *VM) check(cmd vmemu.Command, sp int) error {
+	m, mem := ch.m, ch.mem
+	switch cmd.Op {
+	case vmemu.Push:
+		if cmd.Segment != vmemu.Constant {
+			addr, err := m.Address(cmd)
+			if err != nil {
+				return nil
+			}
+			if err := mem.read(addr); err != nil {
+				return err
+			}
+		}
+		return mem.push(sp)
+	case vmemu.Pop:
+		if err := mem.pop(sp - 1); err != nil {
+			return err
+		}
+		addr, err := m.Address(cmd)
+		if err != nil {
+			return nil
+		}
+		return mem.write(addr, 0)
+	case vmemu.Neg, vmemu.Not:
+		if err := mem.pop(sp - 1); err != nil {
+			return err
+		}
+		return mem.push(sp - 1)
+	case vmemu.Add, vmemu.Sub, vmemu.Eq, vmemu.Gt, vmemu.Lt, vmemu.And, vmemu.Or:
+		if err := mem.pop(sp - 1); err != nil {
+			return err
+		}
+		if err := mem.pop(sp - 2); err != nil {
+			return err
+		}
+		return mem.push(sp - 2)
+	case vmemu.IfGoto:
+		return mem.pop(sp - 1)
+	case vmemu.Function:
+		for i := 0; i < cmd.Index; i++ {
+			if err := mem.push(sp + i); err != nil {
+				return err
+			}
+		}
+	case vmemu.Call:
+		for i := 0; i < 5; i++ {
+			if err := mem.push(sp + i); err != nil {
+				return err
+			}
+		}
+	case vmemu.Return:
+		frame := int(m.RAM[hack.LCL])
+		for i := 5; i > 0; i-- {
+			if err := mem.read(frame - i); err != nil {
+				return err
+			}
+		}
+		if err := mem.pop(sp - 1); err != nil {
+			return err
+		}
+		return mem.write(int(m.RAM[hack.ARG]), 0)
+	}
+
+	return nil
+}
+
+func (ch *VM) where(pc int) string {
+	where := ch.m.Program.Position(pc)
+	if function := ch.functions[pc]; function != "" {
+		where += " (" + function + ")"
+	}
+
+	return where
+}
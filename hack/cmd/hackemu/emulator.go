@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+
+	"hack/asm"
+	"hack/check"
+	"hack/cpu"
+	"hack/profile"
+	"hack/trace"
+	"hack/tui"
+	"hack/vmemu"
+)
+
+// emulator is the computer that runs machine code or the machine that runs
+// VM code. A cycle is an instruction of the computer or a command of the
+// machine.
+type emulator interface {
+	tui.Emulator
+}
+
+type computer struct {
+	c *cpu.Computer
+}
+
+func (c *computer) Step() error {
+	c.c.Step()
+	return nil
+}
+
+func (c *computer) Halted() bool   { return c.c.Halted() }
+func (c *computer) Cycles() uint64 { return c.c.Cycles }
+func (c *computer) RAM() []int16   { return c.c.RAM[:] }
+
+func (c *computer) Status() []string {
+	return []string{
+		fmt.Sprintf("PC     %6d", c.c.PC),
+		fmt.Sprintf("A      %6d", c.c.A),
+		fmt.Sprintf("D      %6d", c.c.D),
+		fmt.Sprintf("cycles %d", c.c.Cycles),
+	}
+}
+
+type machine struct {
+	m *vmemu.Machine
+}
+
+func (m *machine) Step() error { return m.m.Step() }
+
+// Halted reports whether the machine halted, or called Sys.halt of the
+// OS, which loops forever.
+func (m *machine) Halted() bool {
+	return m.m.Halted() || m.m.Function() == "Sys.halt"
+}
+
+func (m *machine) Cycles() uint64 { return m.m.Steps }
+func (m *machine) RAM() []int16   { return m.m.RAM[:] }
+
+func (m *machine) Status() []string {
+	status := []string{m.m.Function(), fmt.Sprintf("PC     %6d", m.m.PC)}
+	if !m.m.Halted() {
+		status = append(status, m.m.Next().String())
+	}
+
+	return append(status, fmt.Sprintf("cycles %d", m.m.Steps))
+}
+
+type profiledComputer struct {
+	*computer
+	p *profile.CPU
+}
+
+func (c *profiledComputer) Step() error {
+	c.p.Step()
+	return nil
+}
+
+type profiledMachine struct {
+	*machine
+	p *profile.VM
+}
+
+func (m *profiledMachine) Step() error { return m.p.Step() }
+
+// profiled returns e, which counts the cycles of its program in the
+// returned profile. p is the assembled source of machine code, or nil.
+func profiled(e emulator, p *asm.Program, name string) (emulator, *profile.Profile) {
+	switch e := e.(type) {
+	case *computer:
+		pr := profile.NewCPU(e.c, p, name)
+		return &profiledComputer{e, pr}, pr.Profile
+	case *machine:
+		pr := profile.NewVM(e.m, name)
+		return &profiledMachine{e, pr}, pr.Profile
+	}
+
+	panic("unknown emulator")
+}
+
+type checkedComputer struct {
+	*computer
+	ch *check.CPU
+}
+
+func (c *checkedComputer) Step() error { return c.ch.Step() }
+
+type checkedMachine struct {
+	*machine
+	ch *check.VM
+}
+
+func (m *checkedMachine) Step() error { return m.ch.Step() }
+
+// checked returns e, which fails at the first access to the RAM that
+// breaks its layout. p is the assembled source of machine code, or nil.
+func checked(e emulator, p *asm.Program) emulator {
+	switch e := e.(type) {
+	case *computer:
+		return &checkedComputer{e, check.NewCPU(e.c, p)}
+	case *machine:
+		return &checkedMachine{e, check.NewVM(e.m)}
+	}
+
+	panic("unknown emulator")
+}
+
+type tracedComputer struct {
+	*computer
+	r *trace.Recorder
+}
+
+func (c *tracedComputer) Step() error {
+	c.r.Step()
+	return nil
+}
+
+// fastComputer is a computer that runs many cycles at once, with its
+// program decoded once.
+type fastComputer struct {
+	*computer
+	f *cpu.Fast
+}
@@ -0,0 +1,397 @@
+// Command hackemu runs a program of the Hack computer: machine code, a
+// .hack file or a .asm file that it assembles, or VM code, .vm files or a
+// directory of them. With -debug or -script, it runs machine code in the
+// debugger instead, with the labels and variables of the .asm file of the
+// same name as the .hack file, if there is one. With -tui, it runs the
+// program in the terminal, where it can be played. With -profile or
+// -report, it counts the cycles of each location, function and call of the
+// program, for go tool pprof or as text. With -trace, it records the
+// cycles of machine code for hacktrace. With -save, it saves a snapshot of
+// the emulator at the end of the run, which it runs on from when given
+// instead of a program. With -fast, it runs machine code decoded once, in
+// blocks of instructions up to a jump. With -check, it stops at the first
+// access to the RAM that breaks its layout: a stack overflow, a write to
+// the keyboard, a read of a word never written or an access to the heap
+// outside the blocks of Memory.alloc.
+//
+//	hackemu [flags] program.hack|program.asm|snapshot.snap
+//	hackemu [flags] dir|file.vm...
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"hack/asm"
+	"hack/cpu"
+	"hack/debugger"
+	"hack/keyboard"
+	"hack/profile"
+	"hack/screen"
+	"hack/snapshot"
+	"hack/trace"
+	"hack/tui"
+	"hack/vmemu"
+)
+
+var (
+	debug   = flag.Bool("debug", false, "run the program in the debugger")
+	script  = flag.String("script", "", "run the debugger commands of a file, then quit")
+	cycles  = flag.Uint64("cycles", 10_000_000, "most instructions, or VM commands, run without the debugger")
+	shot    = flag.String("screen", "", "save the screen to a PNG file at the end of the run")
+	shotsAt = flag.String("screen-at", "", "save the screen at these comma-separated cycles, to the -screen file, screen.png by default, with the cycle before its extension")
+	keys    = flag.String("keys", "", "press the keys of a timeline file, lines such as \"1000 press right\"")
+	frame   = flag.Uint64("frame", 100_000, "cycles of a frame, for the times of -keys in frames")
+
+	terminal = flag.Bool("tui", false, "run the program in the terminal, with its screen and keyboard")
+	mode     = flag.String("mode", "braille", "characters that draw the screen with -tui, braille or half")
+	scale    = flag.Int("scale", 0, "pixels of a dot of the screen across and down with -tui, 0 to fit the terminal")
+	speed    = flag.Uint64("speed", 5_000_000, "cycles a second with -tui, 0 for as fast as possible")
+	hold     = flag.Duration("hold", 150*time.Millisecond, "time a key typed stays pressed with -tui")
+
+	profileFile = flag.String("profile", "", "write a profile of the cycles of the run to a file, for go tool pprof")
+	report      = flag.Int("report", 0, "print the first `n` functions, calls and locations that ran the most cycles")
+	traceFile   = flag.String("trace", "", "record the cycles of machine code to a file, for hacktrace")
+	traceLast   = flag.Int("trace-last", 0, "record the last `n` cycles only with -trace, 0 for all")
+	save        = flag.String("save", "", "save a snapshot of the emulator to a file at the end of the run")
+	fast        = flag.Bool("fast", false, "run machine code with its instructions decoded once, in blocks")
+	checkRAM    = flag.Bool("check", false, "stop at the first access to the RAM that breaks its layout, such as a stack overflow")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: hackemu [flags] program.hack|program.asm|snapshot.snap|dir|file.vm...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	e, program, err := load(flag.Args())
+	if err != nil {
+		printErr(err.Error())
+	}
+	base := e
+
+	if *debug || *script != "" {
+		c, ok := e.(*computer)
+		if !ok {
+			printErr("the debugger runs machine code, not VM code")
+		}
+		d := debugger.New(c.c, program)
+		interrupts := make(chan os.Signal, 1)
+		signal.Notify(interrupts, os.Interrupt)
+		go func() {
+			for range interrupts {
+				d.Interrupt()
+			}
+		}()
+
+		if *script != "" {
+			f, err := os.Open(*script)
+			if err != nil {
+				printErr(err.Error())
+			}
+			defer f.Close()
+			if err := d.Run(f, os.Stdout, true); err != nil {
+				printErr(err.Error())
+			}
+			return
+		}
+		if err := d.Run(os.Stdin, os.Stdout, false); err != nil {
+			printErr(err.Error())
+		}
+		return
+	}
+
+	if *checkRAM {
+		if *profileFile != "" || *report > 0 || *traceFile != "" || *fast {
+			printErr("-check runs without -profile, -report, -trace or -fast")
+		}
+		e = checked(e, program)
+	}
+	var prof *profile.Profile
+	if *profileFile != "" || *report > 0 {
+		e, prof = profiled(e, program, filepath.Base(flag.Arg(0)))
+	}
+	closeTrace := func() {}
+	if *traceFile != "" {
+		c, ok := e.(*computer)
+		if !ok {
+			printErr("-trace records machine code, without -profile or -report")
+		}
+		f, err := os.Create(*traceFile)
+		if err != nil {
+			printErr(err.Error())
+		}
+		r := trace.New(c.c, f, *traceLast)
+		closeTrace = func() {
+			if err := r.Close(); err != nil {
+				printErr(err.Error())
+			}
+			if err := f.Close(); err != nil {
+				printErr(err.Error())
+			}
+		}
+		e = &tracedComputer{c, r}
+	}
+	if *fast {
+		c, ok := e.(*computer)
+		if !ok {
+			printErr("-fast runs machine code, without -profile, -report or -trace")
+		}
+		e = &fastComputer{c, cpu.NewFast(c.c)}
+	}
+
+	if *terminal {
+		opts := tui.Options{Scale: *scale, Speed: *speed, Hold: *hold}
+		switch *mode {
+		case "braille":
+		case "half":
+			opts.Mode = tui.HalfBlock
+		default:
+			printErr(fmt.Sprintf("invalid mode %v", *mode))
+		}
+		if err := tui.Run(e, os.Stdin, os.Stdout, opts); err != nil {
+			printErr(err.Error())
+		}
+		writeProfile(prof)
+		closeTrace()
+		return
+	}
+
+	var shots []uint64
+	if *shotsAt != "" {
+		if shots, err = parseCycles(*shotsAt); err != nil {
+			printErr(err.Error())
+		}
+	}
+	name := *shot
+	if name == "" {
+		name = "screen.png"
+	}
+	timeline := &keyboard.Timeline{}
+	if *keys != "" {
+		src, err := os.ReadFile(*keys)
+		if err != nil {
+			printErr(err.Error())
+		}
+		if timeline, err = keyboard.Parse(*keys, src, *frame); err != nil {
+			printErr(err.Error())
+		}
+	}
+
+	runErr := run(e, *cycles, func() (uint64, error) {
+		timeline.Apply(e.Cycles(), e.RAM())
+		for len(shots) > 0 && e.Cycles() >= shots[0] {
+			ext := filepath.Ext(name)
+			file := fmt.Sprintf("%v-%d%v", strings.TrimSuffix(name, ext), shots[0], ext)
+			if err := screen.Save(file, e.RAM()); err != nil {
+				return 0, err
+			}
+			shots = shots[1:]
+		}
+		next, ok := timeline.Next()
+		if !ok {
+			next = ^uint64(0)
+		}
+		if len(shots) > 0 {
+			next = min(next, shots[0])
+		}
+		return next, nil
+	})
+	if runErr == nil && *shot != "" {
+		runErr = screen.Save(*shot, e.RAM())
+	}
+	writeProfile(prof)
+	closeTrace()
+	if runErr == nil && *save != "" {
+		runErr = saveSnapshot(base, *save)
+	}
+	if runErr != nil {
+		printErr(runErr.Error())
+	}
+	if e.Halted() {
+		fmt.Printf("halted after %d cycles\n", e.Cycles())
+	} else {
+		fmt.Printf("stopped after %d cycles\n", e.Cycles())
+	}
+}
+
+// writeProfile writes p to the -profile file and its report to the
+// standard output, if p is not nil.
+func writeProfile(p *profile.Profile) {
+	if p == nil {
+		return
+	}
+	if *report > 0 {
+		if err := p.WriteReport(os.Stdout, *report); err != nil {
+			printErr(err.Error())
+		}
+		fmt.Println()
+	}
+	if *profileFile == "" {
+		return
+	}
+	f, err := os.Create(*profileFile)
+	if err != nil {
+		printErr(err.Error())
+	}
+	if err := p.WritePprof(f); err != nil {
+		printErr(err.Error())
+	}
+	if err := f.Close(); err != nil {
+		printErr(err.Error())
+	}
+}
+
+// saveSnapshot saves a snapshot of e to the file name.
+func saveSnapshot(e emulator, name string) error {
+	switch e := e.(type) {
+	case *computer:
+		return (&snapshot.Snapshot{Computer: e.c}).Save(name)
+	case *machine:
+		return (&snapshot.Snapshot{Machine: e.m}).Save(name)
+	}
+
+	return fmt.Errorf("no snapshot of %T", e)
+}
+
+// run steps e until it halts or ran limit cycles. before is called before
+// each step, and after the last, and returns the next cycle it must be
+// called at: a fast computer runs up to it at once.
+func run(e emulator, limit uint64, before func() (uint64, error)) error {
+	for !e.Halted() && e.Cycles() < limit {
+		next, err := before()
+		if err != nil {
+			return err
+		}
+		if f, ok := e.(*fastComputer); ok {
+			f.f.Run(min(next, limit) - e.Cycles())
+			continue
+		}
+		if err := e.Step(); err != nil {
+			return err
+		}
+	}
+	_, err := before()
+
+	return err
+}
+
+// parseCycles returns the cycles of a comma-separated list, which must
+// increase.
+func parseCycles(s string) ([]uint64, error) {
+	var cycles []uint64
+	for _, field := range strings.Split(s, ",") {
+		n, err := strconv.ParseUint(strings.TrimSpace(field), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cycle %q", field)
+		}
+		if len(cycles) > 0 && n <= cycles[len(cycles)-1] {
+			return nil, fmt.Errorf("cycles must increase: %v", s)
+		}
+		cycles = append(cycles, n)
+	}
+
+	return cycles, nil
+}
+
+// load returns the emulator of the program of paths, and the assembled
+// source of machine code if there is one.
+func load(paths []string) (emulator, *asm.Program, error) {
+	path := paths[0]
+	switch filepath.Ext(path) {
+	case ".snap":
+		if len(paths) > 1 {
+			return nil, nil, fmt.Errorf("one snapshot only")
+		}
+		s, err := snapshot.Load(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if s.Computer != nil {
+			return &computer{s.Computer}, nil, nil
+		}
+		return &machine{s.Machine}, nil, nil
+	case ".hack", ".asm":
+		if len(paths) > 1 {
+			return nil, nil, fmt.Errorf("one file of machine code only")
+		}
+		code, p, err := loadCode(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &computer{cpu.New(code)}, p, nil
+	}
+
+	var p *vmemu.Program
+	if info, err := os.Stat(path); err == nil && info.IsDir() && len(paths) == 1 {
+		p, err = vmemu.LoadDir(path)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		var files []vmemu.File
+		for _, path := range paths {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				return nil, nil, err
+			}
+			name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+			files = append(files, vmemu.File{Name: name, Src: src})
+		}
+		if p, err = vmemu.Load(files...); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return &machine{vmemu.New(p)}, nil, nil
+}
+
+// loadCode returns the machine code of a .hack or .asm file, and the
+// assembled source if there is one.
+func loadCode(path string) ([]uint16, *asm.Program, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if filepath.Ext(path) == ".asm" {
+		p, err := asm.Assemble(src)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%v: %v", path, err)
+		}
+		return p.Code, p, nil
+	}
+
+	code, err := asm.ParseHack(src)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v: %v", path, err)
+	}
+	source := strings.TrimSuffix(path, filepath.Ext(path)) + ".asm"
+	src, err = os.ReadFile(source)
+	if err != nil {
+		return code, nil, nil
+	}
+	p, err := asm.Assemble(src)
+	if err != nil || !bytes.Equal(asm.FormatHack(p.Code), asm.FormatHack(code)) {
+		fmt.Fprintf(os.Stderr, "hackemu: %v does not assemble to %v, no symbols\n", source, path)
+		return code, nil, nil
+	}
+
+	return code, p, nil
+}
+
+func printErr(err string) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
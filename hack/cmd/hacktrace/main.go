@@ -0,0 +1,189 @@
+// Command hacktrace reads a trace of a run of the Hack computer, as
+// written by hackemu -trace, to search it and to show the state of the
+// computer after any cycle of the trace. Without flags, it prints the last
+// records of the trace.
+//
+//	hacktrace [flags] trace
+//
+// For instance, the last write to the top of the stack before cycle
+// 1000000, and the state of the computer then:
+//
+//	hacktrace -at 1000000 -write 256 -state trace
+//
+// With -compare, it runs a program in lockstep with the trace, as written
+// by another implementation of the computer such as an HDL simulator, and
+// prints the first cycle where the emulator diverges from it:
+//
+//	hacktrace -compare Pong.hack trace
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"hack"
+	"hack/asm"
+	"hack/cpu"
+	"hack/lockstep"
+	"hack/trace"
+)
+
+var (
+	at      = flag.Int64("at", -1, "the cycle to search back from and to show the state after, -1 for the end")
+	n       = flag.Int("n", 10, "records to print up to the cycle")
+	write   = flag.Int("write", -1, "print the last write to this RAM `address`")
+	pc      = flag.Int("pc", -1, "print the last cycle that ran the instruction at this ROM `address`")
+	state   = flag.Bool("state", false, "print the registers, the pointers of the VM and the top of the stack after the cycle")
+	ram     = flag.String("ram", "", "print these RAM words after the cycle, as 256 or 256-263")
+	compare = flag.String("compare", "", "run this .hack or .asm `program` in lockstep with the trace, up to the cycle, and print the first divergence")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: hacktrace [flags] trace")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		printErr(err.Error())
+	}
+	t, err := trace.Read(f)
+	f.Close()
+	if err != nil {
+		printErr(fmt.Sprintf("%v: %v", flag.Arg(0), err))
+	}
+
+	cycle := t.End.Cycles
+	if *at >= 0 {
+		cycle = uint64(*at)
+	}
+	if *compare != "" {
+		compareProgram(t, *compare, cycle)
+		return
+	}
+	if cycle < t.First() || cycle > t.End.Cycles {
+		printErr(fmt.Sprintf("cycle %d is not in the trace, from %d to %d", cycle, t.First(), t.End.Cycles))
+	}
+	fmt.Printf("%d records, cycles %d to %d\n", len(t.Records), t.First()+1, t.End.Cycles)
+
+	searched := false
+	if *write >= 0 {
+		searched = true
+		if r, ok := t.LastWrite(*write, cycle); ok {
+			fmt.Printf("RAM[%d] last written at cycle %d\n%v\n", *write, r.Cycle, r)
+		} else {
+			fmt.Printf("RAM[%d] not written in the trace up to cycle %d\n", *write, cycle)
+		}
+	}
+	if *pc >= 0 {
+		searched = true
+		if r, ok := t.Last(cycle, func(r trace.Record) bool { return int(r.PC) == *pc }); ok {
+			fmt.Printf("ROM[%d] last run at cycle %d\n%v\n", *pc, r.Cycle, r)
+		} else {
+			fmt.Printf("ROM[%d] not run in the trace up to cycle %d\n", *pc, cycle)
+		}
+	}
+
+	if *state || *ram != "" {
+		c, err := t.State(cycle)
+		if err != nil {
+			printErr(err.Error())
+		}
+		fmt.Printf("after cycle %d\n", cycle)
+		if *state {
+			fmt.Printf("PC %6d\nA  %6d\nD  %6d\n", c.PC, c.A, c.D)
+			for i, name := range []string{"SP", "LCL", "ARG", "THIS", "THAT"} {
+				fmt.Printf("%-6v %6d\n", name, c.RAM[i])
+			}
+			sp := int(uint16(c.RAM[hack.SP]))
+			for addr := sp - 1; addr >= max(sp-8, 0) && addr < hack.RAMSize; addr-- {
+				fmt.Printf("RAM[%d] %d\n", addr, c.RAM[addr])
+			}
+		}
+		if *ram != "" {
+			from, to, err := parseRange(*ram)
+			if err != nil {
+				printErr(err.Error())
+			}
+			for addr := from; addr <= to; addr++ {
+				fmt.Printf("RAM[%d] %d\n", addr, c.RAM[addr])
+			}
+		}
+		return
+	}
+
+	if !searched {
+		for _, r := range t.Records[max(0, int(cycle-t.First())-*n):int(cycle-t.First())] {
+			fmt.Println(r)
+		}
+	}
+}
+
+// compareProgram runs the program at path in lockstep with the trace t up
+// to cycle, and exits with an error at the first divergence.
+func compareProgram(t *trace.Trace, path string, cycle uint64) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		printErr(err.Error())
+	}
+	var code []uint16
+	if strings.HasSuffix(path, ".asm") {
+		var p *asm.Program
+		if p, err = asm.Assemble(src); err == nil {
+			code = p.Code
+		}
+	} else {
+		code, err = asm.ParseHack(src)
+	}
+	if err != nil {
+		printErr(fmt.Sprintf("%v: %v", path, err))
+	}
+	if !slices.Equal(code, t.ROM) {
+		printErr(fmt.Sprintf("%v is not the program of the trace", path))
+	}
+	ref, err := lockstep.Trace(t)
+	if err != nil {
+		printErr(err.Error())
+	}
+	if cycle == 0 {
+		fmt.Println("0 cycles alike")
+		return
+	}
+
+	cycles, err := lockstep.Compare(cpu.New(code), ref, cycle)
+	fmt.Printf("%d cycles alike\n", cycles)
+	if err != nil {
+		printErr(err.Error())
+	}
+}
+
+// parseRange returns the addresses of a RAM word, 256, or of a range of
+// them, 256-263.
+func parseRange(s string) (int, int, error) {
+	first, last, ok := strings.Cut(s, "-")
+	from, err := strconv.Atoi(first)
+	to := from
+	if err == nil && ok {
+		to, err = strconv.Atoi(last)
+	}
+	if err != nil || from < 0 || to < from || to >= hack.RAMSize {
+		return 0, 0, fmt.Errorf("invalid RAM range %q", s)
+	}
+
+	return from, to, nil
+}
+
+func printErr(err string) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,142 @@
+// Package cpu emulates the Hack computer at the level of its machine code:
+// the CPU of project 5 with its ROM and its RAM.
+package cpu
+
+import (
+	"hack"
+)
+
+// ROMSize is the number of words of the instruction memory.
+const ROMSize = hack.ROMSize
+
+// Computer runs a program of Hack machine code.
+type Computer struct {
+	ROM    [ROMSize]uint16
+	RAM    [hack.RAMSize]int16
+	A, D   int16
+	PC     uint16 // next instruction
+	Cycles uint64 // instructions run so far
+	Size   int    // words of the program in the ROM
+}
+
+// New returns a computer with code in its ROM, reset. The code past the
+// ROM is left out.
+func New(code []uint16) *Computer {
+	c := &Computer{Size: min(len(code), ROMSize)}
+	copy(c.ROM[:], code)
+
+	return c
+}
+
+// Clone returns a copy of c, which runs on its own.
+func (c *Computer) Clone() *Computer {
+	clone := *c
+	return &clone
+}
+
+// Reset starts the program again. It leaves the RAM as it is, as the
+// reset button of the Hack computer does.
+func (c *Computer) Reset() {
+	c.A, c.D, c.PC, c.Cycles = 0, 0, 0, 0
+}
+
+// Halted reports whether the computer ran past the end of the program, or
+// loops forever on a jump to itself, which is how Hack programs end:
+//
+//	(END)
+//	@END
+//	0;JMP
+func (c *Computer) Halted() bool {
+	return c.haltsAt(int(c.PC))
+}
+
+// haltsAt reports whether the computer halts at address pc.
+func (c *Computer) haltsAt(pc int) bool {
+	if pc >= c.Size {
+		return true
+	}
+	next := c.ROM[pc]
+	if pc+1 < c.Size && next&(1<<15) == 0 && int(next) == pc {
+		return c.ROM[pc+1] == 0b1110101010000111 // 0;JMP
+	}
+
+	return false
+}
+
+// Run steps until the computer halts or ran n instructions.
+func (c *Computer) Run(n uint64) {
+	for ; n > 0 && !c.Halted(); n-- {
+		c.Step()
+	}
+}
+
+// Step runs the instruction at PC. It reports the address of the RAM word
+// the instruction wrote, if any. Addresses are the low 15 bits of A.
+func (c *Computer) Step() (addr int, wrote bool) {
+	inst := c.ROM[c.PC&(ROMSize-1)]
+	c.Cycles++
+	if inst&(1<<15) == 0 {
+		c.A = int16(inst)
+		c.PC++
+		return 0, false
+	}
+
+	addr = int(uint16(c.A) & (hack.RAMSize - 1))
+	y := c.A
+	if inst&(1<<12) != 0 {
+		y = c.RAM[addr]
+	}
+	out := ALU(c.D, y, inst>>6)
+
+	pc := c.PC + 1
+	if jump(inst, out) {
+		pc = uint16(c.A) & (ROMSize - 1)
+	}
+	if inst&(1<<3) != 0 {
+		c.RAM[addr] = out
+		wrote = true
+	}
+	if inst&(1<<5) != 0 {
+		c.A = out
+	}
+	if inst&(1<<4) != 0 {
+		c.D = out
+	}
+	c.PC = pc
+
+	return addr, wrote
+}
+
+func jump(inst uint16, out int16) bool {
+	return inst&0b100 != 0 && out < 0 ||
+		inst&0b010 != 0 && out == 0 ||
+		inst&0b001 != 0 && out > 0
+}
+
+// ALU computes the output of the ALU of project 2 for inputs x and y. The
+// low six bits of control are zx, nx, zy, ny, f and no, from the highest.
+func ALU(x, y int16, control uint16) int16 {
+	if control&0b100000 != 0 {
+		x = 0
+	}
+	if control&0b010000 != 0 {
+		x = ^x
+	}
+	if control&0b001000 != 0 {
+		y = 0
+	}
+	if control&0b000100 != 0 {
+		y = ^y
+	}
+	var out int16
+	if control&0b000010 != 0 {
+		out = x + y
+	} else {
+		out = x & y
+	}
+	if control&0b000001 != 0 {
+		out = ^out
+	}
+
+	return out
+}
@@ -0,0 +1,203 @@
+package cpu_test
+
+import (
+	"math/rand"
+	"os"
+	"testing"
+
+	"hack"
+	"hack/asm"
+	"hack/cpu"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func load(t *testing.T, src string) *cpu.Computer {
+	t.Helper()
+	p, err := asm.Assemble([]byte(src))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return cpu.New(p.Code)
+}
+
+func TestALU(t *testing.T) {
+	tests := []struct {
+		comp string
+		want int16
+	}{
+		{"0", 0}, {"1", 1}, {"-1", -1},
+		{"D", 12}, {"A", -5}, {"!D", ^12}, {"!A", 4},
+		{"-D", -12}, {"-A", 5}, {"D+1", 13}, {"A+1", -4}, {"D-1", 11}, {"A-1", -6},
+		{"D+A", 7}, {"D-A", 17}, {"A-D", -17}, {"D&A", 12 & -5}, {"D|A", 12 | -5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.comp, func(t *testing.T) {
+			p, err := asm.Assemble([]byte(tt.comp))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, cpu.ALU(12, -5, p.Code[0]>>6))
+		})
+	}
+}
+
+func TestComputer_Run(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		ram  map[int]int16 // RAM before the run
+		want map[int]int16 // RAM after the run
+	}{
+		{"1. Add", "Add", nil, map[int]int16{0: 5}},
+		{"2. Max, R0", "Max", map[int]int16{0: 7, 1: -3}, map[int]int16{2: 7}},
+		{"3. Max, R1", "Max", map[int]int16{0: -7, 1: 3}, map[int]int16{2: 3}},
+		{"4. Rect", "Rect", map[int]int16{0: 3}, map[int]int16{16: 0, hack.Screen: -1, hack.Screen + 64: -1, hack.Screen + 96: 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := os.ReadFile("../../project6/tests/" + tt.file + ".asm")
+			assert.NoError(t, err)
+			c := load(t, string(src))
+			for addr, v := range tt.ram {
+				c.RAM[addr] = v
+			}
+			c.Run(1000)
+			assert.True(t, c.Halted())
+			for addr, want := range tt.want {
+				assert.Equal(t, want, c.RAM[addr], "RAM[%d]", addr)
+			}
+		})
+	}
+}
+
+func TestComputer_Step(t *testing.T) {
+	// the instruction reads M at the old A, then writes A, M and D
+	c := load(t, "@100\nD=A\nM=D\nAMD=M+1")
+	c.Run(3)
+	addr, wrote := c.Step()
+	assert.Equal(t, 100, addr)
+	assert.True(t, wrote)
+	assert.Equal(t, int16(101), c.RAM[100])
+	assert.Equal(t, int16(101), c.A)
+	assert.Equal(t, int16(101), c.D)
+	assert.Equal(t, uint64(4), c.Cycles)
+
+	// addresses are the low 15 bits of A, and a jump goes to A
+	c = load(t, "@32767\nA=!A\nM=1;JMP")
+	c.Run(3)
+	assert.Equal(t, int16(1), c.RAM[0])
+	assert.Equal(t, uint16(0), c.PC)
+
+	c = load(t, "@0\n(END)\n@END\n0;JMP")
+	assert.False(t, c.Halted())
+	c.Step()
+	assert.True(t, c.Halted())
+	c.Reset()
+	assert.Equal(t, uint16(0), c.PC)
+}
+
+func TestNew(t *testing.T) {
+	// the code past the ROM is left out
+	code := make([]uint16, cpu.ROMSize+10)
+	c := cpu.New(code)
+	assert.Equal(t, cpu.ROMSize, c.Size)
+	c.PC = cpu.ROMSize - 1
+	assert.False(t, c.Halted())
+	c.Step()
+	assert.True(t, c.Halted())
+}
+
+func TestComputer_Clone(t *testing.T) {
+	c := load(t, "@5\nD=A\n@16\nM=D")
+	c.Run(2)
+	clone := c.Clone()
+	clone.Run(2)
+	assert.Equal(t, int16(5), clone.RAM[16])
+	assert.Equal(t, int16(0), c.RAM[16])
+	assert.Equal(t, uint16(2), c.PC)
+	assert.Equal(t, uint64(4), clone.Cycles)
+}
+
+func TestFast_Run(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		ram   map[int]int16
+		steps []uint64 // cycles of each run
+	}{
+		{"1. Add", "Add", nil, []uint64{100}},
+		{"2. Max", "Max", map[int]int16{0: -7, 1: 3}, []uint64{1, 2, 3, 100}},
+		{"3. Rect", "Rect", map[int]int16{0: 5}, []uint64{7, 13, 1000}},
+		{"4. Pong", "Pong", nil, []uint64{1, 1000, 99_999, 1_000_000, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := os.ReadFile("../../project6/tests/" + tt.file + ".asm")
+			assert.NoError(t, err)
+			want := load(t, string(src))
+			for addr, v := range tt.ram {
+				want.RAM[addr] = v
+			}
+			got := want.Clone()
+			f := cpu.NewFast(got)
+			for _, n := range tt.steps {
+				want.Run(n)
+				f.Run(n)
+				if !assert.Equal(t, want, got, "after %d cycles", want.Cycles) {
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestFast_Random(t *testing.T) {
+	// any word is an instruction, and runs as Step runs it
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		code := make([]uint16, 1+r.Intn(200))
+		for j := range code {
+			code[j] = uint16(r.Intn(1 << 16))
+			if code[j]&(1<<15) == 0 {
+				code[j] %= uint16(len(code) + 10)
+			}
+		}
+		want := cpu.New(code)
+		got := want.Clone()
+		f := cpu.NewFast(got)
+		for _, n := range []uint64{1, 17, 500, 10_000} {
+			want.Run(n)
+			f.Run(n)
+		}
+		if !assert.Equal(t, want, got, "program %d", i) {
+			return
+		}
+	}
+}
+
+// benchmarkPong runs Pong a million instructions at a time, by run.
+func benchmarkPong(b *testing.B, run func(c *cpu.Computer) func(n uint64)) {
+	src, err := os.ReadFile("../../project6/tests/Pong.asm")
+	if err != nil {
+		b.Fatal(err)
+	}
+	p, err := asm.Assemble(src)
+	if err != nil {
+		b.Fatal(err)
+	}
+	c := cpu.New(p.Code)
+	runN := run(c)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		runN(1_000_000)
+	}
+	b.ReportMetric(float64(c.Cycles)/b.Elapsed().Seconds(), "inst/s")
+}
+
+func BenchmarkComputer_Run(b *testing.B) {
+	benchmarkPong(b, func(c *cpu.Computer) func(uint64) { return c.Run })
+}
+
+func BenchmarkFast_Run(b *testing.B) {
+	benchmarkPong(b, func(c *cpu.Computer) func(uint64) { return cpu.NewFast(c).Run })
+}
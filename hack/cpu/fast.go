@@ -0,0 +1,210 @@
+package cpu
+
+import (
+	"hack"
+)
+
+// Fast runs a computer with its program decoded once, as the ROM does not
+// change, rather than at each instruction as Step does. The program is cut
+// into basic blocks, which end with a jump, and a block runs without
+// checking for the end of the program or for jumps until its last
+// instruction.
+type Fast struct {
+	c    *Computer
+	ops  []op
+	ends []int  // last instruction of the block that starts at each address
+	halt []bool // the computer halts at each address
+}
+
+// op is a decoded instruction.
+type op struct {
+	code  uint8 // what it computes, or opLoad
+	m     bool  // the ALU computes with M rather than A
+	dest  uint8 // the A, D and M bits
+	jump  uint8
+	value int16 // of opLoad, or the control bits of the ALU for opOther
+}
+
+// The codes of ops: opLoad for A instructions, then a code for each
+// computation of the Hack assembly language, with D as x and A or M as y,
+// and opOther for the computations without a mnemonic.
+const (
+	opLoad = iota
+	opZero
+	opOne
+	opMinusOne
+	opX
+	opY
+	opNotX
+	opNotY
+	opNegX
+	opNegY
+	opXPlusOne
+	opYPlusOne
+	opXMinusOne
+	opYMinusOne
+	opXPlusY
+	opXMinusY
+	opYMinusX
+	opXAndY
+	opXOrY
+	opOther
+)
+
+// codes are the codes of the control bits of the ALU.
+var codes = map[uint16]uint8{
+	0b101010: opZero,
+	0b111111: opOne,
+	0b111010: opMinusOne,
+	0b001100: opX,
+	0b110000: opY,
+	0b001101: opNotX,
+	0b110001: opNotY,
+	0b001111: opNegX,
+	0b110011: opNegY,
+	0b011111: opXPlusOne,
+	0b110111: opYPlusOne,
+	0b001110: opXMinusOne,
+	0b110010: opYMinusOne,
+	0b000010: opXPlusY,
+	0b010011: opXMinusY,
+	0b000111: opYMinusX,
+	0b000000: opXAndY,
+	0b010101: opXOrY,
+}
+
+// The bits of dest.
+const (
+	destM = 1 << iota
+	destD
+	destA
+)
+
+// NewFast returns the fast runner of c, which decodes its program.
+func NewFast(c *Computer) *Fast {
+	f := &Fast{
+		c:    c,
+		ops:  make([]op, c.Size),
+		ends: make([]int, c.Size),
+		halt: make([]bool, c.Size+1),
+	}
+	for i, inst := range c.ROM[:c.Size] {
+		if inst&(1<<15) == 0 {
+			f.ops[i] = op{code: opLoad, value: int16(inst)}
+			f.halt[i] = c.haltsAt(i)
+			continue
+		}
+		control := inst >> 6 & 0b111111
+		code, ok := codes[control]
+		if !ok {
+			code = opOther
+		}
+		f.ops[i] = op{
+			code:  code,
+			m:     inst&(1<<12) != 0,
+			dest:  uint8(inst >> 3 & 0b111),
+			jump:  uint8(inst & 0b111),
+			value: int16(control),
+		}
+		f.halt[i] = c.haltsAt(i)
+	}
+	f.halt[c.Size] = true
+	for i := c.Size - 1; i >= 0; i-- {
+		f.ends[i] = i
+		if f.ops[i].jump == 0 && !f.halt[i+1] {
+			f.ends[i] = f.ends[i+1]
+		}
+	}
+
+	return f
+}
+
+// Run steps until the computer halts or ran n instructions, as Computer.Run
+// does.
+func (f *Fast) Run(n uint64) {
+	c := f.c
+	ram := &c.RAM
+	for n > 0 {
+		pc := int(c.PC)
+		if pc >= len(f.ops) || f.halt[pc] {
+			return
+		}
+		end := f.ends[pc]
+		if uint64(end-pc+1) > n {
+			// the cycles left end in the block
+			c.Run(n)
+			return
+		}
+
+		a, d := c.A, c.D
+		next := end + 1
+		for i := pc; i <= end; i++ {
+			o := &f.ops[i]
+			if o.code == opLoad {
+				a = o.value
+				continue
+			}
+			addr := uint16(a) & (hack.RAMSize - 1)
+			y := a
+			if o.m {
+				y = ram[addr]
+			}
+			var out int16
+			switch o.code {
+			case opZero:
+				out = 0
+			case opOne:
+				out = 1
+			case opMinusOne:
+				out = -1
+			case opX:
+				out = d
+			case opY:
+				out = y
+			case opNotX:
+				out = ^d
+			case opNotY:
+				out = ^y
+			case opNegX:
+				out = -d
+			case opNegY:
+				out = -y
+			case opXPlusOne:
+				out = d + 1
+			case opYPlusOne:
+				out = y + 1
+			case opXMinusOne:
+				out = d - 1
+			case opYMinusOne:
+				out = y - 1
+			case opXPlusY:
+				out = d + y
+			case opXMinusY:
+				out = d - y
+			case opYMinusX:
+				out = y - d
+			case opXAndY:
+				out = d & y
+			case opXOrY:
+				out = d | y
+			default:
+				out = ALU(d, y, uint16(o.value))
+			}
+			if o.jump != 0 && (o.jump&0b100 != 0 && out < 0 || o.jump&0b010 != 0 && out == 0 || o.jump&0b001 != 0 && out > 0) {
+				next = int(uint16(a) & (ROMSize - 1))
+			}
+			if o.dest&destM != 0 {
+				ram[addr] = out
+			}
+			if o.dest&destA != 0 {
+				a = out
+			}
+			if o.dest&destD != 0 {
+				d = out
+			}
+		}
+		c.A, c.D, c.PC = a, d, uint16(next)
+		c.Cycles += uint64(end - pc + 1)
+		n -= uint64(end - pc + 1)
+	}
+}
@@ -0,0 +1,613 @@
+// Package debugger is a debugger of Hack machine code in the manner of
+// gdb. It reads commands such as break, watch, step, next, continue, print
+// and disassemble, one per line, and runs them on a computer of package
+// hack/cpu. With the assembly source, it knows the labels and the
+// variables of the program.
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"hack/asm"
+	"hack/cpu"
+)
+
+// Debugger debugs the program of a computer.
+type Debugger struct {
+	Computer *cpu.Computer
+	program  *asm.Program // nil without source
+
+	points    []*point       // breakpoints and watchpoints
+	n         int            // number of the last point
+	labels    map[int]string // label of each ROM address
+	variables map[int]string // variable of each RAM address
+	addresses []int          // addresses with a label, sorted
+	calls     map[uint16]uint16
+	returns   map[uint16]bool
+
+	interrupted atomic.Bool
+	out         io.Writer
+}
+
+// point is a breakpoint, on the instruction at addr, or a watchpoint, on
+// the RAM word at addr.
+type point struct {
+	n     int
+	watch bool
+	addr  int
+	hits  int
+}
+
+// New returns a debugger of c. p is the assembled source of the program
+// of c, or nil.
+func New(c *cpu.Computer, p *asm.Program) *Debugger {
+	d := &Debugger{
+		Computer:  c,
+		program:   p,
+		labels:    make(map[int]string),
+		variables: make(map[int]string),
+		returns:   make(map[uint16]bool),
+		out:       io.Discard,
+	}
+	if p != nil {
+		for name, addr := range p.Labels {
+			if old, ok := d.labels[addr]; !ok || better(name, old) {
+				d.labels[addr] = name
+			}
+		}
+		for addr := range d.labels {
+			d.addresses = append(d.addresses, addr)
+		}
+		sort.Ints(d.addresses)
+
+		predefined := asm.Predefined()
+		for name, addr := range p.Symbols {
+			_, label := p.Labels[name]
+			if _, ok := predefined[name]; !ok && !label {
+				d.variables[addr] = name
+			}
+		}
+	}
+
+	// next steps over the calls of VM functions
+	d.calls = asm.Calls(c.ROM[:c.Size])
+	for _, ret := range d.calls {
+		d.returns[ret] = true
+	}
+
+	return d
+}
+
+// better reports whether label a names an address rather than label b:
+// the labels of functions rather than those in functions.
+func better(a, b string) bool {
+	if strings.Contains(a, "$") != strings.Contains(b, "$") {
+		return !strings.Contains(a, "$")
+	}
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+
+	return a < b
+}
+
+// Interrupt stops the running command at the next instruction. It may be
+// called from another goroutine, on a signal.
+func (d *Debugger) Interrupt() {
+	d.interrupted.Store(true)
+}
+
+// Run runs the commands of in until quit or the end of the input. If
+// script is true, it echoes the commands and stops at the first error,
+// which it returns. Otherwise it prompts for each command, an empty line
+// repeats the last one, and errors are only printed.
+func (d *Debugger) Run(in io.Reader, out io.Writer, script bool) error {
+	d.out = out
+	scanner := bufio.NewScanner(in)
+	last := ""
+	for {
+		if !script {
+			fmt.Fprint(out, "(hdb) ")
+		}
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" && !script {
+			line = last
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		last = line
+		if script {
+			fmt.Fprintf(out, "(hdb) %v\n", line)
+		}
+
+		quit, err := d.Exec(line)
+		if err != nil {
+			if script {
+				return err
+			}
+			fmt.Fprintln(out, err)
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// Exec runs a command and reports whether it is quit.
+func (d *Debugger) Exec(line string) (quit bool, err error) {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return false, nil
+	}
+	name, args := args[0], args[1:]
+	for _, cmd := range commands {
+		if name == cmd.name || name == cmd.alias {
+			if len(args) < cmd.min || len(args) > cmd.max {
+				return false, fmt.Errorf("usage: %v", cmd.usage)
+			}
+			if cmd.name == "quit" {
+				return true, nil
+			}
+			return false, cmd.run(d, args)
+		}
+	}
+
+	return false, fmt.Errorf("unknown command %q, try help", name)
+}
+
+type command struct {
+	name, alias string
+	min, max    int // arguments
+	usage       string
+	help        string
+	run         func(d *Debugger, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"break", "b", 1, 1, "break ADDRESS|LABEL", "stop before the instruction", (*Debugger).breakpoint},
+		{"watch", "w", 1, 1, "watch ADDRESS|VARIABLE", "stop when the RAM word changes", (*Debugger).watchpoint},
+		{"delete", "d", 0, 1, "delete [N]", "delete point N, or all points", (*Debugger).delete},
+		{"info", "i", 1, 1, "info breakpoints|registers", "list the points, or show the registers", (*Debugger).info},
+		{"step", "s", 0, 1, "step [N]", "run N instructions, 1 by default", (*Debugger).step},
+		{"next", "n", 0, 1, "next [N]", "step, over the calls of VM functions", (*Debugger).next},
+		{"continue", "c", 0, 0, "continue", "run until a point stops it or the program halts", (*Debugger).cont},
+		{"print", "p", 1, 1, "print A|D|M|PC|ADDRESS|VARIABLE", "show a register or a RAM word", (*Debugger).print},
+		{"x", "", 1, 2, "x ADDRESS|VARIABLE [N]", "show N RAM words, 8 by default", (*Debugger).examine},
+		{"set", "", 2, 2, "set A|D|M|PC|ADDRESS|VARIABLE VALUE", "change a register or a RAM word, PC may be set to a label", (*Debugger).set},
+		{"disassemble", "l", 0, 2, "disassemble [ADDRESS|LABEL [N]]", "list N instructions, 10 by default, around PC by default", (*Debugger).disassemble},
+		{"reset", "", 0, 0, "reset", "start the program again, the RAM is kept", (*Debugger).reset},
+		{"source", "", 1, 1, "source FILE", "run the commands of a file", (*Debugger).source},
+		{"help", "h", 0, 0, "help", "list the commands", (*Debugger).help},
+		{"quit", "q", 0, 0, "quit", "leave the debugger", nil},
+	}
+}
+
+func (d *Debugger) help([]string) error {
+	for _, cmd := range commands {
+		fmt.Fprintf(d.out, "%-38v %v\n", cmd.usage, cmd.help)
+	}
+	fmt.Fprintln(d.out, "Commands may be abbreviated to their first letter, and numbers may be hexadecimal (0x).")
+
+	return nil
+}
+
+func (d *Debugger) breakpoint(args []string) error {
+	addr, err := d.location(args[0])
+	if err != nil {
+		return err
+	}
+	p := d.add(false, addr)
+	fmt.Fprintf(d.out, "Breakpoint %d at %v\n", p.n, d.where(addr))
+
+	return nil
+}
+
+func (d *Debugger) watchpoint(args []string) error {
+	addr, err := d.address(args[0])
+	if err != nil {
+		return err
+	}
+	p := d.add(true, addr)
+	fmt.Fprintf(d.out, "Watchpoint %d: %v\n", p.n, d.ram(addr))
+
+	return nil
+}
+
+func (d *Debugger) add(watch bool, addr int) *point {
+	d.n++
+	p := &point{n: d.n, watch: watch, addr: addr}
+	d.points = append(d.points, p)
+
+	return p
+}
+
+func (d *Debugger) delete(args []string) error {
+	if len(args) == 0 {
+		d.points = nil
+		return nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid point %v", args[0])
+	}
+	for i, p := range d.points {
+		if p.n == n {
+			d.points = append(d.points[:i], d.points[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no point %d", n)
+}
+
+func (d *Debugger) info(args []string) error {
+	c := d.Computer
+	switch args[0] {
+	case "breakpoints", "b", "watchpoints", "w":
+		if len(d.points) == 0 {
+			fmt.Fprintln(d.out, "No breakpoints or watchpoints.")
+		}
+		for _, p := range d.points {
+			if p.watch {
+				fmt.Fprintf(d.out, "%-3d watch %v, hit %d times\n", p.n, d.ram(p.addr), p.hits)
+			} else {
+				fmt.Fprintf(d.out, "%-3d break %v, hit %d times\n", p.n, d.where(p.addr), p.hits)
+			}
+		}
+	case "registers", "r":
+		fmt.Fprintf(d.out, "A  %6d  0x%04x\n", c.A, uint16(c.A))
+		fmt.Fprintf(d.out, "D  %6d  0x%04x\n", c.D, uint16(c.D))
+		fmt.Fprintf(d.out, "M  %6d  0x%04x\n", c.RAM[uint16(c.A)&0x7fff], uint16(c.RAM[uint16(c.A)&0x7fff]))
+		fmt.Fprintf(d.out, "PC %6d  %v\n", c.PC, d.where(int(c.PC)))
+		for i, name := range []string{"SP", "LCL", "ARG", "THIS", "THAT"} {
+			fmt.Fprintf(d.out, "%-4v %4d\n", name, c.RAM[i])
+		}
+		fmt.Fprintf(d.out, "cycles %d\n", c.Cycles)
+	default:
+		return fmt.Errorf("usage: info breakpoints|registers")
+	}
+
+	return nil
+}
+
+func (d *Debugger) step(args []string) error {
+	n, err := count(args, 1)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if d.run(i > 0) {
+			return nil
+		}
+	}
+	d.show()
+
+	return nil
+}
+
+func (d *Debugger) next(args []string) error {
+	n, err := count(args, 1)
+	if err != nil {
+		return err
+	}
+	c := d.Computer
+	for i := 0; i < n; i++ {
+		// the stack pointer after the call, above its arguments
+		sp := int(c.RAM[0]) + 1
+		ret, ok := d.calls[c.PC]
+		if !ok && d.returns[c.PC+1] && isJump(c.ROM[c.PC&(cpu.ROMSize-1)]) {
+			ret, ok = c.PC+1, true
+			if c.RAM[1] == c.RAM[0] {
+				// the frame is pushed, LCL is set
+				sp -= 5
+			}
+		}
+		if d.run(i > 0) {
+			return nil
+		}
+		for ok && (c.PC != ret || int(c.RAM[0]) > sp) {
+			if d.run(true) {
+				return nil
+			}
+		}
+	}
+	d.show()
+
+	return nil
+}
+
+func isJump(inst uint16) bool {
+	return inst&(1<<15) != 0 && inst&0b111 != 0
+}
+
+func (d *Debugger) cont([]string) error {
+	for first := true; ; first = false {
+		if d.run(!first) {
+			return nil
+		}
+	}
+}
+
+// run runs an instruction and reports whether the debugger stopped: on a
+// breakpoint before it, unless check is false, or a watchpoint after it,
+// or because the program halted or was interrupted. The stop is printed.
+func (d *Debugger) run(check bool) bool {
+	c := d.Computer
+	if d.interrupted.Swap(false) {
+		fmt.Fprintln(d.out, "Interrupted.")
+		d.show()
+		return true
+	}
+	if check {
+		if c.Halted() {
+			fmt.Fprintf(d.out, "Halted after %d cycles.\n", c.Cycles)
+			d.show()
+			return true
+		}
+		for _, p := range d.points {
+			if !p.watch && p.addr == int(c.PC) {
+				p.hits++
+				fmt.Fprintf(d.out, "Breakpoint %d, ", p.n)
+				d.show()
+				return true
+			}
+		}
+	}
+
+	var old int16
+	if i := uint16(c.A) & 0x7fff; c.ROM[c.PC&(cpu.ROMSize-1)]&(1<<15) != 0 {
+		old = c.RAM[i]
+	}
+	addr, wrote := c.Step()
+	if !wrote {
+		return false
+	}
+	stop := false
+	for _, p := range d.points {
+		if p.watch && p.addr == addr && c.RAM[addr] != old {
+			p.hits++
+			fmt.Fprintf(d.out, "Watchpoint %d: %v, was %d\n", p.n, d.ram(addr), old)
+			stop = true
+		}
+	}
+	if stop {
+		d.show()
+	}
+
+	return stop
+}
+
+// show prints the next instruction.
+func (d *Debugger) show() {
+	c := d.Computer
+	fmt.Fprintf(d.out, "%v: %v\n", d.where(int(c.PC)), asm.Disassemble(c.ROM[c.PC&(cpu.ROMSize-1)]))
+}
+
+func (d *Debugger) print(args []string) error {
+	c := d.Computer
+	var v int
+	switch strings.ToUpper(args[0]) {
+	case "A":
+		v = int(c.A)
+	case "D":
+		v = int(c.D)
+	case "M":
+		v = int(c.RAM[uint16(c.A)&0x7fff])
+	case "PC":
+		fmt.Fprintf(d.out, "PC = %v\n", d.where(int(c.PC)))
+		return nil
+	default:
+		addr, err := d.address(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(d.out, d.ram(addr))
+		return nil
+	}
+	fmt.Fprintf(d.out, "%v = %d\n", strings.ToUpper(args[0]), v)
+
+	return nil
+}
+
+func (d *Debugger) examine(args []string) error {
+	addr, err := d.address(args[0])
+	if err != nil {
+		return err
+	}
+	n, err := count(args[1:], 8)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n && addr+i < len(d.Computer.RAM); i++ {
+		if i%8 == 0 {
+			if i > 0 {
+				fmt.Fprintln(d.out)
+			}
+			fmt.Fprintf(d.out, "RAM[%d]:", addr+i)
+		}
+		fmt.Fprintf(d.out, " %6d", d.Computer.RAM[addr+i])
+	}
+	fmt.Fprintln(d.out)
+
+	return nil
+}
+
+func (d *Debugger) set(args []string) error {
+	c := d.Computer
+	if strings.ToUpper(args[0]) == "PC" {
+		addr, err := d.location(args[1])
+		if err != nil {
+			return err
+		}
+		c.PC = uint16(addr)
+		return nil
+	}
+	v, err := strconv.ParseInt(args[1], 0, 32)
+	if err != nil || v < -1<<15 || v >= 1<<16 {
+		return fmt.Errorf("invalid value %v", args[1])
+	}
+	switch strings.ToUpper(args[0]) {
+	case "A":
+		c.A = int16(v)
+	case "D":
+		c.D = int16(v)
+	case "M":
+		c.RAM[uint16(c.A)&0x7fff] = int16(v)
+	default:
+		addr, err := d.address(args[0])
+		if err != nil {
+			return err
+		}
+		c.RAM[addr] = int16(v)
+	}
+
+	return nil
+}
+
+func (d *Debugger) disassemble(args []string) error {
+	c := d.Computer
+	start := max(int(c.PC)-3, 0)
+	if len(args) > 0 {
+		addr, err := d.location(args[0])
+		if err != nil {
+			return err
+		}
+		start = addr
+	}
+	n, err := count(args[min(len(args), 1):], 10)
+	if err != nil {
+		return err
+	}
+
+	for addr := start; addr < start+n && addr < cpu.ROMSize; addr++ {
+		if label, ok := d.labels[addr]; ok {
+			fmt.Fprintf(d.out, "(%v)\n", label)
+		}
+		mark := "  "
+		if addr == int(c.PC) {
+			mark = "=>"
+		}
+		for _, p := range d.points {
+			if !p.watch && p.addr == addr {
+				mark = mark[:1] + "*"
+			}
+		}
+		line := ""
+		if d.program != nil && addr < len(d.program.Lines) {
+			line = fmt.Sprintf("  // line %d", d.program.Lines[addr])
+		}
+		fmt.Fprintf(d.out, "%v %5d  %-12v%v\n", mark, addr, asm.Disassemble(c.ROM[addr]), line)
+	}
+
+	return nil
+}
+
+func (d *Debugger) reset([]string) error {
+	d.Computer.Reset()
+	d.show()
+
+	return nil
+}
+
+func (d *Debugger) source(args []string) error {
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return d.Run(f, d.out, true)
+}
+
+// location returns the ROM address of a number or a label.
+func (d *Debugger) location(arg string) (int, error) {
+	if n, err := strconv.ParseInt(arg, 0, 32); err == nil {
+		if n < 0 || n >= cpu.ROMSize {
+			return 0, fmt.Errorf("address %v out of range", arg)
+		}
+		return int(n), nil
+	}
+	if d.program != nil {
+		if addr, ok := d.program.Labels[arg]; ok {
+			return addr, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no label %v", arg)
+}
+
+// address returns the RAM address of a number, RAM[number], or a symbol
+// that is not a label.
+func (d *Debugger) address(arg string) (int, error) {
+	s := arg
+	if strings.HasPrefix(s, "RAM[") && strings.HasSuffix(s, "]") {
+		s = s[4 : len(s)-1]
+	}
+	if n, err := strconv.ParseInt(s, 0, 32); err == nil {
+		if n < 0 || n >= int64(len(d.Computer.RAM)) {
+			return 0, fmt.Errorf("address %v out of range", arg)
+		}
+		return int(n), nil
+	}
+	if d.program != nil {
+		if _, ok := d.program.Labels[arg]; ok {
+			return 0, fmt.Errorf("%v is a label", arg)
+		}
+		if addr, ok := d.program.Symbols[arg]; ok {
+			return addr, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no variable %v", arg)
+}
+
+// where returns a ROM address with the label before it, as 12 <LOOP+2>.
+func (d *Debugger) where(addr int) string {
+	i := sort.SearchInts(d.addresses, addr+1) - 1
+	if i < 0 {
+		return strconv.Itoa(addr)
+	}
+	label := d.labels[d.addresses[i]]
+	if off := addr - d.addresses[i]; off > 0 {
+		return fmt.Sprintf("%d <%v+%d>", addr, label, off)
+	}
+
+	return fmt.Sprintf("%d <%v>", addr, label)
+}
+
+// ram returns a RAM word with its address and its variable, as
+// RAM[16] <i> = 3.
+func (d *Debugger) ram(addr int) string {
+	if name, ok := d.variables[addr]; ok {
+		return fmt.Sprintf("RAM[%d] <%v> = %d", addr, name, d.Computer.RAM[addr])
+	}
+
+	return fmt.Sprintf("RAM[%d] = %d", addr, d.Computer.RAM[addr])
+}
+
+// count returns the number in args, or def if there is none.
+func count(args []string, def int) (int, error) {
+	if len(args) == 0 {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(args[0], 0, 32)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid count %v", args[0])
+	}
+
+	return int(n), nil
+}
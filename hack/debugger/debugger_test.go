@@ -0,0 +1,216 @@
+package debugger_test
+
+import (
+	"strings"
+	"testing"
+
+	"hack/asm"
+	"hack/cpu"
+	"hack/debugger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// program calls a function that doubles the word on the stack twice, as
+// the VM translator calls functions, and stores the result in x.
+const program = `
+	@256
+	D=A
+	@SP
+	M=D
+	@3
+	D=A
+	@SP
+	AM=M+1
+	A=A-1
+	M=D
+	@RETURN_ADDR_double_0
+	D=A
+	@SP
+	AM=M+1
+	A=A-1
+	M=D
+	@double
+	0;JMP
+(RETURN_ADDR_double_0)
+	@RETURN_ADDR_double_1
+	D=A
+	@SP
+	AM=M+1
+	A=A-1
+	M=D
+	@double
+	0;JMP
+(RETURN_ADDR_double_1)
+	@SP
+	A=M-1
+	D=M
+	@x
+	M=D
+(END)
+	@END
+	0;JMP
+(double)
+	@SP
+	AM=M-1
+	D=M
+	@R13
+	M=D
+	@SP
+	A=M-1
+	D=M
+	M=D+M
+	@R13
+	A=M
+	0;JMP
+`
+
+func TestDebugger_Run(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{
+			"1. breakpoints",
+			"break double\ninfo breakpoints\ncontinue\nprint D\ncontinue\nx 256 2\ndelete 1\ncontinue\nprint x",
+			`(hdb) break double
+Breakpoint 1 at 33 <double>
+(hdb) info breakpoints
+1   break 33 <double>, hit 0 times
+(hdb) continue
+Breakpoint 1, 33 <double>: @0
+(hdb) print D
+D = 18
+(hdb) continue
+Breakpoint 1, 33 <double>: @0
+(hdb) x 256 2
+RAM[256]:      6     26
+(hdb) delete 1
+(hdb) continue
+Halted after 55 cycles.
+31 <END>: @31
+(hdb) print x
+RAM[16] <x> = 12
+`,
+		},
+		{
+			"2. watchpoints",
+			"watch x\nwatch 256\ncontinue\ncontinue\ncontinue\ninfo b",
+			`(hdb) watch x
+Watchpoint 1: RAM[16] <x> = 0
+(hdb) watch 256
+Watchpoint 2: RAM[256] = 0
+(hdb) continue
+Watchpoint 2: RAM[256] = 3, was 0
+10: @18
+(hdb) continue
+Watchpoint 2: RAM[256] = 6, was 3
+42 <double+9>: @13
+(hdb) continue
+Watchpoint 2: RAM[256] = 12, was 6
+42 <double+9>: @13
+(hdb) info b
+1   watch RAM[16] <x> = 0, hit 0 times
+2   watch RAM[256] = 12, hit 3 times
+`,
+		},
+		{
+			"3. next over calls",
+			"step 10\nnext\nnext\nprint RAM[256]\nreset\nstep 16\nnext\nnext\nprint RAM[256]\nbreak double\nnext\ndisassemble double 3",
+			`(hdb) step 10
+10: @18
+(hdb) next
+18 <RETURN_ADDR_double_0>: @26
+(hdb) next
+26 <RETURN_ADDR_double_1>: @0
+(hdb) print RAM[256]
+RAM[256] = 12
+(hdb) reset
+0: @256
+(hdb) step 16
+16: @33
+(hdb) next
+17: 0;JMP
+(hdb) next
+18 <RETURN_ADDR_double_0>: @26
+(hdb) print RAM[256]
+RAM[256] = 6
+(hdb) break double
+Breakpoint 1 at 33 <double>
+(hdb) next
+Breakpoint 1, 33 <double>: @0
+(hdb) disassemble double 3
+(double)
+=*    33  @0            // line 39
+      34  AM=M-1        // line 40
+      35  D=M           // line 41
+`,
+		},
+		{
+			"4. registers",
+			"set PC double\nset A 300\nset M -2\nset D 0x10\ninfo registers\nstep\nprint A",
+			`(hdb) set PC double
+(hdb) set A 300
+(hdb) set M -2
+(hdb) set D 0x10
+(hdb) info registers
+A     300  0x012c
+D      16  0x0010
+M      -2  0xfffe
+PC     33  33 <double>
+SP      0
+LCL     0
+ARG     0
+THIS    0
+THAT    0
+cycles 0
+(hdb) step
+34 <double+1>: AM=M-1
+(hdb) print A
+A = 0
+`,
+		},
+		{
+			"5. errors stop the script",
+			"break LOOP\nprint x",
+			"(hdb) break LOOP\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := asm.Assemble([]byte(program))
+			assert.NoError(t, err)
+			d := debugger.New(cpu.New(p.Code), p)
+
+			var out strings.Builder
+			err = d.Run(strings.NewReader(tt.script), &out, true)
+			if strings.HasPrefix(tt.name, "5.") {
+				assert.EqualError(t, err, "no label LOOP")
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func TestDebugger_Interactive(t *testing.T) {
+	d := debugger.New(cpu.New([]uint16{1, 2, 3}), nil)
+
+	var out strings.Builder
+	err := d.Run(strings.NewReader("step\n\nfoo\nl 0 2\nquit\nstep\n"), &out, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "(hdb) 1: @2\n(hdb) 2: @3\n(hdb) unknown command \"foo\", try help\n"+
+		"(hdb)        0  @1          \n       1  @2          \n(hdb) ", out.String())
+}
+
+func TestDebugger_FullROM(t *testing.T) {
+	// past the end of a full ROM, the instructions are those at its start
+	d := debugger.New(cpu.New(make([]uint16, cpu.ROMSize)), nil)
+
+	var out strings.Builder
+	err := d.Run(strings.NewReader("step 32768\nnext\nstep\n"), &out, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "(hdb) step 32768\n32768: @0\n(hdb) next\n32769: @0\n(hdb) step\n32770: @0\n", out.String())
+}
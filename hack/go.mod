@@ -0,0 +1,15 @@
+module hack
+
+go 1.22.5
+
+require (
+	github.com/stretchr/testify v1.9.0
+	golang.org/x/term v0.22.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.22.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
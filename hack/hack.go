@@ -0,0 +1,34 @@
+// Package hack describes the memory of the Hack computer, as it is used by
+// the emulator of its machine code (package hack/cpu) and the emulator of
+// the VM language (package hack/vmemu).
+package hack
+
+// The RAM is 32K words of 16 bits. The VM translator lays it out as
+// follows, the first five words are the pointers of the VM segments.
+const (
+	SP   = 0 // stack pointer
+	LCL  = 1 // base of the local segment
+	ARG  = 2 // base of the argument segment
+	THIS = 3 // base of the this segment, pointer 0
+	THAT = 4 // base of the that segment, pointer 1
+
+	Temp   = 5     // temp segment, 8 words
+	Static = 16    // static variables, up to 255
+	Stack  = 256   // stack, up to 2047
+	Heap   = 2048  // heap, up to 16383
+	Screen = 16384 // screen memory map, 8K words
+	KBD    = 24576 // keyboard memory map
+
+	RAMSize = 32768
+)
+
+// The ROM holds up to 32K instructions.
+const ROMSize = 32768
+
+// The screen has 256 rows of 512 pixels, 32 words per row. The least
+// significant bit of a word is its leftmost pixel, and 1 is black.
+const (
+	ScreenWidth  = 512
+	ScreenHeight = 256
+	ScreenWords  = ScreenWidth / 16 * ScreenHeight
+)
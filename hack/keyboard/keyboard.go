@@ -0,0 +1,184 @@
+// Package keyboard feeds the keyboard memory map of the Hack computer from
+// a timeline of key events, to replay a session of a program in the
+// emulators of package hack/cpu and hack/vmemu.
+//
+// A timeline has one event per line: a time, press or release, and a key.
+//
+//	# move right for a while, then quit
+//	100000   press   right
+//	400000   release right
+//	20f      press   q
+//	21f      release
+//
+// A time is a cycle of the emulator, or a frame if it ends with f. Keys are
+// printable characters, space, the names of the special keys or their
+// codes. Letters are as written, the Hack keyboard gives upper case ones.
+// A release without a key releases any key. Lines starting with # are
+// comments.
+package keyboard
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"hack"
+)
+
+// The codes of the special keys of the Hack keyboard.
+const (
+	Newline   = 128
+	Backspace = 129
+	Left      = 130
+	Up        = 131
+	Right     = 132
+	Down      = 133
+	Home      = 134
+	End       = 135
+	PageUp    = 136
+	PageDown  = 137
+	Insert    = 138
+	Delete    = 139
+	Esc       = 140
+	F1        = 141 // to F12, 152
+)
+
+// Keys are the names of the special keys.
+var Keys = map[string]int16{
+	"space":     ' ',
+	"newline":   Newline,
+	"enter":     Newline,
+	"backspace": Backspace,
+	"left":      Left,
+	"up":        Up,
+	"right":     Right,
+	"down":      Down,
+	"home":      Home,
+	"end":       End,
+	"pageup":    PageUp,
+	"pagedown":  PageDown,
+	"insert":    Insert,
+	"delete":    Delete,
+	"esc":       Esc,
+}
+
+func init() {
+	for i := 0; i < 12; i++ {
+		Keys["f"+strconv.Itoa(i+1)] = int16(F1 + i)
+	}
+}
+
+// Event presses or releases a key at a cycle.
+type Event struct {
+	Cycle uint64
+	Press bool
+	Key   int16 // 0 for any key on release
+	Line  int
+}
+
+// Timeline is a sequence of events in time order.
+type Timeline struct {
+	Events []Event
+	next   int
+}
+
+// Parse reads the timeline of src, whose file name is name. A frame is
+// frameCycles cycles.
+func Parse(name string, src []byte, frameCycles uint64) (*Timeline, error) {
+	t := &Timeline{}
+	for i, line := range strings.Split(string(src), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		e, err := parseEvent(fields, frameCycles)
+		if err != nil {
+			return nil, fmt.Errorf("%v:%d: %v", name, i+1, err)
+		}
+		if n := len(t.Events); n > 0 && e.Cycle < t.Events[n-1].Cycle {
+			return nil, fmt.Errorf("%v:%d: event before the previous one", name, i+1)
+		}
+		e.Line = i + 1
+		t.Events = append(t.Events, e)
+	}
+
+	return t, nil
+}
+
+func parseEvent(fields []string, frameCycles uint64) (Event, error) {
+	if len(fields) < 2 || len(fields) > 3 {
+		return Event{}, fmt.Errorf("want a time, press or release, and a key")
+	}
+
+	var e Event
+	time, frames := strings.CutSuffix(fields[0], "f")
+	n, err := strconv.ParseUint(time, 10, 64)
+	if err != nil {
+		return Event{}, fmt.Errorf("invalid time %q", fields[0])
+	}
+	e.Cycle = n
+	if frames {
+		e.Cycle = n * frameCycles
+	}
+
+	switch fields[1] {
+	case "press":
+		e.Press = true
+		if len(fields) < 3 {
+			return Event{}, fmt.Errorf("press without a key")
+		}
+	case "release":
+	default:
+		return Event{}, fmt.Errorf("want press or release, not %q", fields[1])
+	}
+	if len(fields) == 3 {
+		if e.Key, err = ParseKey(fields[2]); err != nil {
+			return Event{}, err
+		}
+	}
+
+	return e, nil
+}
+
+// ParseKey returns the code of a key: a printable character, the name of a
+// key, or a code.
+func ParseKey(s string) (int16, error) {
+	if code, ok := Keys[strings.ToLower(s)]; ok {
+		return code, nil
+	}
+	if len(s) == 1 && s[0] > ' ' && s[0] < 127 {
+		return int16(s[0]), nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && n > 0 && n < 1<<15 {
+		return int16(n), nil
+	}
+
+	return 0, fmt.Errorf("unknown key %q", s)
+}
+
+// Apply applies the events up to cycle to ram, the RAM of an emulator.
+func (t *Timeline) Apply(cycle uint64, ram []int16) {
+	for ; t.next < len(t.Events) && t.Events[t.next].Cycle <= cycle; t.next++ {
+		e := t.Events[t.next]
+		switch {
+		case e.Press:
+			ram[hack.KBD] = e.Key
+		case e.Key == 0 || ram[hack.KBD] == e.Key:
+			ram[hack.KBD] = 0
+		}
+	}
+}
+
+// Done reports whether all the events were applied.
+func (t *Timeline) Done() bool {
+	return t.next == len(t.Events)
+}
+
+// Next returns the cycle of the next event to apply, if there is one.
+func (t *Timeline) Next() (uint64, bool) {
+	if t.Done() {
+		return 0, false
+	}
+
+	return t.Events[t.next].Cycle, true
+}
@@ -0,0 +1,76 @@
+package keyboard_test
+
+import (
+	"testing"
+
+	"hack"
+	"hack/keyboard"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeline(t *testing.T) {
+	timeline, err := keyboard.Parse("keys.txt", []byte(`
+# comment
+10 press right
+20 release left
+30 release right
+2f press a
+2f release a
+3f press 133
+4f press space
+5f release
+`), 100)
+	assert.NoError(t, err)
+
+	var ram [hack.RAMSize]int16
+	want := map[uint64]int16{
+		0:   0,
+		10:  keyboard.Right,
+		25:  keyboard.Right, // another key is released
+		30:  0,
+		200: 0,
+		300: keyboard.Down,
+		400: ' ',
+		499: ' ',
+		500: 0,
+	}
+	next := map[uint64]uint64{0: 10, 10: 20, 25: 30, 30: 200, 200: 300, 300: 400, 400: 500, 499: 500}
+	for _, cycle := range []uint64{0, 10, 25, 30, 200, 300, 400, 499, 500} {
+		timeline.Apply(cycle, ram[:])
+		assert.Equal(t, want[cycle], ram[hack.KBD], "cycle %d", cycle)
+		n, ok := timeline.Next()
+		assert.Equal(t, next[cycle], n, "cycle %d", cycle)
+		assert.Equal(t, cycle < 500, ok)
+	}
+	assert.True(t, timeline.Done())
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"1. unknown key", "1 press foo", `keys.txt:1: unknown key "foo"`},
+		{"2. invalid time", "\n1x press a", `keys.txt:2: invalid time "1x"`},
+		{"3. invalid action", "1 hold a", `keys.txt:1: want press or release, not "hold"`},
+		{"4. press without a key", "1 press", "keys.txt:1: press without a key"},
+		{"5. out of order", "10 press a\n1f release", "keys.txt:2: event before the previous one"},
+		{"6. too many fields", "1 press a b", "keys.txt:1: want a time, press or release, and a key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := keyboard.Parse("keys.txt", []byte(tt.src), 5)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	for s, want := range map[string]int16{"a": 'a', "Q": 'Q', "1": '1', "Enter": keyboard.Newline, "f12": 152, "esc": 140, "200": 200} {
+		got, err := keyboard.ParseKey(s)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, s)
+	}
+}
@@ -0,0 +1,133 @@
+// Package lockstep runs the computer of package hack/cpu in lockstep with
+// a reference implementation of the Hack computer, as the CPU.hdl and
+// Computer.hdl of project 5 run by an HDL simulator, and stops at the first
+// cycle where they diverge: on the PC, A or D after the cycle, or on the
+// RAM word that it writes.
+//
+// The reference gives the state after each of its cycles. A trace of
+// package hack/trace is one, so a simulator that writes its cycles in that
+// format can be compared with the emulator.
+package lockstep
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"hack/asm"
+	"hack/cpu"
+	"hack/trace"
+)
+
+// Cycle is the state of the computer after a cycle, and the RAM word that
+// the cycle wrote.
+type Cycle struct {
+	PC    uint16 // of the next instruction
+	A, D  int16
+	Write bool
+	Addr  uint16 // of the write
+	Value int16  // written
+}
+
+func (c Cycle) write() string {
+	if !c.Write {
+		return "no write"
+	}
+
+	return fmt.Sprintf("RAM[%d] = %d", c.Addr, c.Value)
+}
+
+// Reference is the implementation the emulator is compared with. Next
+// returns the state after its next cycle, or io.EOF after the last one.
+type Reference interface {
+	Next() (Cycle, error)
+}
+
+// Divergence is the first cycle where the emulator and the reference
+// differ.
+type Divergence struct {
+	Cycle     uint64 // from 1
+	PC, Inst  uint16 // the instruction of the cycle
+	Got, Want Cycle  // of the emulator, of the reference
+}
+
+func (d *Divergence) Error() string {
+	var diffs []string
+	if d.Got.A != d.Want.A {
+		diffs = append(diffs, fmt.Sprintf("A %d, reference %d", d.Got.A, d.Want.A))
+	}
+	if d.Got.D != d.Want.D {
+		diffs = append(diffs, fmt.Sprintf("D %d, reference %d", d.Got.D, d.Want.D))
+	}
+	if d.Got.write() != d.Want.write() {
+		diffs = append(diffs, fmt.Sprintf("%v, reference %v", d.Got.write(), d.Want.write()))
+	}
+	if d.Got.PC != d.Want.PC {
+		diffs = append(diffs, fmt.Sprintf("PC %d, reference %d", d.Got.PC, d.Want.PC))
+	}
+
+	return fmt.Sprintf("cycle %d, ROM[%d] %v: %v", d.Cycle, d.PC, asm.Disassemble(d.Inst), strings.Join(diffs, "; "))
+}
+
+// Compare runs c and ref in lockstep until ref ends, or for n cycles if
+// n > 0, and returns the cycles that they ran alike. The error is a
+// *Divergence at the first cycle where they differ, or that of ref.
+func Compare(c *cpu.Computer, ref Reference, n uint64) (uint64, error) {
+	var cycles uint64
+	for n == 0 || cycles < n {
+		want, err := ref.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return cycles, err
+		}
+		if !want.Write {
+			want.Addr, want.Value = 0, 0
+		}
+
+		pc, inst := c.PC, c.ROM[c.PC&(cpu.ROMSize-1)]
+		addr, wrote := c.Step()
+		got := Cycle{PC: c.PC, A: c.A, D: c.D, Write: wrote}
+		if wrote {
+			got.Addr, got.Value = uint16(addr), c.RAM[addr]
+		}
+		if got != want {
+			return cycles, &Divergence{Cycle: c.Cycles, PC: pc, Inst: inst, Got: got, Want: want}
+		}
+		cycles++
+	}
+
+	return cycles, nil
+}
+
+// traceReference replays the records of a trace.
+type traceReference struct {
+	t    *trace.Trace
+	next int
+}
+
+// Trace returns a reference that replays t, which must start at the first
+// cycle of its program.
+func Trace(t *trace.Trace) (Reference, error) {
+	if t.First() != 0 {
+		return nil, errors.New("the trace does not start at the first cycle")
+	}
+
+	return &traceReference{t: t}, nil
+}
+
+func (r *traceReference) Next() (Cycle, error) {
+	if r.next == len(r.t.Records) {
+		return Cycle{}, io.EOF
+	}
+	rec := r.t.Records[r.next]
+	r.next++
+	pc := r.t.End.PC
+	if r.next < len(r.t.Records) {
+		pc = r.t.Records[r.next].PC
+	}
+
+	return Cycle{PC: pc, A: rec.A, D: rec.D, Write: rec.Write, Addr: rec.Addr, Value: rec.New}, nil
+}
@@ -0,0 +1,87 @@
+package lockstep_test
+
+import (
+	"bytes"
+	"testing"
+
+	"hack/asm"
+	"hack/cpu"
+	"hack/lockstep"
+	"hack/trace"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const add = `
+	@2
+	D=A
+	@3
+	D=D+A
+	@0
+	M=D
+(END)
+	@END
+	0;JMP
+`
+
+// record returns a trace of the first 10 cycles of p, the last n only if
+// n > 0.
+func record(t *testing.T, p *asm.Program, n int) *trace.Trace {
+	t.Helper()
+	var b bytes.Buffer
+	c := cpu.New(p.Code)
+	r := trace.New(c, &b, n)
+	for i := 0; i < 10; i++ {
+		r.Step()
+	}
+	assert.NoError(t, r.Close())
+	tr, err := trace.Read(&b)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return tr
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(tr *trace.Trace)
+		n      uint64
+		cycles uint64
+		want   string
+	}{
+		{"1. same", func(tr *trace.Trace) {}, 0, 10, ""},
+		{"2. first cycles", func(tr *trace.Trace) { tr.Records[5].D = 7 }, 4, 4, ""},
+		{"3. D", func(tr *trace.Trace) { tr.Records[3].D = 6 }, 0, 3, "cycle 4, ROM[3] D=D+A: D 5, reference 6"},
+		{"4. write", func(tr *trace.Trace) { tr.Records[5].Addr = 1 }, 0, 5, "cycle 6, ROM[5] M=D: RAM[0] = 5, reference RAM[1] = 5"},
+		{"5. no write", func(tr *trace.Trace) { tr.Records[5].Write = false }, 0, 5, "cycle 6, ROM[5] M=D: RAM[0] = 5, reference no write"},
+		{"6. jump", func(tr *trace.Trace) { tr.Records[8].PC = 8 }, 0, 7, "cycle 8, ROM[7] 0;JMP: PC 6, reference 8"},
+		{"7. several", func(tr *trace.Trace) { tr.Records[0].A, tr.Records[1].PC = 3, 0 }, 0, 0, "cycle 1, ROM[0] @2: A 2, reference 3; PC 1, reference 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := asm.Assemble([]byte(add))
+			assert.NoError(t, err)
+			tr := record(t, p, 0)
+			tt.change(tr)
+			ref, err := lockstep.Trace(tr)
+			assert.NoError(t, err)
+
+			cycles, err := lockstep.Compare(cpu.New(p.Code), ref, tt.n)
+			assert.Equal(t, tt.cycles, cycles)
+			if tt.want == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.want)
+				assert.IsType(t, &lockstep.Divergence{}, err)
+			}
+		})
+	}
+
+	// the last cycles of a run do not start from the state of the computer
+	p, err := asm.Assemble([]byte(add))
+	assert.NoError(t, err)
+	_, err = lockstep.Trace(record(t, p, 4))
+	assert.EqualError(t, err, "the trace does not start at the first cycle")
+}
@@ -0,0 +1,160 @@
+package profile
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+)
+
+// WritePprof writes the profile to w in the format of pprof: a gzipped
+// profile.proto message, whose samples count cycles. The address of a
+// location is its index, the ROM address of machine code.
+func (p *Profile) WritePprof(w io.Writer) error {
+	index := map[string]int{"": 0}
+	table := []string{""}
+	str := func(s string) uint64 {
+		i, ok := index[s]
+		if !ok {
+			i = len(table)
+			index[s] = i
+			table = append(table, s)
+		}
+		return uint64(i)
+	}
+
+	var b buffer
+	cycles, count := str("cycles"), str("count")
+	valueType := func(b *buffer) {
+		b.uint64(1, cycles)
+		b.uint64(2, count)
+	}
+	b.message(1, valueType) // sample_type
+
+	samples := make([]sample, 0, len(p.samples))
+	for s := range p.samples {
+		samples = append(samples, s)
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].node != samples[j].node {
+			return samples[i].node < samples[j].node
+		}
+		return samples[i].loc < samples[j].loc
+	})
+	used := make(map[int32]bool)
+	for _, s := range samples {
+		stack := p.stack(s)
+		ids := make([]uint64, len(stack))
+		for i, loc := range stack {
+			ids[i] = uint64(loc) + 1
+			used[loc] = true
+		}
+		b.message(2, func(b *buffer) { // sample
+			b.packed(1, ids)
+			b.packed(2, []uint64{p.samples[s]})
+		})
+	}
+
+	b.message(3, func(b *buffer) { // mapping
+		b.uint64(1, 1)
+		b.uint64(3, uint64(len(p.Locations)))
+		b.uint64(5, str(p.Name))
+		b.uint64(7, 1)
+		b.uint64(8, 1)
+		b.uint64(9, 1)
+	})
+
+	type function struct{ name, file string }
+	functions := make(map[function]uint64)
+	var order []function
+	for loc := range p.Locations {
+		if !used[int32(loc)] {
+			continue
+		}
+		l := p.Locations[loc]
+		f := function{l.Function, l.File}
+		id, ok := functions[f]
+		if !ok {
+			id = uint64(len(functions) + 1)
+			functions[f] = id
+			order = append(order, f)
+		}
+		b.message(4, func(b *buffer) { // location
+			b.uint64(1, uint64(loc)+1)
+			b.uint64(2, 1)
+			b.uint64(3, uint64(loc))
+			b.message(4, func(b *buffer) { // line
+				b.uint64(1, id)
+				b.uint64(2, uint64(l.Line))
+			})
+		})
+	}
+	for _, f := range order {
+		b.message(5, func(b *buffer) { // function
+			b.uint64(1, functions[f])
+			b.uint64(2, str(f.name))
+			b.uint64(3, str(f.name))
+			b.uint64(4, str(f.file))
+		})
+	}
+
+	b.message(11, valueType) // period_type
+	b.uint64(12, 1)          // period
+	for _, s := range table {
+		b.bytes(6, []byte(s)) // string_table
+	}
+
+	z := gzip.NewWriter(w)
+	if _, err := z.Write(b.data); err != nil {
+		return err
+	}
+
+	return z.Close()
+}
+
+// buffer encodes a protocol buffer message, with the fields of profile.proto
+// that WritePprof writes: varints, packed varints, strings and messages.
+type buffer struct {
+	data []byte
+}
+
+func (b *buffer) varint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+// key encodes the key of a field, with wire type 0 for varints and 2 for
+// the others.
+func (b *buffer) key(field, wire int) {
+	b.varint(uint64(field<<3 | wire))
+}
+
+// uint64 encodes a varint field, unless it is 0.
+func (b *buffer) uint64(field int, x uint64) {
+	if x != 0 {
+		b.key(field, 0)
+		b.varint(x)
+	}
+}
+
+func (b *buffer) bytes(field int, data []byte) {
+	b.key(field, 2)
+	b.varint(uint64(len(data)))
+	b.data = append(b.data, data...)
+}
+
+func (b *buffer) packed(field int, xs []uint64) {
+	var m buffer
+	for _, x := range xs {
+		m.varint(x)
+	}
+	b.bytes(field, m.data)
+}
+
+func (b *buffer) message(field int, encode func(*buffer)) {
+	var m buffer
+	encode(&m)
+	b.bytes(field, m.data)
+}
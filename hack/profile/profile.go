@@ -0,0 +1,397 @@
+// Package profile counts the cycles that a program spends at each of its
+// locations, in each of its functions and in each call between them, in
+// the emulators of package hack/cpu and hack/vmemu. The functions of
+// machine code are those of the VM code that it was translated from, after
+// the labels of the VM translator.
+//
+// A profile is written as a report of text, or in the format of pprof:
+//
+//	go tool pprof -top profile.pb.gz
+package profile
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"hack"
+	"hack/asm"
+	"hack/cpu"
+	"hack/vmemu"
+)
+
+// Location is a ROM address of machine code, or a command of VM code.
+type Location struct {
+	Function string // "(none)" outside the functions
+	Entry    bool   // the start of its function
+	File     string
+	Line     int
+	Text     string // the instruction or the command
+}
+
+// none is the function of the locations outside the functions.
+const none = "(none)"
+
+// Profile is the cycles of a run, by location and by stack of calls.
+type Profile struct {
+	Name      string // of the program
+	Locations []Location
+	Counts    []uint64 // cycles at each location
+	Total     uint64
+
+	frames  []frame // the calls being run, innermost last
+	nodes   []node  // the stacks of calls, a tree from nodes[0]
+	index   map[node]int32
+	callee  []int32  // entry of the function called by each node, or -1
+	calls   []uint64 // calls of each node
+	samples map[sample]uint64
+}
+
+// node is a stack of calls: the stack of the parent node, with a call at
+// location site.
+type node struct {
+	parent, site int32
+}
+
+// frame is a call being run, which returns to location ret. sp is the
+// stack pointer at the call: a return leaves it at most one above, with
+// the value returned in place of the arguments.
+type frame struct {
+	node, ret, sp int32
+}
+
+// sample is a location run with a stack of calls.
+type sample struct {
+	node, loc int32
+}
+
+func newProfile(name string, locs []Location) *Profile {
+	return &Profile{
+		Name:      name,
+		Locations: locs,
+		Counts:    make([]uint64, len(locs)),
+		nodes:     []node{{-1, -1}},
+		index:     make(map[node]int32),
+		callee:    []int32{-1},
+		calls:     []uint64{0},
+		samples:   make(map[sample]uint64),
+	}
+}
+
+// current returns the node of the stack of calls being run.
+func (p *Profile) current() int32 {
+	if len(p.frames) == 0 {
+		return 0
+	}
+
+	return p.frames[len(p.frames)-1].node
+}
+
+// count counts a cycle at location loc.
+func (p *Profile) count(loc int) {
+	p.Counts[loc]++
+	p.Total++
+	n := p.current()
+	p.samples[sample{n, int32(loc)}]++
+	if p.callee[n] < 0 && p.Locations[loc].Entry {
+		p.callee[n] = int32(loc)
+	}
+}
+
+// call enters a call at location site, which returns to location ret,
+// with stack pointer sp.
+func (p *Profile) call(site, ret, sp int) {
+	key := node{p.current(), int32(site)}
+	n, ok := p.index[key]
+	if !ok {
+		n = int32(len(p.nodes))
+		p.nodes = append(p.nodes, key)
+		p.index[key] = n
+		p.callee = append(p.callee, -1)
+		p.calls = append(p.calls, 0)
+	}
+	p.calls[n]++
+	p.frames = append(p.frames, frame{n, int32(ret), int32(sp)})
+}
+
+// ret leaves the calls up to the one that returns to location ret with
+// stack pointer sp. It leaves none if no call does: the location is also
+// the entry of a function, called from deeper in the stack.
+func (p *Profile) ret(ret, sp int) {
+	for i := len(p.frames) - 1; i >= 0; i-- {
+		if f := p.frames[i]; f.ret == int32(ret) && int32(sp) <= f.sp+1 {
+			p.frames = p.frames[:i]
+			return
+		}
+	}
+}
+
+// stack returns the locations of a sample, the location run first, then
+// the sites of its calls from the innermost.
+func (p *Profile) stack(s sample) []int32 {
+	locs := []int32{s.loc}
+	for n := s.node; n > 0; n = p.nodes[n].parent {
+		locs = append(locs, p.nodes[n].site)
+	}
+
+	return locs
+}
+
+// edge is a call from a function to another.
+type edge struct {
+	caller, callee string
+}
+
+// Stat is the cycles of a function, or of the calls from a function to
+// another.
+type Stat struct {
+	Name  string
+	Flat  uint64 // cycles in the function
+	Cum   uint64 // cycles in the function and the functions it calls
+	Calls uint64
+}
+
+// Functions returns the cycles of the functions that ran, the most first.
+func (p *Profile) Functions() []Stat {
+	stats := make(map[string]*Stat)
+	stat := func(name string) *Stat {
+		s, ok := stats[name]
+		if !ok {
+			s = &Stat{Name: name}
+			stats[name] = s
+		}
+		return s
+	}
+	for loc, n := range p.Counts {
+		if n > 0 {
+			stat(p.Locations[loc].Function).Flat += n
+		}
+	}
+	for s, n := range p.samples {
+		seen := make(map[string]bool)
+		for _, loc := range p.stack(s) {
+			name := p.Locations[loc].Function
+			if !seen[name] {
+				seen[name] = true
+				stat(name).Cum += n
+			}
+		}
+	}
+	for n, entry := range p.callee {
+		if entry >= 0 && n > 0 {
+			stat(p.Locations[entry].Function).Calls += p.calls[n]
+		}
+	}
+
+	return sorted(stats)
+}
+
+// Calls returns the cycles of the calls from a function to another, the
+// most first. Their names are caller -> callee.
+func (p *Profile) Calls() []Stat {
+	stats := make(map[edge]*Stat)
+	stat := func(e edge) *Stat {
+		s, ok := stats[e]
+		if !ok {
+			s = &Stat{Name: e.caller + " -> " + e.callee}
+			stats[e] = s
+		}
+		return s
+	}
+	for s, n := range p.samples {
+		seen := make(map[edge]bool)
+		locs := p.stack(s)
+		for i := 0; i+1 < len(locs); i++ {
+			e := edge{p.Locations[locs[i+1]].Function, p.Locations[locs[i]].Function}
+			if i == 0 {
+				stat(e).Flat += n
+			}
+			if !seen[e] {
+				seen[e] = true
+				stat(e).Cum += n
+			}
+		}
+	}
+	for n, entry := range p.callee {
+		if entry >= 0 && n > 0 {
+			stat(edge{p.Locations[p.nodes[n].site].Function, p.Locations[entry].Function}).Calls += p.calls[n]
+		}
+	}
+
+	return sorted(stats)
+}
+
+// sorted returns stats by cumulative cycles, then by flat cycles and name.
+func sorted[K comparable](stats map[K]*Stat) []Stat {
+	list := make([]Stat, 0, len(stats))
+	for _, s := range stats {
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Cum != b.Cum {
+			return a.Cum > b.Cum
+		}
+		if a.Flat != b.Flat {
+			return a.Flat > b.Flat
+		}
+		return a.Name < b.Name
+	})
+
+	return list
+}
+
+// WriteReport writes the functions, the calls and the locations that ran
+// the most cycles to w, the first n of each, or all if n is 0.
+func (p *Profile) WriteReport(w io.Writer, n int) error {
+	var b strings.Builder
+	percent := func(x uint64) float64 {
+		if p.Total == 0 {
+			return 0
+		}
+		return 100 * float64(x) / float64(p.Total)
+	}
+	head := func(length int) int {
+		if n > 0 && n < length {
+			return n
+		}
+		return length
+	}
+
+	fmt.Fprintf(&b, "%v: %d cycles\n\n", p.Name, p.Total)
+	fmt.Fprintf(&b, "%12v %6v %12v %6v %9v  %v\n", "flat", "flat%", "cum", "cum%", "calls", "function")
+	functions := p.Functions()
+	sort.SliceStable(functions, func(i, j int) bool { return functions[i].Flat > functions[j].Flat })
+	for _, s := range functions[:head(len(functions))] {
+		fmt.Fprintf(&b, "%12d %5.1f%% %12d %5.1f%% %9d  %v\n", s.Flat, percent(s.Flat), s.Cum, percent(s.Cum), s.Calls, s.Name)
+	}
+
+	fmt.Fprintf(&b, "\n%12v %6v %12v %6v %9v  %v\n", "flat", "flat%", "cum", "cum%", "calls", "call")
+	calls := p.Calls()
+	for _, s := range calls[:head(len(calls))] {
+		fmt.Fprintf(&b, "%12d %5.1f%% %12d %5.1f%% %9d  %v\n", s.Flat, percent(s.Flat), s.Cum, percent(s.Cum), s.Calls, s.Name)
+	}
+
+	var locs []int
+	for loc, count := range p.Counts {
+		if count > 0 {
+			locs = append(locs, loc)
+		}
+	}
+	sort.SliceStable(locs, func(i, j int) bool { return p.Counts[locs[i]] > p.Counts[locs[j]] })
+	fmt.Fprintf(&b, "\n%12v %6v %6v  %v\n", "flat", "flat%", "loc", "function")
+	for _, loc := range locs[:head(len(locs))] {
+		l := p.Locations[loc]
+		fmt.Fprintf(&b, "%12d %5.1f%% %6d  %v %v:%d  %v\n", p.Counts[loc], percent(p.Counts[loc]), loc, l.Function, l.File, l.Line, l.Text)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// CPU profiles a computer.
+type CPU struct {
+	*Profile
+	c       *cpu.Computer
+	calls   []uint16 // return address of the call that jumps at each address, or 0
+	returns []bool
+}
+
+// NewCPU returns a profiler of c. p is the assembled source of the program
+// of c, whose file is name, or nil. Without it, no location is in a
+// function.
+func NewCPU(c *cpu.Computer, p *asm.Program, name string) *CPU {
+	locs := make([]Location, c.Size)
+	var functions map[int]string
+	if p != nil {
+		functions = p.Functions()
+	}
+	function := none
+	for addr := range locs {
+		if name, ok := functions[addr]; ok {
+			function = name
+		}
+		_, entry := functions[addr]
+		locs[addr] = Location{Function: function, Entry: entry, File: filepath.Base(name), Line: addr + 1, Text: asm.Disassemble(c.ROM[addr])}
+		if p != nil && addr < len(p.Lines) {
+			locs[addr].Line = p.Lines[addr]
+		}
+	}
+
+	pr := &CPU{
+		Profile: newProfile(name, locs),
+		c:       c,
+		calls:   make([]uint16, c.Size),
+		returns: make([]bool, c.Size),
+	}
+	for _, ret := range asm.Calls(c.ROM[:c.Size]) {
+		pr.calls[ret-1] = ret
+		pr.returns[ret] = true
+	}
+
+	return pr
+}
+
+// Step runs the next instruction of the computer, and counts it.
+func (pr *CPU) Step() {
+	pc := int(pr.c.PC)
+	if pc < len(pr.calls) {
+		sp := int(pr.c.RAM[hack.SP])
+		if pr.returns[pc] {
+			pr.ret(pc, sp)
+		}
+		pr.count(pc)
+		if ret := pr.calls[pc]; ret != 0 {
+			pr.call(pc, int(ret), sp)
+		}
+	}
+	pr.c.Step()
+}
+
+// VM profiles a machine.
+type VM struct {
+	*Profile
+	m *vmemu.Machine
+}
+
+// NewVM returns a profiler of m, whose program is name.
+func NewVM(m *vmemu.Machine, name string) *VM {
+	p := m.Program
+	locs := make([]Location, len(p.Code))
+	for i, cmd := range p.Code {
+		function := none
+		if cmd.Op == vmemu.Function {
+			function = cmd.Name
+		} else if i > 0 && p.Code[i-1].File == cmd.File {
+			function = locs[i-1].Function
+		}
+		locs[i] = Location{Function: function, Entry: cmd.Op == vmemu.Function, File: p.Files[cmd.File] + ".vm", Line: cmd.Line, Text: cmd.String()}
+	}
+
+	return &VM{newProfile(name, locs), m}
+}
+
+// Step runs the next command of the machine, and counts it if it does not
+// fail.
+func (pr *VM) Step() error {
+	m := pr.m
+	pc, sp := m.PC, int(m.RAM[hack.SP])
+	if m.Halted() {
+		return m.Step()
+	}
+	cmd := m.Program.Code[pc]
+	if err := m.Step(); err != nil {
+		return err
+	}
+	pr.count(pc)
+	switch cmd.Op {
+	case vmemu.Call:
+		pr.call(pc, pc+1, sp)
+	case vmemu.Return:
+		pr.ret(m.PC, int(m.RAM[hack.SP]))
+	}
+
+	return nil
+}
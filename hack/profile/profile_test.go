@@ -0,0 +1,252 @@
+package profile_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"hack/asm"
+	"hack/cpu"
+	"hack/profile"
+	"hack/vmemu"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// calls calls Main.main, which calls Main.f twice, as the VM translator
+// would, with the return address in R13 for Main.f.
+const calls = `
+	@RET0
+	D=A
+	@Main.main
+	0;JMP
+(RET0)
+	@RET0
+	0;JMP
+(Main.main)
+	@RET1
+	D=A
+	@R13
+	M=D
+	@Main.f
+	0;JMP
+(RET1)
+	@RET2
+	D=A
+	@R13
+	M=D
+	@Main.f
+	0;JMP
+(RET2)
+(Main.main$END)
+	@Main.main$END
+	0;JMP
+(Main.f)
+	@3
+	D=A
+(Main.f$LOOP)
+	D=D-1
+	@Main.f$LOOP
+	D;JGT
+	@R13
+	A=M
+	0;JMP
+`
+
+func profileCPU(t *testing.T, src string, n int) *profile.CPU {
+	t.Helper()
+	p, err := asm.Assemble([]byte(src))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	c := cpu.New(p.Code)
+	pr := profile.NewCPU(c, p, "Calls.asm")
+	for i := 0; i < n && !c.Halted(); i++ {
+		pr.Step()
+	}
+
+	return pr
+}
+
+func TestCPU(t *testing.T) {
+	pr := profileCPU(t, calls, 1000)
+	assert.Equal(t, uint64(44), pr.Total)
+	assert.Equal(t, uint64(6), pr.Counts[22]) // D=D-1
+	assert.Equal(t, profile.Location{Function: "Main.f", Entry: true, File: "Calls.asm", Line: 28, Text: "@3"}, pr.Locations[20])
+	assert.Equal(t, []profile.Stat{
+		{Name: "(none)", Flat: 4, Cum: 44},
+		{Name: "Main.main", Flat: 12, Cum: 40, Calls: 1},
+		{Name: "Main.f", Flat: 28, Cum: 28, Calls: 2},
+	}, pr.Functions())
+	assert.Equal(t, []profile.Stat{
+		{Name: "(none) -> Main.main", Flat: 12, Cum: 40, Calls: 1},
+		{Name: "Main.main -> Main.f", Flat: 28, Cum: 28, Calls: 2},
+	}, pr.Calls())
+}
+
+func TestCPU_Pong(t *testing.T) {
+	src, err := os.ReadFile("../../project6/tests/Pong.asm")
+	assert.NoError(t, err)
+	pr := profileCPU(t, string(src), 5_000_000)
+
+	var flat uint64
+	names := make(map[string]bool)
+	for _, s := range pr.Functions() {
+		flat += s.Flat
+		names[s.Name] = true
+		// no labels in functions, or of the translator
+		assert.NotContains(t, s.Name, "$")
+		assert.NotContains(t, s.Name, "_")
+	}
+	assert.Equal(t, pr.Total, flat)
+	assert.True(t, names["sys.init"])
+	assert.True(t, names["output.init"])
+	for _, s := range pr.Calls() {
+		if s.Name == "sys.init -> output.init" {
+			assert.Equal(t, uint64(1), s.Calls)
+		}
+	}
+}
+
+func TestVM(t *testing.T) {
+	p, err := vmemu.Load(
+		vmemu.File{Name: "Sys", Src: []byte(`
+			function Sys.init 0
+			call Main.main 0
+			return`)},
+		vmemu.File{Name: "Main", Src: []byte(`
+			function Main.main 0
+			push constant 1
+			call Main.f 1
+			push constant 2
+			call Main.f 1
+			add
+			return
+			function Main.f 0
+			push argument 0
+			push argument 0
+			add
+			return`)},
+	)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	m := vmemu.New(p)
+	pr := profile.NewVM(m, "Main")
+	for !m.Halted() {
+		if !assert.NoError(t, pr.Step()) {
+			t.FailNow()
+		}
+	}
+
+	assert.Equal(t, int16(6), m.RAM[256])
+	assert.Equal(t, uint64(20), pr.Total)
+	assert.Equal(t, profile.Location{Function: "Main.f", Entry: true, File: "Main.vm", Line: 9, Text: "function Main.f 0"}, pr.Locations[10])
+	assert.Equal(t, []profile.Stat{
+		{Name: "Sys.init", Flat: 3, Cum: 20},
+		{Name: "Main.main", Flat: 7, Cum: 17, Calls: 1},
+		{Name: "Main.f", Flat: 10, Cum: 10, Calls: 2},
+	}, pr.Functions())
+	assert.Equal(t, []profile.Stat{
+		{Name: "Sys.init -> Main.main", Flat: 7, Cum: 17, Calls: 1},
+		{Name: "Main.main -> Main.f", Flat: 10, Cum: 10, Calls: 2},
+	}, pr.Calls())
+}
+
+func TestProfile_WriteReport(t *testing.T) {
+	pr := profileCPU(t, calls, 1000)
+	var b strings.Builder
+	assert.NoError(t, pr.WriteReport(&b, 2))
+	assert.Equal(t, `Calls.asm: 44 cycles
+
+        flat  flat%          cum   cum%     calls  function
+          28  63.6%           28  63.6%         2  Main.f
+          12  27.3%           40  90.9%         1  Main.main
+
+        flat  flat%          cum   cum%     calls  call
+          12  27.3%           40  90.9%         1  (none) -> Main.main
+          28  63.6%           28  63.6%         2  Main.main -> Main.f
+
+        flat  flat%    loc  function
+           6  13.6%     22  Main.f Calls.asm:31  D=D-1
+           6  13.6%     23  Main.f Calls.asm:32  @22
+`, b.String())
+}
+
+func TestProfile_WritePprof(t *testing.T) {
+	pr := profileCPU(t, calls, 1000)
+	var b bytes.Buffer
+	assert.NoError(t, pr.WritePprof(&b))
+	z, err := gzip.NewReader(&b)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(z)
+	assert.NoError(t, err)
+
+	// the values of the samples, and the string table
+	var total uint64
+	var table []string
+	for _, f := range fields(t, data) {
+		switch f.num {
+		case 2:
+			for _, v := range fields(t, f.data) {
+				if v.num == 2 {
+					for d := v.data; len(d) > 0; {
+						x, n := varint(d)
+						total += x
+						d = d[n:]
+					}
+				}
+			}
+		case 6:
+			table = append(table, string(f.data))
+		}
+	}
+	assert.Equal(t, pr.Total, total)
+	assert.Equal(t, []string{"", "cycles", "count", "Calls.asm", "(none)", "Main.main", "Main.f"}, table)
+}
+
+type field struct {
+	num  int
+	x    uint64
+	data []byte
+}
+
+// fields decodes the fields of a protocol buffer message, of wire types 0
+// and 2.
+func fields(t *testing.T, data []byte) []field {
+	var fs []field
+	for len(data) > 0 {
+		key, n := varint(data)
+		data = data[n:]
+		f := field{num: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			f.x, n = varint(data)
+			data = data[n:]
+		case 2:
+			size, n := varint(data)
+			f.data = data[n : n+int(size)]
+			data = data[n+int(size):]
+		default:
+			t.Fatalf("wire type %d", key&7)
+		}
+		fs = append(fs, f)
+	}
+
+	return fs
+}
+
+func varint(data []byte) (uint64, int) {
+	var x uint64
+	for i, b := range data {
+		x |= uint64(b&0x7f) << (7 * i)
+		if b < 0x80 {
+			return x, i + 1
+		}
+	}
+
+	return x, len(data)
+}
@@ -0,0 +1,73 @@
+// Package screen renders the screen memory map of the Hack computer as an
+// image, for the emulators of package hack/cpu and hack/vmemu.
+package screen
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+
+	"hack"
+)
+
+// Palette is the colors of the screen, white for 0 and black for 1.
+var Palette = color.Palette{color.White, color.Black}
+
+// Image returns the screen of ram, the RAM of an emulator, as an image of
+// hack.ScreenWidth by hack.ScreenHeight pixels with Palette.
+func Image(ram []int16) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, hack.ScreenWidth, hack.ScreenHeight), Palette)
+	for i, word := range ram[hack.Screen : hack.Screen+hack.ScreenWords] {
+		if word == 0 {
+			continue
+		}
+		offset := i * 16 // the pixels are in the order of the words
+		for bit := 0; bit < 16; bit++ {
+			if word&(1<<bit) != 0 {
+				img.Pix[offset+bit] = 1
+			}
+		}
+	}
+
+	return img
+}
+
+// Encode writes the screen of ram to w in the PNG format.
+func Encode(w io.Writer, ram []int16) error {
+	return png.Encode(w, Image(ram))
+}
+
+// Save writes the screen of ram to the PNG file name.
+func Save(name string, ram []int16) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := Encode(f, ram); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Diff returns the number of pixels that are black in one image and not in
+// the other. Both images are the size of the screen.
+func Diff(a, b image.Image) int {
+	n := 0
+	for y := 0; y < hack.ScreenHeight; y++ {
+		for x := 0; x < hack.ScreenWidth; x++ {
+			if black(a.At(x, y)) != black(b.At(x, y)) {
+				n++
+			}
+		}
+	}
+
+	return n
+}
+
+func black(c color.Color) bool {
+	return Palette.Index(c) == 1
+}
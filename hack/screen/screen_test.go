@@ -0,0 +1,37 @@
+package screen_test
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+
+	"hack"
+	"hack/screen"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImage(t *testing.T) {
+	var ram [hack.RAMSize]int16
+	ram[hack.Screen] = 1                 // the first pixel
+	ram[hack.Screen+1] = -1 << 15        // the 32nd pixel
+	ram[hack.Screen+32*10+31] = -1 << 15 // the last pixel of row 10
+	ram[hack.KBD] = -1
+
+	img := screen.Image(ram[:])
+	black := map[[2]int]bool{{0, 0}: true, {31, 0}: true, {511, 10}: true}
+	for y := 0; y < hack.ScreenHeight; y++ {
+		for x := 0; x < hack.ScreenWidth; x++ {
+			assert.Equal(t, black[[2]int{x, y}], img.ColorIndexAt(x, y) == 1, "pixel %d, %d", x, y)
+		}
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, screen.Encode(&buf, ram[:]))
+	decoded, err := png.Decode(&buf)
+	assert.NoError(t, err)
+	assert.Zero(t, screen.Diff(img, decoded))
+
+	ram[hack.Screen+hack.ScreenWords-1] = 3
+	assert.Equal(t, 2, screen.Diff(img, screen.Image(ram[:])))
+}
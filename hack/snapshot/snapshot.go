@@ -0,0 +1,236 @@
+// Package snapshot saves the state of the emulators of package hack/cpu
+// and hack/vmemu to files, to run on from there as many times as needed.
+// A snapshot holds everything that the run depends on: the program, the
+// RAM with the keyboard and the stack of the VM, the registers and the
+// cycles run so far. The same state always makes the same snapshot.
+//
+// A snapshot is gzipped:
+//
+//	header    "HSNP" version:u8 kind:u8
+//	computer  size:u16 ROM:size*u16 PC:u16 A:i16 D:i16 cycles:u64 RAM
+//	machine   files:u16 (name:string src:string)*files PC:u32 steps:u64 RAM
+//
+// where kind is 1 for a computer and 2 for a machine, RAM is the 32768
+// words of the RAM, and a string is its length:u32 and its bytes. Numbers
+// are little endian. The VM code of a machine is that of
+// vmemu.Program.Source, without comments.
+package snapshot
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"hack"
+	"hack/cpu"
+	"hack/vmemu"
+)
+
+const (
+	magic   = "HSNP"
+	version = 1
+)
+
+// The kinds of snapshots.
+const (
+	kindComputer = 1
+	kindMachine  = 2
+)
+
+// Snapshot is the state of a computer or of a machine, the other is nil.
+type Snapshot struct {
+	Computer *cpu.Computer
+	Machine  *vmemu.Machine
+}
+
+// Write writes the snapshot to w.
+func (s *Snapshot) Write(w io.Writer) error {
+	var b []byte
+	le := binary.LittleEndian
+	str := func(s []byte) {
+		b = le.AppendUint32(b, uint32(len(s)))
+		b = append(b, s...)
+	}
+	ram := func(ram *[hack.RAMSize]int16) {
+		for _, word := range ram {
+			b = le.AppendUint16(b, uint16(word))
+		}
+	}
+
+	b = append(b, magic...)
+	b = append(b, version)
+	switch {
+	case s.Computer != nil:
+		c := s.Computer
+		b = append(b, kindComputer)
+		b = le.AppendUint16(b, uint16(c.Size))
+		for _, word := range c.ROM[:c.Size] {
+			b = le.AppendUint16(b, word)
+		}
+		b = le.AppendUint16(b, c.PC)
+		b = le.AppendUint16(b, uint16(c.A))
+		b = le.AppendUint16(b, uint16(c.D))
+		b = le.AppendUint64(b, c.Cycles)
+		ram(&c.RAM)
+	case s.Machine != nil:
+		m := s.Machine
+		b = append(b, kindMachine)
+		files := m.Program.Source()
+		b = le.AppendUint16(b, uint16(len(files)))
+		for _, f := range files {
+			str([]byte(f.Name))
+			str(f.Src)
+		}
+		b = le.AppendUint32(b, uint32(m.PC))
+		b = le.AppendUint64(b, m.Steps)
+		ram(&m.RAM)
+	default:
+		return errors.New("empty snapshot")
+	}
+
+	z := gzip.NewWriter(w)
+	if _, err := z.Write(b); err != nil {
+		return err
+	}
+
+	return z.Close()
+}
+
+// Read reads a snapshot.
+func Read(r io.Reader) (*Snapshot, error) {
+	z, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.New("not a snapshot")
+	}
+	d := &decoder{r: bufio.NewReader(z)}
+	if string(d.bytes(len(magic))) != magic || d.err != nil {
+		return nil, errors.New("not a snapshot")
+	}
+	if v := d.byte(); v != version {
+		return nil, fmt.Errorf("snapshot version %d, not %d", v, version)
+	}
+
+	s := &Snapshot{}
+	switch kind := d.byte(); kind {
+	case kindComputer:
+		size := d.uint16()
+		if d.err == nil && int(size) > cpu.ROMSize {
+			return nil, fmt.Errorf("snapshot program of %d words, longer than the %d words of the ROM", size, cpu.ROMSize)
+		}
+		code := make([]uint16, size)
+		for i := range code {
+			code[i] = d.uint16()
+		}
+		c := cpu.New(code)
+		c.PC = d.uint16()
+		if d.err == nil && int(c.PC) > cpu.ROMSize {
+			return nil, fmt.Errorf("snapshot PC %d after the ROM", c.PC)
+		}
+		c.A, c.D = int16(d.uint16()), int16(d.uint16())
+		c.Cycles = d.uint64()
+		d.ram(&c.RAM)
+		s.Computer = c
+	case kindMachine:
+		files := make([]vmemu.File, d.uint16())
+		for i := range files {
+			files[i] = vmemu.File{Name: string(d.string()), Src: d.string()}
+		}
+		pc, steps := d.uint32(), d.uint64()
+		var ram [hack.RAMSize]int16
+		d.ram(&ram)
+		if d.err != nil {
+			break
+		}
+		p, err := vmemu.Load(files...)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot program: %v", err)
+		}
+		if int(pc) > len(p.Code) {
+			return nil, fmt.Errorf("snapshot PC %d after the program", pc)
+		}
+		m := vmemu.New(p)
+		m.PC, m.Steps, m.RAM = int(pc), steps, ram
+		s.Machine = m
+	default:
+		if d.err == nil {
+			return nil, fmt.Errorf("unknown snapshot kind %d", kind)
+		}
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("truncated snapshot: %v", d.err)
+	}
+
+	return s, nil
+}
+
+// Save writes the snapshot to the file name.
+func (s *Snapshot) Save(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := s.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Load reads the snapshot of the file name.
+func Load(name string) (*Snapshot, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", name, err)
+	}
+
+	return s, nil
+}
+
+// decoder reads the numbers of a snapshot, until the first error.
+type decoder struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (d *decoder) bytes(n int) []byte {
+	b := make([]byte, n)
+	if d.err == nil {
+		_, d.err = io.ReadFull(d.r, b)
+	}
+
+	return b
+}
+
+func (d *decoder) byte() byte     { return d.bytes(1)[0] }
+func (d *decoder) uint16() uint16 { return binary.LittleEndian.Uint16(d.bytes(2)) }
+func (d *decoder) uint32() uint32 { return binary.LittleEndian.Uint32(d.bytes(4)) }
+func (d *decoder) uint64() uint64 { return binary.LittleEndian.Uint64(d.bytes(8)) }
+
+func (d *decoder) string() []byte {
+	n := d.uint32()
+	if d.err == nil && n > 1<<24 {
+		d.err = errors.New("invalid string")
+	}
+	if d.err != nil {
+		return nil
+	}
+
+	return d.bytes(int(n))
+}
+
+func (d *decoder) ram(ram *[hack.RAMSize]int16) {
+	b := d.bytes(2 * hack.RAMSize)
+	for i := range ram {
+		ram[i] = int16(binary.LittleEndian.Uint16(b[2*i:]))
+	}
+}
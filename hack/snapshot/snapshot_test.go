@@ -0,0 +1,167 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"hack/asm"
+	"hack/cpu"
+	"hack/snapshot"
+	"hack/vmemu"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// roundTrip writes s, reads it back, and checks that writing it again
+// gives the same bytes.
+func roundTrip(t *testing.T, s *snapshot.Snapshot) *snapshot.Snapshot {
+	t.Helper()
+	var b bytes.Buffer
+	assert.NoError(t, s.Write(&b))
+	data := bytes.Clone(b.Bytes())
+	restored, err := snapshot.Read(&b)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	var again bytes.Buffer
+	assert.NoError(t, restored.Write(&again))
+	assert.Equal(t, data, again.Bytes())
+
+	return restored
+}
+
+func TestSnapshot_Computer(t *testing.T) {
+	src, err := os.ReadFile("../../project6/tests/Rect.asm")
+	assert.NoError(t, err)
+	p, err := asm.Assemble(src)
+	assert.NoError(t, err)
+	c := cpu.New(p.Code)
+	c.RAM[0] = 4
+	c.Run(40)
+
+	restored := roundTrip(t, &snapshot.Snapshot{Computer: c})
+	assert.Nil(t, restored.Machine)
+	assert.Equal(t, c, restored.Computer)
+
+	// both run on the same
+	c.Run(1000)
+	restored.Computer.Run(1000)
+	assert.Equal(t, c, restored.Computer)
+}
+
+func TestSnapshot_Machine(t *testing.T) {
+	p, err := vmemu.Load(
+		vmemu.File{Name: "Sys", Src: []byte(`
+			function Sys.init 0
+			push constant 5
+			call Main.fact 1
+			pop static 0
+			label HALT
+			goto HALT`)},
+		vmemu.File{Name: "Main", Src: []byte(`
+			// factorial, recursive
+			function Main.fact 0
+			push argument 0
+			push constant 1
+			gt
+			if-goto MORE
+			push constant 1
+			return
+			label MORE
+			push argument 0
+			push argument 0
+			push constant 1
+			sub
+			call Main.fact 1
+			call Main.multiply 2
+			return
+			function Main.multiply 0
+			push constant 0
+			label LOOP
+			push argument 1
+			if-goto ADD
+			return
+			label ADD
+			push argument 0
+			add
+			push argument 1
+			push constant 1
+			sub
+			pop argument 1
+			goto LOOP`)},
+	)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	m := vmemu.New(p)
+	// in the calls, with frames on the stack
+	assert.NoError(t, m.Run(60))
+
+	restored := roundTrip(t, &snapshot.Snapshot{Machine: m})
+	assert.Nil(t, restored.Computer)
+	assert.Equal(t, m.Program.Code, restored.Machine.Program.Code)
+	assert.Equal(t, m.RAM, restored.Machine.RAM)
+	assert.Equal(t, m.PC, restored.Machine.PC)
+	assert.Equal(t, m.Steps, restored.Machine.Steps)
+
+	assert.NoError(t, m.Run(2000))
+	assert.NoError(t, restored.Machine.Run(2000))
+	assert.Equal(t, int16(120), m.RAM[16])
+	assert.Equal(t, m.RAM, restored.Machine.RAM)
+	assert.Equal(t, m.PC, restored.Machine.PC)
+	assert.Equal(t, m.Steps, restored.Machine.Steps)
+}
+
+func TestSnapshot_SaveLoad(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "rect.snap")
+	c := cpu.New([]uint16{7, 0b1110110000010000})
+	c.Run(1)
+	assert.NoError(t, (&snapshot.Snapshot{Computer: c}).Save(name))
+	s, err := snapshot.Load(name)
+	assert.NoError(t, err)
+	assert.Equal(t, c, s.Computer)
+
+	_, err = snapshot.Load(filepath.Join(t.TempDir(), "none.snap"))
+	assert.Error(t, err)
+}
+
+func TestRead_Errors(t *testing.T) {
+	gz := func(data []byte) *bytes.Buffer {
+		var b bytes.Buffer
+		z := gzip.NewWriter(&b)
+		z.Write(data)
+		z.Close()
+		return &b
+	}
+	var b bytes.Buffer
+	assert.NoError(t, (&snapshot.Snapshot{Computer: cpu.New(nil)}).Write(&b))
+	z, err := gzip.NewReader(&b)
+	assert.NoError(t, err)
+	var data bytes.Buffer
+	data.ReadFrom(z)
+
+	tests := []struct {
+		name string
+		data *bytes.Buffer
+		want string
+	}{
+		{"1. not gzipped", bytes.NewBufferString("HSNP"), "not a snapshot"},
+		{"2. no magic", gz([]byte("HACK\x01\x01")), "not a snapshot"},
+		{"3. version", gz([]byte("HSNP\x02\x01")), "snapshot version 2, not 1"},
+		{"4. kind", gz([]byte("HSNP\x01\x07")), "unknown snapshot kind 7"},
+		{"5. truncated", gz(data.Bytes()[:100]), "truncated snapshot: unexpected EOF"},
+		{"6. program too long", gz([]byte("HSNP\x01\x01\x01\x80")), "snapshot program of 32769 words, longer than the 32768 words of the ROM"},
+		{"7. PC after the ROM", gz([]byte("HSNP\x01\x01\x00\x00\x01\x80")), "snapshot PC 32769 after the ROM"},
+		{"8. truncated program", gz([]byte("HSNP\x01\x02\x01\x00\x04\x00\x00\x00Main\x03\x00\x00\x00pop")), "truncated snapshot: EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := snapshot.Read(tt.data)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+	assert.EqualError(t, (&snapshot.Snapshot{}).Write(&b), "empty snapshot")
+}
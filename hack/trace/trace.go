@@ -0,0 +1,353 @@
+// Package trace records the cycles of the computer of package hack/cpu in
+// a compact binary trace, all of them or the last ones only, and reads
+// traces back to search them and to reconstruct the state of the computer
+// after any cycle they recorded.
+//
+// A trace is a header with the ROM, a record of each cycle, and a footer
+// with the state of the computer at the end:
+//
+//	header  "HTRC" version:u8 size:u16 ROM:size*u16 A:i16 D:i16
+//	record  flags:u8 [PC:u16] inst:u16 [A:i16] [D:i16] [addr:u16 old:i16 new:i16]
+//	footer  0xff PC:u16 A:i16 D:i16 cycles:u64 RAM:32768*i16
+//
+// Numbers are little endian. The flags of a record tell its optional
+// fields: the PC when the instruction does not follow the one before, A
+// and D when the instruction changed them, and the RAM word that it
+// wrote. A and D are those after the instruction, those of the header are
+// before the first record. The old value of a write undoes it, so that
+// the state after a cycle is the state at the end with the writes of the
+// cycles after it undone.
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"hack"
+	"hack/asm"
+	"hack/cpu"
+)
+
+const (
+	magic   = "HTRC"
+	version = 1
+)
+
+// The flags of a record.
+const (
+	flagPC    = 1 << iota // the instruction does not follow the previous one
+	flagA                 // A changed
+	flagD                 // D changed
+	flagWrite             // the instruction wrote the RAM
+	footer    = 0xff
+)
+
+// Record is a cycle of the computer.
+type Record struct {
+	Cycle    uint64 // the cycles run with this one, from 1
+	PC, Inst uint16
+	A, D     int16 // after the instruction
+	Write    bool
+	Addr     uint16 // of the write
+	Old, New int16
+}
+
+func (r Record) String() string {
+	s := fmt.Sprintf("%d: %d %-10v A %6d  D %6d", r.Cycle, r.PC, asm.Disassemble(r.Inst), r.A, r.D)
+	if r.Write {
+		s += fmt.Sprintf("  RAM[%d] %d -> %d", r.Addr, r.Old, r.New)
+	}
+
+	return s
+}
+
+// encoder encodes records, each after the previous one.
+type encoder struct {
+	w    *bufio.Writer
+	prev *Record
+}
+
+func (e *encoder) encode(r Record) {
+	var flags byte
+	if e.prev == nil || r.PC != e.prev.PC+1 {
+		flags |= flagPC
+	}
+	if e.prev == nil || r.A != e.prev.A {
+		flags |= flagA
+	}
+	if e.prev == nil || r.D != e.prev.D {
+		flags |= flagD
+	}
+	if r.Write {
+		flags |= flagWrite
+	}
+
+	b := make([]byte, 0, 15)
+	b = append(b, flags)
+	if flags&flagPC != 0 {
+		b = binary.LittleEndian.AppendUint16(b, r.PC)
+	}
+	b = binary.LittleEndian.AppendUint16(b, r.Inst)
+	if flags&flagA != 0 {
+		b = binary.LittleEndian.AppendUint16(b, uint16(r.A))
+	}
+	if flags&flagD != 0 {
+		b = binary.LittleEndian.AppendUint16(b, uint16(r.D))
+	}
+	if r.Write {
+		b = binary.LittleEndian.AppendUint16(b, r.Addr)
+		b = binary.LittleEndian.AppendUint16(b, uint16(r.Old))
+		b = binary.LittleEndian.AppendUint16(b, uint16(r.New))
+	}
+	e.w.Write(b)
+	e.prev = &r
+}
+
+// Recorder runs a computer and records its cycles.
+type Recorder struct {
+	c      *cpu.Computer
+	enc    encoder
+	ring   []Record // the last cycles, or nil to write each cycle
+	next   int      // of the ring
+	full   bool
+	a, d   int16 // before the first record
+	closed bool
+}
+
+// New returns a recorder of c, which writes the trace to w: each cycle as
+// it runs, or the last n cycles when it is closed if n > 0.
+func New(c *cpu.Computer, w io.Writer, n int) *Recorder {
+	r := &Recorder{c: c, enc: encoder{w: bufio.NewWriter(w)}, a: c.A, d: c.D}
+	if n > 0 {
+		r.ring = make([]Record, n)
+	} else {
+		r.header()
+	}
+
+	return r
+}
+
+func (r *Recorder) header() {
+	b := []byte(magic)
+	b = append(b, version)
+	b = binary.LittleEndian.AppendUint16(b, uint16(r.c.Size))
+	for _, word := range r.c.ROM[:r.c.Size] {
+		b = binary.LittleEndian.AppendUint16(b, word)
+	}
+	b = binary.LittleEndian.AppendUint16(b, uint16(r.a))
+	b = binary.LittleEndian.AppendUint16(b, uint16(r.d))
+	r.enc.w.Write(b)
+}
+
+// Step runs the next instruction of the computer, and records it.
+func (r *Recorder) Step() {
+	c := r.c
+	rec := Record{PC: c.PC, Inst: c.ROM[c.PC&(cpu.ROMSize-1)]}
+	rec.Old = c.RAM[uint16(c.A)&(hack.RAMSize-1)]
+	addr, wrote := c.Step()
+	rec.Cycle, rec.A, rec.D = c.Cycles, c.A, c.D
+	if wrote {
+		rec.Write, rec.Addr, rec.New = true, uint16(addr), c.RAM[addr]
+	} else {
+		rec.Old = 0
+	}
+
+	if r.ring == nil {
+		r.enc.encode(rec)
+		return
+	}
+	if r.full {
+		// the record that drops out of the ring
+		r.a, r.d = r.ring[r.next].A, r.ring[r.next].D
+	}
+	r.ring[r.next] = rec
+	r.next++
+	if r.next == len(r.ring) {
+		r.next, r.full = 0, true
+	}
+}
+
+// Close writes the end of the trace: the cycles of the ring, and the state
+// of the computer. It does not close the writer of the trace.
+func (r *Recorder) Close() error {
+	if r.closed {
+		return errors.New("trace closed")
+	}
+	r.closed = true
+	if r.ring != nil {
+		r.header()
+		if r.full {
+			for _, rec := range r.ring[r.next:] {
+				r.enc.encode(rec)
+			}
+		}
+		for _, rec := range r.ring[:r.next] {
+			r.enc.encode(rec)
+		}
+	}
+
+	c := r.c
+	b := []byte{footer}
+	b = binary.LittleEndian.AppendUint16(b, c.PC)
+	b = binary.LittleEndian.AppendUint16(b, uint16(c.A))
+	b = binary.LittleEndian.AppendUint16(b, uint16(c.D))
+	b = binary.LittleEndian.AppendUint64(b, c.Cycles)
+	for _, word := range c.RAM {
+		b = binary.LittleEndian.AppendUint16(b, uint16(word))
+	}
+	r.enc.w.Write(b)
+
+	return r.enc.w.Flush()
+}
+
+// Trace is a trace read back.
+type Trace struct {
+	ROM     []uint16
+	Records []Record
+	End     *cpu.Computer // the computer at the end of the trace
+	a, d    int16         // before the first record
+}
+
+// Read reads a trace.
+func Read(r io.Reader) (*Trace, error) {
+	br := bufio.NewReader(r)
+	d := &decoder{r: br}
+	if string(d.bytes(len(magic))) != magic || d.err != nil {
+		return nil, errors.New("not a trace")
+	}
+	if v := d.byte(); v != version {
+		return nil, fmt.Errorf("trace version %d, not %d", v, version)
+	}
+	t := &Trace{ROM: make([]uint16, d.uint16())}
+	for i := range t.ROM {
+		t.ROM[i] = d.uint16()
+	}
+	t.a, t.d = int16(d.uint16()), int16(d.uint16())
+
+	var prev Record
+	for d.err == nil {
+		flags := d.byte()
+		if flags == footer {
+			break
+		}
+		rec := Record{PC: prev.PC + 1, A: prev.A, D: prev.D}
+		if flags&flagPC != 0 {
+			rec.PC = d.uint16()
+		}
+		rec.Inst = d.uint16()
+		if flags&flagA != 0 {
+			rec.A = int16(d.uint16())
+		}
+		if flags&flagD != 0 {
+			rec.D = int16(d.uint16())
+		}
+		if flags&flagWrite != 0 {
+			rec.Write = true
+			rec.Addr = d.uint16()
+			rec.Old, rec.New = int16(d.uint16()), int16(d.uint16())
+		}
+		t.Records = append(t.Records, rec)
+		prev = rec
+	}
+
+	c := cpu.New(t.ROM)
+	c.PC = d.uint16()
+	c.A, c.D = int16(d.uint16()), int16(d.uint16())
+	c.Cycles = d.uint64()
+	for i := range c.RAM {
+		c.RAM[i] = int16(d.uint16())
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("truncated trace: %v", d.err)
+	}
+	t.End = c
+	first := c.Cycles - uint64(len(t.Records)) + 1
+	for i := range t.Records {
+		t.Records[i].Cycle = first + uint64(i)
+	}
+
+	return t, nil
+}
+
+// decoder reads the numbers of a trace, until the first error.
+type decoder struct {
+	r   *bufio.Reader
+	buf [8]byte
+	err error
+}
+
+func (d *decoder) bytes(n int) []byte {
+	b := d.buf[:n]
+	if d.err == nil {
+		_, d.err = io.ReadFull(d.r, b)
+	}
+
+	return b
+}
+
+func (d *decoder) byte() byte     { return d.bytes(1)[0] }
+func (d *decoder) uint16() uint16 { return binary.LittleEndian.Uint16(d.bytes(2)) }
+func (d *decoder) uint64() uint64 { return binary.LittleEndian.Uint64(d.bytes(8)) }
+
+// First returns the cycles run before the first record of the trace, the
+// first cycle whose state the trace knows.
+func (t *Trace) First() uint64 {
+	return t.End.Cycles - uint64(len(t.Records))
+}
+
+// Last returns the last record of the cycles up to cycle that matches, if
+// there is one.
+func (t *Trace) Last(cycle uint64, match func(Record) bool) (Record, bool) {
+	for i := t.index(cycle); i >= 0; i-- {
+		if match(t.Records[i]) {
+			return t.Records[i], true
+		}
+	}
+
+	return Record{}, false
+}
+
+// LastWrite returns the last write to RAM[addr] up to cycle, if there is
+// one.
+func (t *Trace) LastWrite(addr int, cycle uint64) (Record, bool) {
+	return t.Last(cycle, func(r Record) bool { return r.Write && int(r.Addr) == addr })
+}
+
+// index returns the index of the record of cycle, or of the last record
+// if the cycle is after it, or -1 if before the first.
+func (t *Trace) index(cycle uint64) int {
+	if cycle < t.First() {
+		return -1
+	}
+
+	return int(min(cycle-t.First(), uint64(len(t.Records)))) - 1
+}
+
+// State returns the computer after cycle, which may run on from there.
+func (t *Trace) State(cycle uint64) (*cpu.Computer, error) {
+	if cycle < t.First() || cycle > t.End.Cycles {
+		return nil, fmt.Errorf("cycle %d is not in the trace, from %d to %d", cycle, t.First(), t.End.Cycles)
+	}
+
+	c := *t.End
+	i := t.index(cycle)
+	for j := len(t.Records) - 1; j > i; j-- {
+		rec := t.Records[j]
+		if rec.Write {
+			c.RAM[rec.Addr] = rec.Old
+		}
+	}
+	if i+1 < len(t.Records) {
+		c.PC = t.Records[i+1].PC
+	}
+	c.A, c.D = t.a, t.d
+	if i >= 0 {
+		c.A, c.D = t.Records[i].A, t.Records[i].D
+	}
+	c.Cycles = cycle
+
+	return &c, nil
+}
@@ -0,0 +1,125 @@
+package trace_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"hack/asm"
+	"hack/cpu"
+	"hack/trace"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rect returns a computer that runs Rect, which draws a rectangle of
+// RAM[0] rows.
+func rect(t *testing.T) *cpu.Computer {
+	t.Helper()
+	src, err := os.ReadFile("../../project6/tests/Rect.asm")
+	assert.NoError(t, err)
+	p, err := asm.Assemble(src)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	c := cpu.New(p.Code)
+	c.RAM[0] = 4
+
+	return c
+}
+
+// record runs c until it halts, with a trace of its last n cycles, and
+// reads the trace back.
+func record(t *testing.T, c *cpu.Computer, n int) *trace.Trace {
+	t.Helper()
+	var b bytes.Buffer
+	r := trace.New(c, &b, n)
+	for !c.Halted() {
+		r.Step()
+	}
+	assert.NoError(t, r.Close())
+	tr, err := trace.Read(&b)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return tr
+}
+
+func TestTrace_State(t *testing.T) {
+	tests := []struct {
+		name string
+		last int
+	}{
+		{"1. all cycles", 0},
+		{"2. last 30 cycles", 30},
+		{"3. more than all cycles", 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := rect(t)
+			tr := record(t, c, tt.last)
+			total := c.Cycles
+			if tt.last > 0 && uint64(tt.last) < total {
+				assert.Equal(t, total-uint64(tt.last), tr.First())
+				assert.Len(t, tr.Records, tt.last)
+			} else {
+				assert.Equal(t, uint64(0), tr.First())
+				assert.Len(t, tr.Records, int(total))
+			}
+			assert.Equal(t, c.ROM, tr.End.ROM)
+
+			for cycle := tr.First(); cycle <= total; cycle++ {
+				want := rect(t)
+				want.Run(cycle)
+				got, err := tr.State(cycle)
+				assert.NoError(t, err)
+				if !assert.Equal(t, *want, *got, "cycle %d", cycle) {
+					return
+				}
+			}
+			_, err := tr.State(total + 1)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTrace_Last(t *testing.T) {
+	c := rect(t)
+	tr := record(t, c, 0)
+
+	// the first row of the rectangle, then the second
+	r, ok := tr.LastWrite(16384, c.Cycles)
+	assert.True(t, ok)
+	assert.Equal(t, trace.Record{Cycle: r.Cycle, PC: r.PC, Inst: r.Inst, A: 16384, D: r.D, Write: true, Addr: 16384, Old: 0, New: -1}, r)
+	_, ok = tr.LastWrite(16384, r.Cycle-1)
+	assert.False(t, ok)
+	r2, ok := tr.LastWrite(16416, c.Cycles)
+	assert.True(t, ok)
+	assert.Greater(t, r2.Cycle, r.Cycle)
+
+	_, ok = tr.LastWrite(100, c.Cycles)
+	assert.False(t, ok)
+	r, ok = tr.Last(c.Cycles, func(r trace.Record) bool { return r.PC == 0 })
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), r.Cycle)
+}
+
+func TestRead_Errors(t *testing.T) {
+	c := rect(t)
+	var b bytes.Buffer
+	r := trace.New(c, &b, 0)
+	for i := 0; i < 10; i++ {
+		r.Step()
+	}
+	assert.NoError(t, r.Close())
+	assert.Error(t, r.Close())
+	data := b.Bytes()
+
+	_, err := trace.Read(bytes.NewReader(data[:len(data)-1]))
+	assert.ErrorContains(t, err, "truncated trace")
+	_, err = trace.Read(bytes.NewReader([]byte("0000000000000000")))
+	assert.EqualError(t, err, "not a trace")
+	_, err = trace.Read(bytes.NewReader(append([]byte("HTRC\x02"), data[5:]...)))
+	assert.EqualError(t, err, "trace version 2, not 1")
+}
@@ -0,0 +1,66 @@
+package tui
+
+import (
+	"hack/keyboard"
+)
+
+// escapes are the escape sequences of the special keys in xterm and its
+// followers, after ESC.
+var escapes = map[string]int16{
+	"[A": keyboard.Up, "OA": keyboard.Up,
+	"[B": keyboard.Down, "OB": keyboard.Down,
+	"[C": keyboard.Right, "OC": keyboard.Right,
+	"[D": keyboard.Left, "OD": keyboard.Left,
+	"[H": keyboard.Home, "OH": keyboard.Home, "[1~": keyboard.Home, "[7~": keyboard.Home,
+	"[F": keyboard.End, "OF": keyboard.End, "[4~": keyboard.End, "[8~": keyboard.End,
+	"[2~": keyboard.Insert,
+	"[3~": keyboard.Delete,
+	"[5~": keyboard.PageUp,
+	"[6~": keyboard.PageDown,
+	"OP":  keyboard.F1, "OQ": keyboard.F1 + 1, "OR": keyboard.F1 + 2, "OS": keyboard.F1 + 3,
+	"[15~": keyboard.F1 + 4, "[17~": keyboard.F1 + 5, "[18~": keyboard.F1 + 6, "[19~": keyboard.F1 + 7,
+	"[20~": keyboard.F1 + 8, "[21~": keyboard.F1 + 9, "[23~": keyboard.F1 + 10, "[24~": keyboard.F1 + 11,
+}
+
+// interrupt is Ctrl-C, which quits.
+const interrupt = -1
+
+// decode returns the Hack key codes of the bytes that a terminal in raw
+// mode sent, and interrupt for Ctrl-C. Letters are upper case, as the
+// Hack keyboard gives them. Unknown sequences are dropped.
+func decode(buf []byte) []int16 {
+	var keys []int16
+	for i := 0; i < len(buf); i++ {
+		b := buf[i]
+		switch {
+		case b == 0x03:
+			keys = append(keys, interrupt)
+		case b == '\r' || b == '\n':
+			keys = append(keys, keyboard.Newline)
+		case b == 0x7f || b == 0x08:
+			keys = append(keys, keyboard.Backspace)
+		case b == 0x1b:
+			// the longest sequence of escapes that matches, else Esc
+			n, key := 0, int16(keyboard.Esc)
+			for seq, code := range escapes {
+				if len(seq) > n && string(buf[i+1:min(i+1+len(seq), len(buf))]) == seq {
+					n, key = len(seq), code
+				}
+			}
+			if n == 0 && i+1 < len(buf) && (buf[i+1] == '[' || buf[i+1] == 'O') {
+				// an unknown sequence: drop it up to its final byte
+				for i += 2; i < len(buf) && (buf[i] < 0x40 || buf[i] > 0x7e); i++ {
+				}
+				continue
+			}
+			i += n
+			keys = append(keys, key)
+		case 'a' <= b && b <= 'z':
+			keys = append(keys, int16(b-'a'+'A'))
+		case ' ' <= b && b < 0x7f:
+			keys = append(keys, int16(b))
+		}
+	}
+
+	return keys
+}
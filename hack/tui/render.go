@@ -0,0 +1,116 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"hack"
+)
+
+// Mode is how characters draw the pixels of the screen.
+type Mode int
+
+const (
+	Braille   Mode = iota // 2 by 4 pixels a character
+	HalfBlock             // 1 by 2 pixels a character
+)
+
+// cell returns the pixels that a character of m draws, across and down.
+func (m Mode) cell() (int, int) {
+	if m == HalfBlock {
+		return 1, 2
+	}
+
+	return 2, 4
+}
+
+// Size returns the characters across and down that draw the screen with
+// mode m, when each dot of a character is a block of scale by scale
+// pixels.
+func Size(m Mode, scale int) (int, int) {
+	w, h := m.cell()
+	return hack.ScreenWidth / (w * scale), hack.ScreenHeight / (h * scale)
+}
+
+// braille are the bits of the dots of a braille character, by row and
+// column.
+var braille = [4][2]rune{{0x01, 0x08}, {0x02, 0x10}, {0x04, 0x20}, {0x40, 0x80}}
+
+var halfBlocks = [4]string{" ", "▀", "▄", "█"}
+
+// Screen returns the lines of characters that draw the screen of ram, the
+// RAM of an emulator. A dot is black if a pixel of its block is.
+func Screen(ram []int16, m Mode, scale int) []string {
+	words := ram[hack.Screen : hack.Screen+hack.ScreenWords]
+	pixel := func(x, y int) bool {
+		return words[y*hack.ScreenWidth/16+x/16]&(1<<(x%16)) != 0
+	}
+	dot := func(x, y int) bool {
+		for j := 0; j < scale; j++ {
+			for i := 0; i < scale; i++ {
+				if pixel(x*scale+i, y*scale+j) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	cols, rows := Size(m, scale)
+	lines := make([]string, rows)
+	var b strings.Builder
+	for row := 0; row < rows; row++ {
+		b.Reset()
+		for col := 0; col < cols; col++ {
+			if m == HalfBlock {
+				i := 0
+				if dot(col, 2*row) {
+					i |= 1
+				}
+				if dot(col, 2*row+1) {
+					i |= 2
+				}
+				b.WriteString(halfBlocks[i])
+				continue
+			}
+			r := rune(0x2800)
+			for y := 0; y < 4; y++ {
+				for x := 0; x < 2; x++ {
+					if dot(2*col+x, 4*row+y) {
+						r |= braille[y][x]
+					}
+				}
+			}
+			b.WriteRune(r)
+		}
+		lines[row] = b.String()
+	}
+
+	return lines
+}
+
+// panelWidth is the width of the panel of registers, in characters.
+const panelWidth = 22
+
+// panel returns the lines of the panel: the status of e, the pointers of
+// the VM, the keyboard and the top of the stack.
+func panel(e Emulator, rows int) []string {
+	ram := e.RAM()
+	lines := append([]string(nil), e.Status()...)
+	lines = append(lines, "")
+	for i, name := range []string{"SP", "LCL", "ARG", "THIS", "THAT"} {
+		lines = append(lines, fmt.Sprintf("%-6v %6d", name, ram[i]))
+	}
+	lines = append(lines, fmt.Sprintf("%-6v %6d", "KBD", ram[hack.KBD]), "", "stack")
+	sp := int(uint16(ram[hack.SP]))
+	for addr := sp - 1; addr >= max(sp-8, 0) && addr < len(ram) && len(lines) < rows; addr-- {
+		lines = append(lines, fmt.Sprintf("%6d %6d", addr, ram[addr]))
+	}
+	for i, line := range lines {
+		if len(line) > panelWidth {
+			lines[i] = line[:panelWidth]
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,176 @@
+// Package tui runs a Hack emulator in a terminal: it draws the screen with
+// braille or half block characters, next to a panel of the registers and
+// the stack, and presses the keys typed on the Hack keyboard, RAM[24576].
+// Terminals only send key presses, so a key is released when it has not
+// been sent again for a while, as it is when the terminal repeats a key
+// that is held down.
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"hack"
+)
+
+// Emulator is the emulator that Run runs, of machine code or VM code.
+type Emulator interface {
+	Step() error
+	Halted() bool
+	Cycles() uint64
+	RAM() []int16
+	Status() []string // lines of registers for the panel
+}
+
+// Options configure Run.
+type Options struct {
+	Mode  Mode
+	Scale int           // pixels of a dot across and down, 0 to fit the terminal
+	Speed uint64        // cycles a second, 0 for as fast as possible
+	Hold  time.Duration // time until a key that is not sent again is released
+}
+
+// frame is the time between two draws of the terminal.
+const frame = time.Second / 30
+
+// Run runs e in the terminal of in and out until Ctrl-C. An error of e
+// stops it, and is shown until then.
+func Run(e Emulator, in, out *os.File, opts Options) error {
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(in.Fd()), state)
+	// the alternate screen, without the cursor
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l\x1b[2J")
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan []int16)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := in.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- decode(buf[:n])
+		}
+	}()
+
+	scale := opts.Scale
+	if scale <= 0 {
+		scale = fit(out, opts.Mode)
+	}
+	if opts.Hold <= 0 {
+		opts.Hold = 150 * time.Millisecond
+	}
+
+	var (
+		d       = &display{out: out}
+		ticker  = time.NewTicker(frame)
+		pressed time.Time // when the key was last sent
+		failure error
+		start   = time.Now()
+		cycles  = e.Cycles()
+	)
+	defer ticker.Stop()
+	for {
+		select {
+		case ks, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			for _, key := range ks {
+				if key == interrupt {
+					return nil
+				}
+				e.RAM()[hack.KBD] = key
+				pressed = time.Now()
+			}
+			continue
+		case <-ticker.C:
+		}
+
+		ram := e.RAM()
+		if ram[hack.KBD] != 0 && time.Since(pressed) > opts.Hold {
+			ram[hack.KBD] = 0
+		}
+
+		// run until the cycles of the speed, or for most of a frame
+		deadline := time.Now().Add(frame * 3 / 4)
+		target := ^uint64(0)
+		if opts.Speed > 0 {
+			target = cycles + uint64(time.Since(start).Seconds()*float64(opts.Speed))
+		}
+		for n := 0; failure == nil && !e.Halted() && e.Cycles() < target; n++ {
+			if n%4096 == 0 && time.Now().After(deadline) {
+				break
+			}
+			failure = e.Step()
+		}
+
+		status := ""
+		switch {
+		case failure != nil:
+			status = failure.Error()
+		case e.Halted():
+			status = "halted"
+		}
+		screen := Screen(ram, opts.Mode, scale)
+		d.draw(screen, panel(e, len(screen)), status+"  Ctrl-C quits")
+	}
+}
+
+// fit returns the smallest scale at which the screen and the panel fit in
+// the terminal of out.
+func fit(out *os.File, m Mode) int {
+	cols, rows, err := term.GetSize(int(out.Fd()))
+	if err != nil {
+		return 2
+	}
+	for scale := 1; scale < 8; scale++ {
+		w, h := Size(m, scale)
+		if w+1+panelWidth <= cols && h+1 <= rows {
+			return scale
+		}
+	}
+
+	return 8
+}
+
+// display draws lines on a terminal, those that changed since the last
+// draw.
+type display struct {
+	out   io.Writer
+	lines []string
+}
+
+// draw draws the screen with the panel on its right, and the status line
+// below.
+func (d *display) draw(screen, panel []string, status string) {
+	lines := make([]string, 0, len(screen)+1)
+	for i, line := range screen {
+		if i < len(panel) {
+			line += " " + panel[i]
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, status)
+
+	var b strings.Builder
+	for i, line := range lines {
+		if i < len(d.lines) && d.lines[i] == line {
+			continue
+		}
+		// the line, then clear the rest of it
+		fmt.Fprintf(&b, "\x1b[%d;1H%v\x1b[K", i+1, line)
+	}
+	d.lines = lines
+	io.WriteString(d.out, b.String())
+}
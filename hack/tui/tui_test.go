@@ -0,0 +1,65 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"hack"
+	"hack/keyboard"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScreen(t *testing.T) {
+	var ram [hack.RAMSize]int16
+	ram[hack.Screen] = 0b1011             // pixels 0, 1 and 3 of row 0
+	ram[hack.Screen+32*3] = 1             // pixel 0 of row 3
+	ram[hack.Screen+32*255+31] = -1 << 15 // the last pixel
+
+	lines := Screen(ram[:], Braille, 1)
+	assert.Len(t, lines, 64)
+	assert.Equal(t, 256, len([]rune(lines[0])))
+	assert.Equal(t, "⡉⠈⠀", string([]rune(lines[0])[:3]))
+	assert.Equal(t, '⢀', []rune(lines[63])[255])
+
+	lines = Screen(ram[:], Braille, 2)
+	assert.Len(t, lines, 32)
+	assert.Equal(t, "⠋⠀", string([]rune(lines[0])[:2]))
+
+	lines = Screen(ram[:], HalfBlock, 1)
+	assert.Len(t, lines, 128)
+	assert.Equal(t, "▀▀ ▀ ", string([]rune(lines[0])[:5]))
+	assert.Equal(t, "▄ ", string([]rune(lines[1])[:2]))
+	assert.Equal(t, "▄", string([]rune(lines[127])[511:]))
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []int16
+	}{
+		{"1. letters are upper case", "qZ1 ", []int16{'Q', 'Z', '1', ' '}},
+		{"2. arrows", "\x1b[A\x1b[B\x1bOC\x1b[D", []int16{keyboard.Up, keyboard.Down, keyboard.Right, keyboard.Left}},
+		{"3. special keys", "\r\x7f\x1b[3~\x1b[15~\x1bOP", []int16{keyboard.Newline, keyboard.Backspace, keyboard.Delete, keyboard.F1 + 4, keyboard.F1}},
+		{"4. escape alone", "\x1b", []int16{keyboard.Esc}},
+		{"5. unknown sequence", "\x1b[99;5Xa", []int16{'A'}},
+		{"6. Ctrl-C", "a\x03", []int16{'A', interrupt}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, decode([]byte(tt.in)))
+		})
+	}
+}
+
+func TestDisplay(t *testing.T) {
+	var out strings.Builder
+	d := &display{out: &out}
+	d.draw([]string{"ab", "cd"}, []string{"PC 1"}, "halted")
+	assert.Equal(t, "\x1b[1;1Hab PC 1\x1b[K\x1b[2;1Hcd\x1b[K\x1b[3;1Hhalted\x1b[K", out.String())
+
+	out.Reset()
+	d.draw([]string{"ab", "ce"}, []string{"PC 1"}, "halted")
+	assert.Equal(t, "\x1b[2;1Hce\x1b[K", out.String())
+}
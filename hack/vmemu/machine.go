@@ -0,0 +1,267 @@
+package vmemu
+
+import (
+	"fmt"
+
+	"hack"
+)
+
+// Machine runs a program. The RAM and the commands are those of the Hack
+// computer and of the VM translator: a call pushes the return address and
+// the LCL, ARG, THIS and THAT pointers of the caller. Return addresses are
+// indexes in Program.Code.
+type Machine struct {
+	Program *Program
+	RAM     [hack.RAMSize]int16
+	PC      int    // next command
+	Steps   uint64 // commands run so far
+}
+
+// New returns a machine ready to run p. If p has a Sys.init function, it
+// is called with the stack at 256 as by the bootstrap code of the VM
+// translator, and the machine halts when it returns. Otherwise the machine
+// starts at the first command with the stack at 256, and halts after the
+// last command.
+func New(p *Program) *Machine {
+	m := &Machine{Program: p}
+	m.RAM[hack.SP] = hack.Stack
+	if init, ok := p.Functions["Sys.init"]; ok {
+		m.call(len(p.Code), init, 0)
+	}
+
+	return m
+}
+
+// Clone returns a copy of m, which runs on its own. The program, which
+// does not change, is shared.
+func (m *Machine) Clone() *Machine {
+	clone := *m
+	return &clone
+}
+
+// Halted reports whether the machine ran past the last command, or
+// returned from Sys.init.
+func (m *Machine) Halted() bool {
+	return m.PC >= len(m.Program.Code)
+}
+
+// Next returns the command that Step runs.
+func (m *Machine) Next() Command {
+	return m.Program.Code[m.PC]
+}
+
+// Run steps until the machine halts, fails or ran n commands.
+func (m *Machine) Run(n uint64) error {
+	for ; n > 0 && !m.Halted(); n-- {
+		if err := m.Step(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Step runs the next command. It fails on accesses outside the RAM, and
+// leaves the machine unchanged then.
+func (m *Machine) Step() error {
+	if m.Halted() {
+		return fmt.Errorf("machine halted")
+	}
+
+	cmd := m.Program.Code[m.PC]
+	next := m.PC + 1
+	sp := int(m.RAM[hack.SP])
+	switch cmd.Op {
+	case Push:
+		v, err := m.read(cmd)
+		if err == nil {
+			err = m.check(sp)
+		}
+		if err != nil {
+			return m.error(err)
+		}
+		m.RAM[sp] = v
+		m.RAM[hack.SP]++
+	case Pop:
+		addr, err := m.Address(cmd)
+		if err == nil {
+			err = m.check(sp - 1)
+		}
+		if err != nil {
+			return m.error(err)
+		}
+		m.RAM[addr] = m.RAM[sp-1]
+		m.RAM[hack.SP]--
+	case Neg, Not:
+		if err := m.check(sp - 1); err != nil {
+			return m.error(err)
+		}
+		if cmd.Op == Neg {
+			m.RAM[sp-1] = -m.RAM[sp-1]
+		} else {
+			m.RAM[sp-1] = ^m.RAM[sp-1]
+		}
+	case Add, Sub, Eq, Gt, Lt, And, Or:
+		if err := m.check(sp - 2); err != nil {
+			return m.error(err)
+		}
+		x, y := m.RAM[sp-2], m.RAM[sp-1]
+		m.RAM[sp-2] = binary(cmd.Op, x, y)
+		m.RAM[hack.SP]--
+	case Label:
+	case Goto:
+		next = cmd.Target
+	case IfGoto:
+		if err := m.check(sp - 1); err != nil {
+			return m.error(err)
+		}
+		if m.RAM[sp-1] != 0 {
+			next = cmd.Target
+		}
+		m.RAM[hack.SP]--
+	case Function:
+		if err := m.check(sp + cmd.Index - 1); err != nil {
+			return m.error(err)
+		}
+		for i := 0; i < cmd.Index; i++ {
+			m.RAM[sp+i] = 0
+		}
+		m.RAM[hack.SP] += int16(cmd.Index)
+	case Call:
+		if err := m.check(sp + 4); err != nil {
+			return m.error(err)
+		}
+		if sp-cmd.Index < 0 {
+			return m.error(fmt.Errorf("stack underflow"))
+		}
+		m.call(next, cmd.Target, cmd.Index)
+		next = m.PC
+	case Return:
+		frame := int(m.RAM[hack.LCL])
+		arg := int(m.RAM[hack.ARG])
+		if err := m.check(frame - 5); err != nil {
+			return m.error(err)
+		}
+		if err := m.check(arg); err != nil {
+			return m.error(err)
+		}
+		if err := m.check(sp - 1); err != nil {
+			return m.error(err)
+		}
+		next = int(m.RAM[frame-5])
+		if next < 0 || next > len(m.Program.Code) {
+			return m.error(fmt.Errorf("invalid return address %d", next))
+		}
+		m.RAM[arg] = m.RAM[sp-1]
+		m.RAM[hack.SP] = int16(arg + 1)
+		m.RAM[hack.THAT] = m.RAM[frame-1]
+		m.RAM[hack.THIS] = m.RAM[frame-2]
+		m.RAM[hack.ARG] = m.RAM[frame-3]
+		m.RAM[hack.LCL] = m.RAM[frame-4]
+	}
+
+	m.PC = next
+	m.Steps++
+	return nil
+}
+
+// call pushes the frame of a call to the function at command target with
+// nArgs arguments on the stack, and jumps to it.
+func (m *Machine) call(ret, target, nArgs int) {
+	sp := int(m.RAM[hack.SP])
+	m.RAM[sp] = int16(ret)
+	m.RAM[sp+1] = m.RAM[hack.LCL]
+	m.RAM[sp+2] = m.RAM[hack.ARG]
+	m.RAM[sp+3] = m.RAM[hack.THIS]
+	m.RAM[sp+4] = m.RAM[hack.THAT]
+	m.RAM[hack.ARG] = int16(sp - nArgs)
+	m.RAM[hack.LCL] = int16(sp + 5)
+	m.RAM[hack.SP] = int16(sp + 5)
+	m.PC = target
+}
+
+func binary(op Op, x, y int16) int16 {
+	switch op {
+	case Add:
+		return x + y
+	case Sub:
+		return x - y
+	case And:
+		return x & y
+	case Or:
+		return x | y
+	case Eq:
+		return boolean(x == y)
+	case Gt:
+		return boolean(x > y)
+	}
+
+	return boolean(x < y)
+}
+
+func boolean(b bool) int16 {
+	if b {
+		return -1
+	}
+
+	return 0
+}
+
+// read returns the segment word of a push.
+func (m *Machine) read(cmd Command) (int16, error) {
+	if cmd.Segment == Constant {
+		return int16(cmd.Index), nil
+	}
+	addr, err := m.Address(cmd)
+	if err != nil {
+		return 0, err
+	}
+
+	return m.RAM[addr], nil
+}
+
+// Address returns the RAM address of the segment word of a push or pop
+// command, as the machine is. It fails if the address is outside the RAM.
+func (m *Machine) Address(cmd Command) (int, error) {
+	var addr int
+	switch cmd.Segment {
+	case Local:
+		addr = int(m.RAM[hack.LCL]) + cmd.Index
+	case Argument:
+		addr = int(m.RAM[hack.ARG]) + cmd.Index
+	case This:
+		addr = int(m.RAM[hack.THIS]) + cmd.Index
+	case That:
+		addr = int(m.RAM[hack.THAT]) + cmd.Index
+	case Pointer:
+		addr = hack.THIS + cmd.Index
+	case Temp:
+		addr = hack.Temp + cmd.Index
+	case Static:
+		addr = m.Program.Statics[cmd.File] + cmd.Index
+	}
+
+	return addr, m.check(addr)
+}
+
+func (m *Machine) check(addr int) error {
+	if addr < 0 || addr >= hack.RAMSize {
+		return fmt.Errorf("address %d out of range", addr)
+	}
+
+	return nil
+}
+
+func (m *Machine) error(err error) error {
+	return fmt.Errorf("%v: %v", m.Program.Position(m.PC), err)
+}
+
+// Function returns the name of the function that runs, "" if there is
+// none.
+func (m *Machine) Function() string {
+	if m.Halted() {
+		return ""
+	}
+
+	return m.Program.Function(m.PC)
+}
@@ -0,0 +1,249 @@
+package vmemu_test
+
+import (
+	"strings"
+	"testing"
+
+	"hack"
+	"hack/vmemu"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func load(t *testing.T, files ...string) *vmemu.Machine {
+	t.Helper()
+	var fs []vmemu.File
+	for i := 0; i+1 < len(files); i += 2 {
+		fs = append(fs, vmemu.File{Name: files[i], Src: []byte(files[i+1])})
+	}
+	p, err := vmemu.Load(fs...)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return vmemu.New(p)
+}
+
+func TestMachine_Run(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  map[int]int16 // RAM after the run
+	}{
+		{
+			"1. arithmetic without functions",
+			[]string{"Main", `
+				push constant 7
+				push constant 8
+				add
+				push constant 3
+				sub
+				neg
+				push constant 2
+				push constant 3
+				lt
+				push constant 5
+				push constant 5
+				eq
+				and
+				not`},
+			map[int]int16{hack.SP: 258, 256: -12, 257: 0},
+		},
+		{
+			"2. loop on labels",
+			[]string{"Main", `
+				push constant 0
+				pop temp 0
+				push constant 5
+				pop temp 1
+			label LOOP
+				push temp 1
+				if-goto BODY
+				goto END
+			label BODY
+				push temp 0
+				push temp 1
+				add
+				pop temp 0
+				push temp 1
+				push constant 1
+				sub
+				pop temp 1
+				goto LOOP
+			label END`},
+			map[int]int16{hack.SP: 256, 5: 15, 6: 0},
+		},
+		{
+			"3. calls from Sys.init",
+			[]string{"Sys", `
+				function Sys.init 0
+				push constant 6
+				call Main.fib 1
+				pop temp 0
+				push constant 0
+				return`,
+				"Main", `
+				function Main.fib 0
+				push argument 0
+				push constant 2
+				lt
+				if-goto BASE
+				push argument 0
+				push constant 1
+				sub
+				call Main.fib 1
+				push argument 0
+				push constant 2
+				sub
+				call Main.fib 1
+				add
+				return
+			label BASE
+				push argument 0
+				return`},
+			map[int]int16{hack.SP: 257, 256: 0, 5: 8},
+		},
+		{
+			"4. statics of each file",
+			[]string{"A", `
+				function A.set 0
+				push constant 1
+				pop static 0
+				push constant 2
+				pop static 1
+				push constant 0
+				return`,
+				"Sys", `
+				function Sys.init 0
+				call A.set 0
+				pop temp 0
+				push constant 3
+				pop static 0
+				push constant 0
+				return`},
+			map[int]int16{16: 1, 17: 2, 18: 3},
+		},
+		{
+			"5. this and that",
+			[]string{"Main", `
+				push constant 3000
+				pop pointer 0
+				push constant 4000
+				pop pointer 1
+				push constant 10
+				pop this 2
+				push constant 20
+				pop that 3
+				push this 2
+				push that 3
+				add
+				pop temp 0`},
+			map[int]int16{3000 + 2: 10, 4000 + 3: 20, 5: 30},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := load(t, tt.files...)
+			assert.NoError(t, m.Run(1000))
+			assert.True(t, m.Halted())
+			for addr, want := range tt.want {
+				assert.Equal(t, want, m.RAM[addr], "RAM[%d]", addr)
+			}
+		})
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"1. unknown command", []string{"Main", "push constant 1\nmul"}, `Main.vm:2: unknown command "mul"`},
+		{"2. unknown segment", []string{"Main", "push stack 1"}, `Main.vm:1: unknown segment "stack"`},
+		{"3. pop constant", []string{"Main", "pop constant 1"}, "Main.vm:1: cannot pop to constant"},
+		{"4. temp out of range", []string{"Main", "pop temp 8"}, "Main.vm:1: temp 8 out of range"},
+		{"5. missing argument", []string{"Main", "goto"}, "Main.vm:1: goto takes 1 arguments"},
+		{"6. undefined label", []string{"Main", "function Main.f 0\ngoto L\nfunction Main.g 0\nlabel L"}, "Main.vm:2: undefined label L"},
+		{"7. undefined function", []string{"Main", "call Main.g 0"}, "Main.vm:1: undefined function Main.g"},
+		{"8. function defined twice", []string{"A", "function Main.f 0", "B", "function Main.f 0"}, "B.vm:1: function Main.f is already defined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fs []vmemu.File
+			for i := 0; i+1 < len(tt.files); i += 2 {
+				fs = append(fs, vmemu.File{Name: tt.files[i], Src: []byte(tt.files[i+1])})
+			}
+			_, err := vmemu.Load(fs...)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestMachine_Step(t *testing.T) {
+	m := load(t, "Main", strings.Join([]string{
+		"function Main.f 1",
+		"push constant 1000",
+		"neg",
+		"pop pointer 1",
+		"push that 0",
+	}, "\n"))
+	assert.NoError(t, m.Run(4))
+	assert.Equal(t, "Main.f", m.Function())
+	assert.Equal(t, "push that 0", m.Next().String())
+
+	err := m.Step()
+	assert.EqualError(t, err, "Main.vm:5: address -1000 out of range")
+	assert.Equal(t, 4, m.PC)
+	assert.Equal(t, uint64(4), m.Steps)
+}
+
+func TestMachine_Clone(t *testing.T) {
+	m := load(t, "Main", `
+		function Main.main 0
+		push constant 1
+		push constant 2
+		add
+		pop static 0`)
+	assert.NoError(t, m.Run(2))
+	clone := m.Clone()
+	assert.NoError(t, clone.Run(10))
+	assert.Equal(t, int16(3), clone.RAM[16])
+	assert.Equal(t, int16(0), m.RAM[16])
+	assert.Equal(t, 2, m.PC)
+	assert.Same(t, m.Program, clone.Program)
+}
+
+func TestProgram_Source(t *testing.T) {
+	files := []vmemu.File{
+		{Name: "Main", Src: []byte(`
+			// counts down from 3
+			function Main.main 1
+				push constant 3 // the count
+				pop local 0
+			label LOOP
+				push local 0
+				if-goto BODY
+				return
+			label BODY
+				push local 0
+				push constant 1
+				sub
+				pop static 0
+				call Main.f 0
+				goto LOOP`)},
+		{Name: "Empty", Src: []byte("// nothing")},
+		{Name: "F", Src: []byte(`
+			function Main.f 0
+				push static 1
+				return`)},
+	}
+	p, err := vmemu.Load(files...)
+	assert.NoError(t, err)
+	source := p.Source()
+	assert.True(t, strings.HasPrefix(string(source[0].Src), "\n\nfunction Main.main 1\npush constant 3\npop local 0\nlabel LOOP\n"))
+	assert.Equal(t, "", string(source[1].Src))
+	q, err := vmemu.Load(source...)
+	assert.NoError(t, err)
+	assert.Equal(t, p, q)
+}
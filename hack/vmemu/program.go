@@ -0,0 +1,358 @@
+// Package vmemu runs programs of the VM language on the memory of the Hack
+// computer, with the stack frames and segments that the VM translator of
+// project 8 uses. There is no built-in OS: the OS is loaded as VM code,
+// like the compiled project 12 classes.
+package vmemu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Op is the operation of a VM command.
+type Op int
+
+// The VM commands.
+const (
+	Push Op = iota
+	Pop
+	Add
+	Sub
+	Neg
+	Eq
+	Gt
+	Lt
+	And
+	Or
+	Not
+	Label
+	Goto
+	IfGoto
+	Function
+	Call
+	Return
+)
+
+var ops = [...]string{
+	Push:     "push",
+	Pop:      "pop",
+	Add:      "add",
+	Sub:      "sub",
+	Neg:      "neg",
+	Eq:       "eq",
+	Gt:       "gt",
+	Lt:       "lt",
+	And:      "and",
+	Or:       "or",
+	Not:      "not",
+	Label:    "label",
+	Goto:     "goto",
+	IfGoto:   "if-goto",
+	Function: "function",
+	Call:     "call",
+	Return:   "return",
+}
+
+func (op Op) String() string {
+	if 0 <= op && op < Op(len(ops)) {
+		return ops[op]
+	}
+
+	return "op(" + strconv.Itoa(int(op)) + ")"
+}
+
+// Segment is a memory segment of push and pop.
+type Segment int
+
+// The memory segments.
+const (
+	Constant Segment = iota
+	Local
+	Argument
+	This
+	That
+	Pointer
+	Temp
+	Static
+)
+
+var segments = [...]string{
+	Constant: "constant",
+	Local:    "local",
+	Argument: "argument",
+	This:     "this",
+	That:     "that",
+	Pointer:  "pointer",
+	Temp:     "temp",
+	Static:   "static",
+}
+
+func (seg Segment) String() string {
+	if 0 <= seg && seg < Segment(len(segments)) {
+		return segments[seg]
+	}
+
+	return "segment(" + strconv.Itoa(int(seg)) + ")"
+}
+
+// Command is a VM command.
+type Command struct {
+	Op      Op
+	Segment Segment // of push and pop
+	Index   int     // of push and pop, locals of function, arguments of call
+	Name    string  // label, function or called function
+	Target  int     // command that goto, if-goto and call jump to
+	File    int     // index of the file in Program.Files
+	Line    int     // line in the file, starting at 1
+}
+
+func (c Command) String() string {
+	switch c.Op {
+	case Push, Pop:
+		return fmt.Sprintf("%v %v %d", c.Op, c.Segment, c.Index)
+	case Function, Call:
+		return fmt.Sprintf("%v %v %d", c.Op, c.Name, c.Index)
+	case Label, Goto, IfGoto:
+		return c.Op.String() + " " + c.Name
+	}
+
+	return c.Op.String()
+}
+
+// File is the VM code of a .vm file. Its name, without the extension,
+// qualifies its static variables.
+type File struct {
+	Name string
+	Src  []byte
+}
+
+// Program is the linked code of one or more files.
+type Program struct {
+	Code      []Command
+	Files     []string
+	Functions map[string]int // command of each function
+	Statics   []int          // address of static 0 of each file
+}
+
+// maxCode is the largest number of commands: a return address must fit in
+// a word.
+const maxCode = 1<<15 - 1
+
+// Load parses and links files. Labels are local to the function that
+// defines them, or to the file if it has no functions. Static variables
+// are allocated from address 16 on, file after file.
+func Load(files ...File) (*Program, error) {
+	p := &Program{Functions: make(map[string]int)}
+	static := 16
+	for i, f := range files {
+		cmds, err := parse(f, i)
+		if err != nil {
+			return nil, err
+		}
+		p.Files = append(p.Files, f.Name)
+		p.Statics = append(p.Statics, static)
+		for _, cmd := range cmds {
+			if (cmd.Op == Push || cmd.Op == Pop) && cmd.Segment == Static {
+				static = max(static, p.Statics[i]+cmd.Index+1)
+			}
+		}
+		p.Code = append(p.Code, cmds...)
+	}
+	if len(p.Code) > maxCode {
+		return nil, fmt.Errorf("program has %d commands, more than %d", len(p.Code), maxCode)
+	}
+
+	return p, p.link()
+}
+
+// LoadDir loads the .vm files of dir, in the order of their names.
+func LoadDir(dir string) (*Program, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.vm"))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]File, 0, len(names))
+	for _, name := range names {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{strings.TrimSuffix(filepath.Base(name), ".vm"), src})
+	}
+
+	return Load(files...)
+}
+
+// Source returns the files of p, with each command on its line, which
+// load to the same program. Comments are gone.
+func (p *Program) Source() []File {
+	lines := make([][]string, len(p.Files))
+	for _, cmd := range p.Code {
+		for len(lines[cmd.File]) < cmd.Line {
+			lines[cmd.File] = append(lines[cmd.File], "")
+		}
+		lines[cmd.File][cmd.Line-1] = cmd.String()
+	}
+	files := make([]File, len(p.Files))
+	for i, name := range p.Files {
+		files[i] = File{name, []byte(strings.Join(lines[i], "\n"))}
+	}
+
+	return files
+}
+
+func parse(f File, file int) ([]Command, error) {
+	var cmds []Command
+	for i, line := range strings.Split(string(f.Src), "\n") {
+		if j := strings.Index(line, "//"); j >= 0 {
+			line = line[:j]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		cmd, err := parseCommand(fields)
+		if err != nil {
+			return nil, fmt.Errorf("%v.vm:%d: %v", f.Name, i+1, err)
+		}
+		cmd.File, cmd.Line = file, i+1
+		cmds = append(cmds, cmd)
+	}
+
+	return cmds, nil
+}
+
+func parseCommand(fields []string) (Command, error) {
+	var cmd Command
+	op := -1
+	for i, name := range ops {
+		if name == fields[0] {
+			op = i
+		}
+	}
+	if op < 0 {
+		return cmd, fmt.Errorf("unknown command %q", fields[0])
+	}
+	cmd.Op = Op(op)
+
+	nArgs := 0
+	switch cmd.Op {
+	case Push, Pop, Function, Call:
+		nArgs = 2
+	case Label, Goto, IfGoto:
+		nArgs = 1
+	}
+	if len(fields) != nArgs+1 {
+		return cmd, fmt.Errorf("%v takes %d arguments", cmd.Op, nArgs)
+	}
+	if nArgs == 0 {
+		return cmd, nil
+	}
+
+	cmd.Name = fields[1]
+	if nArgs == 1 {
+		return cmd, nil
+	}
+	n, err := strconv.Atoi(fields[2])
+	if err != nil || n < 0 || n > maxCode {
+		return cmd, fmt.Errorf("invalid number %q", fields[2])
+	}
+	cmd.Index = n
+	if cmd.Op == Function || cmd.Op == Call {
+		return cmd, nil
+	}
+
+	seg := -1
+	for i, name := range segments {
+		if name == cmd.Name {
+			seg = i
+		}
+	}
+	cmd.Name = ""
+	cmd.Segment = Segment(seg)
+	switch {
+	case seg < 0:
+		return cmd, fmt.Errorf("unknown segment %q", fields[1])
+	case cmd.Op == Pop && cmd.Segment == Constant:
+		return cmd, fmt.Errorf("cannot pop to constant")
+	case cmd.Segment == Pointer && n > 1, cmd.Segment == Temp && n > 7:
+		return cmd, fmt.Errorf("%v %d out of range", cmd.Segment, n)
+	}
+
+	return cmd, nil
+}
+
+// link resolves the targets of goto, if-goto and call.
+func (p *Program) link() error {
+	for i, cmd := range p.Code {
+		if cmd.Op != Function {
+			continue
+		}
+		if _, ok := p.Functions[cmd.Name]; ok {
+			return p.errorf(i, "function %v is already defined", cmd.Name)
+		}
+		p.Functions[cmd.Name] = i
+	}
+
+	labels := make(map[string]int)
+	start := 0
+	for i := range p.Code {
+		cmd := p.Code[i]
+		if i == start {
+			clear(labels)
+			end := i + 1
+			for end < len(p.Code) && p.Code[end].Op != Function && p.Code[end].File == cmd.File {
+				end++
+			}
+			for j := i; j < end; j++ {
+				if p.Code[j].Op == Label {
+					labels[p.Code[j].Name] = j
+				}
+			}
+			start = end
+		}
+
+		switch cmd.Op {
+		case Goto, IfGoto:
+			target, ok := labels[cmd.Name]
+			if !ok {
+				return p.errorf(i, "undefined label %v", cmd.Name)
+			}
+			p.Code[i].Target = target
+		case Call:
+			target, ok := p.Functions[cmd.Name]
+			if !ok {
+				return p.errorf(i, "undefined function %v", cmd.Name)
+			}
+			p.Code[i].Target = target
+		}
+	}
+
+	return nil
+}
+
+func (p *Program) errorf(i int, format string, args ...interface{}) error {
+	return fmt.Errorf("%v: %v", p.Position(i), fmt.Sprintf(format, args...))
+}
+
+// Position returns the file and line of command i, as Main.vm:12.
+func (p *Program) Position(i int) string {
+	cmd := p.Code[i]
+	return fmt.Sprintf("%v.vm:%d", p.Files[cmd.File], cmd.Line)
+}
+
+// Function returns the name of the function that contains command i, ""
+// if it is not in a function.
+func (p *Program) Function(i int) string {
+	for file := p.Code[i].File; i >= 0 && p.Code[i].File == file; i-- {
+		if p.Code[i].Op == Function {
+			return p.Code[i].Name
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,339 @@
+package jack
+
+// Node is implemented by every node of the syntax tree.
+type Node interface {
+	Pos() Pos
+}
+
+// Stmt is implemented by statement nodes.
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Expr is implemented by expression nodes.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// Comment is a // or /* */ comment.
+type Comment struct {
+	Slash Pos
+	Text  string
+}
+
+// Ident is a class, subroutine, variable or type name.
+// Primitive types (int, char, boolean, void) are idents too.
+type Ident struct {
+	NamePos Pos
+	Name    string
+}
+
+// ClassDecl is the root of a .jack file.
+type ClassDecl struct {
+	Class       Pos // position of 'class'
+	Name        *Ident
+	Vars        []*ClassVarDec
+	Subroutines []*SubroutineDecl
+	Rbrace      Pos
+	Comments    []*Comment // all comments of the file, in source order
+}
+
+// ClassVarDec is a static or field declaration.
+type ClassVarDec struct {
+	KindPos Pos
+	Kind    string // static or field
+	Type    *Ident
+	Names   []*Ident
+}
+
+// SubroutineDecl is a constructor, function or method declaration.
+type SubroutineDecl struct {
+	KindPos    Pos
+	Kind       string // constructor, function or method
+	ReturnType *Ident
+	Name       *Ident
+	Params     []*Param
+	Vars       []*VarDec
+	Body       []Stmt
+	Rbrace     Pos
+}
+
+// Param is a subroutine parameter.
+type Param struct {
+	Type *Ident
+	Name *Ident
+}
+
+// VarDec is a local variable declaration.
+type VarDec struct {
+	Var   Pos
+	Type  *Ident
+	Names []*Ident
+}
+
+// Statements.
+type (
+	// LetStmt is 'let' name ('[' index ']')? '=' value ';', or an
+	// assignment of a for statement, without 'let' and ';'.
+	LetStmt struct {
+		Let   Pos // position of 'let', or of name without 'let'
+		Name  *Ident
+		Index Expr   // nil if not an array assignment
+		Op    string // operator of a compound assignment, as + for +=
+		Value Expr
+	}
+
+	// IfStmt is 'if' '(' cond ')' '{' then '}' ('else' '{' else '}')?.
+	IfStmt struct {
+		If   Pos
+		Cond Expr
+		Then []Stmt
+		Else Pos // position of 'else', invalid if there is no else
+		Alt  []Stmt
+	}
+
+	// WhileStmt is 'while' '(' cond ')' '{' body '}'.
+	WhileStmt struct {
+		While Pos
+		Cond  Expr
+		Body  []Stmt
+	}
+
+	// ForStmt is 'for' '(' init? ';' cond? ';' post? ')' '{' body '}'.
+	ForStmt struct {
+		For  Pos
+		Init *LetStmt // nil if omitted
+		Cond Expr     // nil if omitted, the loop then only ends by break
+		Post *LetStmt // nil if omitted
+		Body []Stmt
+	}
+
+	// BranchStmt is 'break' ';' or 'continue' ';'.
+	BranchStmt struct {
+		TokPos Pos
+		Tok    string // break or continue
+	}
+
+	// DoStmt is 'do' call ';'.
+	DoStmt struct {
+		Do   Pos
+		Call *CallExpr
+	}
+
+	// ReturnStmt is 'return' value? ';'.
+	ReturnStmt struct {
+		Return Pos
+		Value  Expr // nil for void subroutines
+	}
+)
+
+// Expressions. A variable reference is an *Ident.
+type (
+	// IntLit is an integer constant, in decimal. Values above 32767 come
+	// from hexadecimal or binary constants and are the 16-bit patterns of
+	// negative numbers.
+	IntLit struct {
+		ValuePos Pos
+		Value    string
+	}
+
+	// StringLit is a string constant, without the quotes.
+	StringLit struct {
+		ValuePos Pos
+		Value    string
+	}
+
+	// KeywordLit is true, false, null or this.
+	KeywordLit struct {
+		ValuePos Pos
+		Value    string
+	}
+
+	// IndexExpr is name '[' index ']'.
+	IndexExpr struct {
+		Name  *Ident
+		Index Expr
+	}
+
+	// CallExpr is (receiver '.')? name '(' args ')'. The receiver is a
+	// variable for method calls and a class name for function calls.
+	CallExpr struct {
+		Receiver *Ident // nil for calls of methods of the current class
+		Name     *Ident
+		Args     []Expr
+	}
+
+	// UnaryExpr is op x, op is - or ~.
+	UnaryExpr struct {
+		OpPos Pos
+		Op    string
+		X     Expr
+	}
+
+	// BinaryExpr is x op y. Jack has no precedence, so a op b op c is
+	// parsed as (a op b) op c.
+	BinaryExpr struct {
+		X     Expr
+		OpPos Pos
+		Op    string
+		Y     Expr
+	}
+
+	// ParenExpr is '(' x ')'.
+	ParenExpr struct {
+		Lparen Pos
+		X      Expr
+	}
+
+	// BadExpr is a placeholder for an expression with syntax errors.
+	BadExpr struct {
+		From Pos
+	}
+)
+
+func (x *Ident) Pos() Pos          { return x.NamePos }
+func (x *ClassDecl) Pos() Pos      { return x.Class }
+func (x *ClassVarDec) Pos() Pos    { return x.KindPos }
+func (x *SubroutineDecl) Pos() Pos { return x.KindPos }
+func (x *Param) Pos() Pos          { return x.Type.Pos() }
+func (x *VarDec) Pos() Pos         { return x.Var }
+
+func (s *LetStmt) Pos() Pos    { return s.Let }
+func (s *IfStmt) Pos() Pos     { return s.If }
+func (s *WhileStmt) Pos() Pos  { return s.While }
+func (s *ForStmt) Pos() Pos    { return s.For }
+func (s *BranchStmt) Pos() Pos { return s.TokPos }
+func (s *DoStmt) Pos() Pos     { return s.Do }
+func (s *ReturnStmt) Pos() Pos { return s.Return }
+
+func (x *IntLit) Pos() Pos     { return x.ValuePos }
+func (x *StringLit) Pos() Pos  { return x.ValuePos }
+func (x *KeywordLit) Pos() Pos { return x.ValuePos }
+func (x *IndexExpr) Pos() Pos  { return x.Name.Pos() }
+func (x *UnaryExpr) Pos() Pos  { return x.OpPos }
+func (x *BinaryExpr) Pos() Pos { return x.X.Pos() }
+func (x *ParenExpr) Pos() Pos  { return x.Lparen }
+func (x *BadExpr) Pos() Pos    { return x.From }
+func (x *CallExpr) Pos() Pos {
+	if x.Receiver != nil {
+		return x.Receiver.Pos()
+	}
+	return x.Name.Pos()
+}
+
+func (*LetStmt) stmtNode()    {}
+func (*IfStmt) stmtNode()     {}
+func (*WhileStmt) stmtNode()  {}
+func (*ForStmt) stmtNode()    {}
+func (*BranchStmt) stmtNode() {}
+func (*DoStmt) stmtNode()     {}
+func (*ReturnStmt) stmtNode() {}
+
+func (*Ident) exprNode()      {}
+func (*IntLit) exprNode()     {}
+func (*StringLit) exprNode()  {}
+func (*KeywordLit) exprNode() {}
+func (*IndexExpr) exprNode()  {}
+func (*CallExpr) exprNode()   {}
+func (*UnaryExpr) exprNode()  {}
+func (*BinaryExpr) exprNode() {}
+func (*ParenExpr) exprNode()  {}
+func (*BadExpr) exprNode()    {}
+
+// Inspect traverses the tree in depth-first order. It calls f(node) for
+// each node and only visits the children of node if f returns true.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *ClassDecl:
+		inspect(f, n.Name)
+		for _, v := range n.Vars {
+			Inspect(v, f)
+		}
+		for _, s := range n.Subroutines {
+			Inspect(s, f)
+		}
+	case *ClassVarDec:
+		inspect(f, n.Type)
+		inspect(f, n.Names...)
+	case *SubroutineDecl:
+		inspect(f, n.ReturnType, n.Name)
+		for _, param := range n.Params {
+			Inspect(param, f)
+		}
+		for _, v := range n.Vars {
+			Inspect(v, f)
+		}
+		inspectStmts(f, n.Body)
+	case *Param:
+		inspect(f, n.Type, n.Name)
+	case *VarDec:
+		inspect(f, n.Type)
+		inspect(f, n.Names...)
+	case *LetStmt:
+		inspect(f, n.Name)
+		inspectExprs(f, n.Index, n.Value)
+	case *IfStmt:
+		inspectExprs(f, n.Cond)
+		inspectStmts(f, n.Then)
+		inspectStmts(f, n.Alt)
+	case *WhileStmt:
+		inspectExprs(f, n.Cond)
+		inspectStmts(f, n.Body)
+	case *ForStmt:
+		if n.Init != nil {
+			Inspect(n.Init, f)
+		}
+		inspectExprs(f, n.Cond)
+		if n.Post != nil {
+			Inspect(n.Post, f)
+		}
+		inspectStmts(f, n.Body)
+	case *DoStmt:
+		if n.Call != nil {
+			Inspect(n.Call, f)
+		}
+	case *ReturnStmt:
+		inspectExprs(f, n.Value)
+	case *IndexExpr:
+		inspect(f, n.Name)
+		inspectExprs(f, n.Index)
+	case *CallExpr:
+		inspect(f, n.Receiver, n.Name)
+		inspectExprs(f, n.Args...)
+	case *UnaryExpr:
+		inspectExprs(f, n.X)
+	case *BinaryExpr:
+		inspectExprs(f, n.X, n.Y)
+	case *ParenExpr:
+		inspectExprs(f, n.X)
+	}
+}
+
+// inspect visits idents, skipping nil ones.
+func inspect(f func(Node) bool, idents ...*Ident) {
+	for _, ident := range idents {
+		if ident != nil {
+			Inspect(ident, f)
+		}
+	}
+}
+
+func inspectStmts(f func(Node) bool, stmts []Stmt) {
+	for _, stmt := range stmts {
+		Inspect(stmt, f)
+	}
+}
+
+func inspectExprs(f func(Node) bool, exprs ...Expr) {
+	for _, expr := range exprs {
+		if expr != nil {
+			Inspect(expr, f)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+// Package jack is the frontend shared by the Jack tools: the scanner, the
+// syntax tree and its parser, and the symbol table. The backends turn a
+// parsed class into XML (package jack/xml), JSON or S-expressions
+// (package jack/tree) or VM code (package jack/vm).
+package jack
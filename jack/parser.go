@@ -0,0 +1,570 @@
+package jack
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Error is a syntax error.
+type Error struct {
+	Pos Pos
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// ErrorList is a list of syntax errors in source order.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, err := range l {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// Err returns an error equivalent to the list, or nil if it is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+
+	return l
+}
+
+// parser builds a syntax tree. It reports syntax errors and keeps going,
+// so tools get a tree for files that are being edited.
+type parser struct {
+	scanner  Scanner
+	tok      Token  // current token
+	lit      string // current value
+	pos      Pos    // current position
+	comments []*Comment
+	errors   ErrorList
+	lexErrs  int // scanner errors already reported
+	mode     Mode
+	loops    int // depth of the loops around the current statement
+}
+
+// Mode selects the language accepted by ParseMode.
+type Mode uint
+
+const (
+	// Extended accepts, besides standard Jack:
+	//   - for '(' init? ';' expression? ';' step? ')' '{' statements '}',
+	//     where init and step are assignments without let
+	//   - else if
+	//   - break ';' and continue ';' in loops
+	//   - compound assignments: let x += y, and -=, *=, /=, &=, |=
+	//   - character constants: 'a', with the escapes of strings
+	//   - hexadecimal and binary constants up to 0xFFFF: 0x7F, 0b1010
+	//   - escapes in strings: \n (128), \b (129), \", \' and \\
+	// Character, hexadecimal and binary constants are parsed into IntLits
+	// of their decimal value, and escapes are replaced in StringLits.
+	// for, break and continue are keywords only at the start of a
+	// statement, so they remain valid names.
+	Extended Mode = 1 << iota
+)
+
+// Parse parses the source of a .jack file. The returned class is never
+// nil, but parts of it are missing when there are syntax errors.
+func Parse(src []byte) (*ClassDecl, ErrorList) {
+	return ParseMode(src, 0)
+}
+
+// ParseMode is Parse for the language selected by mode.
+func ParseMode(src []byte, mode Mode) (*ClassDecl, ErrorList) {
+	p := parser{mode: mode}
+	p.scanner.InitMode(src, mode)
+	p.next()
+
+	class := p.parseClass()
+	class.Comments = p.comments
+
+	return class, p.errors
+}
+
+func (p *parser) next() {
+	for {
+		p.tok, p.lit = p.scanner.Scan()
+		p.pos = p.scanner.Pos()
+		for _, e := range p.scanner.Errors()[p.lexErrs:] {
+			p.error(e.Pos, e.Msg)
+		}
+		p.lexErrs = len(p.scanner.Errors())
+		if p.tok != COMMENT {
+			return
+		}
+		p.comments = append(p.comments, &Comment{
+			Slash: p.pos,
+			Text:  strings.TrimRight(p.lit, "\r\n"),
+		})
+	}
+}
+
+func (p *parser) error(pos Pos, msg string) {
+	// one error per position is enough
+	if n := len(p.errors); n > 0 && p.errors[n-1].Pos == pos {
+		return
+	}
+	p.errors = append(p.errors, &Error{pos, msg})
+}
+
+func (p *parser) errorExpected(what string) {
+	found := "'" + p.lit + "'"
+	if p.tok == EOF {
+		found = "EOF"
+	}
+	p.error(p.pos, fmt.Sprintf("expected %v, found %v", what, found))
+}
+
+// is reports whether the current token is a keyword or symbol equal to lit.
+func (p *parser) is(lit string) bool {
+	return (p.tok == KEYWORD || p.tok == SYMBOL) && p.lit == lit
+}
+
+// isExtension reports whether the current token is the word of an
+// extended statement, which the scanner returns as an identifier.
+func (p *parser) isExtension(word string) bool {
+	return p.mode&Extended != 0 && p.tok == IDENT && p.lit == word
+}
+
+// expect consumes the current token if it is lit and reports an error
+// otherwise. It returns the position of the token.
+func (p *parser) expect(lit string) Pos {
+	pos := p.pos
+	if p.is(lit) {
+		p.next()
+	} else {
+		p.errorExpected("'" + lit + "'")
+	}
+
+	return pos
+}
+
+func (p *parser) parseIdent() *Ident {
+	ident := &Ident{NamePos: p.pos, Name: "_"}
+	if p.tok == IDENT {
+		ident.Name = p.lit
+		p.next()
+	} else {
+		p.errorExpected("identifier")
+	}
+
+	return ident
+}
+
+// parseType parses int, char, boolean, a class name and, when void is
+// true, void.
+func (p *parser) parseType(void bool) *Ident {
+	if p.tok == KEYWORD {
+		switch p.lit {
+		case "int", "char", "boolean":
+		case "void":
+			if !void {
+				p.error(p.pos, "void is only allowed as a return type")
+			}
+		default:
+			p.errorExpected("type")
+			return &Ident{NamePos: p.pos, Name: "_"}
+		}
+		ident := &Ident{NamePos: p.pos, Name: p.lit}
+		p.next()
+		return ident
+	}
+
+	return p.parseIdent()
+}
+
+// 'class' className '{' classVarDec* subroutineDec* '}'
+func (p *parser) parseClass() *ClassDecl {
+	class := &ClassDecl{Class: p.expect("class")}
+	class.Name = p.parseIdent()
+	p.expect("{")
+
+	for p.tok != EOF && !p.is("}") {
+		switch {
+		case p.is("static") || p.is("field"):
+			if len(class.Subroutines) > 0 {
+				p.error(p.pos, "class variables must be declared before subroutines")
+			}
+			class.Vars = append(class.Vars, p.parseClassVarDec())
+		case p.is("constructor") || p.is("function") || p.is("method"):
+			class.Subroutines = append(class.Subroutines, p.parseSubroutine())
+		default:
+			p.errorExpected("class variable or subroutine declaration")
+			p.next()
+		}
+	}
+	class.Rbrace = p.expect("}")
+
+	if p.tok != EOF {
+		p.errorExpected("EOF")
+	}
+
+	return class
+}
+
+// ('static' | 'field') type varName (',' varName)* ';'
+func (p *parser) parseClassVarDec() *ClassVarDec {
+	dec := &ClassVarDec{KindPos: p.pos, Kind: p.lit}
+	p.next()
+	dec.Type = p.parseType(false)
+	dec.Names = p.parseNames()
+
+	return dec
+}
+
+// 'var' type varName (',' varName)* ';'
+func (p *parser) parseVarDec() *VarDec {
+	dec := &VarDec{Var: p.expect("var")}
+	dec.Type = p.parseType(false)
+	dec.Names = p.parseNames()
+
+	return dec
+}
+
+// varName (',' varName)* ';'
+func (p *parser) parseNames() []*Ident {
+	names := []*Ident{p.parseIdent()}
+	for p.is(",") {
+		p.next()
+		names = append(names, p.parseIdent())
+	}
+	p.expect(";")
+
+	return names
+}
+
+// ('constructor' | 'function' | 'method') ('void' | type) subroutineName
+// '(' parameterList ')' '{' varDec* statements '}'
+func (p *parser) parseSubroutine() *SubroutineDecl {
+	sub := &SubroutineDecl{KindPos: p.pos, Kind: p.lit}
+	p.next()
+	sub.ReturnType = p.parseType(true)
+	sub.Name = p.parseIdent()
+
+	p.expect("(")
+	if !p.is(")") {
+		for {
+			param := &Param{Type: p.parseType(false)}
+			param.Name = p.parseIdent()
+			sub.Params = append(sub.Params, param)
+			if !p.is(",") {
+				break
+			}
+			p.next()
+		}
+	}
+	p.expect(")")
+
+	p.expect("{")
+	for p.is("var") {
+		sub.Vars = append(sub.Vars, p.parseVarDec())
+	}
+	sub.Body = p.parseStatements()
+	sub.Rbrace = p.expect("}")
+
+	return sub
+}
+
+// isStatement reports whether the current token starts a statement.
+func (p *parser) isStatement() bool {
+	if p.isExtension("for") || p.isExtension("break") || p.isExtension("continue") {
+		return true
+	}
+	return p.tok == KEYWORD &&
+		(p.lit == "let" || p.lit == "if" || p.lit == "while" ||
+			p.lit == "do" || p.lit == "return")
+}
+
+// isDeclaration reports whether the current token starts a declaration.
+func (p *parser) isDeclaration() bool {
+	return p.tok == KEYWORD &&
+		(p.lit == "static" || p.lit == "field" || p.lit == "constructor" ||
+			p.lit == "function" || p.lit == "method")
+}
+
+// statement*, up to the closing '}'
+func (p *parser) parseStatements() []Stmt {
+	stmts := make([]Stmt, 0)
+	for p.tok != EOF && !p.is("}") && !p.isDeclaration() {
+		if !p.isStatement() {
+			p.errorExpected("statement")
+			// skip to the next statement
+			for p.tok != EOF && !p.is("}") && !p.isStatement() && !p.isDeclaration() {
+				p.next()
+			}
+			continue
+		}
+		stmts = append(stmts, p.parseStatement())
+	}
+
+	return stmts
+}
+
+func (p *parser) parseStatement() Stmt {
+	switch p.lit {
+	case "let":
+		return p.parseLet()
+	case "if":
+		return p.parseIf()
+	case "while":
+		return p.parseWhile()
+	case "do":
+		return p.parseDo()
+	case "for":
+		return p.parseFor()
+	case "break", "continue":
+		return p.parseBranch()
+	}
+
+	return p.parseReturn()
+}
+
+// 'let' varName ('[' expression ']')? '=' expression ';'
+func (p *parser) parseLet() *LetStmt {
+	pos := p.pos
+	p.next()
+	stmt := p.parseAssignment(pos)
+	p.expect(";")
+
+	return stmt
+}
+
+// varName ('[' expression ']')? '=' expression, the operator can be
+// compound in the extended language.
+func (p *parser) parseAssignment(pos Pos) *LetStmt {
+	stmt := &LetStmt{Let: pos}
+	stmt.Name = p.parseIdent()
+	if p.is("[") {
+		p.next()
+		stmt.Index = p.parseExpression()
+		p.expect("]")
+	}
+	if p.mode&Extended != 0 && p.tok == SYMBOL && strings.Contains("+-*/&|", p.lit) {
+		opPos := p.pos
+		stmt.Op = p.lit
+		p.next()
+		if p.pos != (Pos{Line: opPos.Line, Column: opPos.Column + 1}) {
+			p.errorExpected("'='")
+		}
+	}
+	p.expect("=")
+	stmt.Value = p.parseExpression()
+
+	return stmt
+}
+
+// '{' statements '}'
+func (p *parser) parseBlock() []Stmt {
+	p.expect("{")
+	stmts := p.parseStatements()
+	p.expect("}")
+
+	return stmts
+}
+
+// '(' expression ')'
+func (p *parser) parseCondition() Expr {
+	p.expect("(")
+	cond := p.parseExpression()
+	p.expect(")")
+
+	return cond
+}
+
+// 'if' '(' expression ')' '{' statements '}' ('else' '{' statements '}')?
+// In the extended language the else branch can be an if statement.
+func (p *parser) parseIf() *IfStmt {
+	stmt := &IfStmt{If: p.pos}
+	p.next()
+	stmt.Cond = p.parseCondition()
+	stmt.Then = p.parseBlock()
+	if p.is("else") {
+		stmt.Else = p.pos
+		p.next()
+		if p.mode&Extended != 0 && p.is("if") {
+			stmt.Alt = []Stmt{p.parseIf()}
+		} else {
+			stmt.Alt = p.parseBlock()
+		}
+	}
+
+	return stmt
+}
+
+// 'while' '(' expression ')' '{' statements '}'
+func (p *parser) parseWhile() *WhileStmt {
+	stmt := &WhileStmt{While: p.pos}
+	p.next()
+	stmt.Cond = p.parseCondition()
+	stmt.Body = p.parseLoopBody()
+
+	return stmt
+}
+
+// 'for' '(' assignment? ';' expression? ';' assignment? ')' '{' statements '}'
+func (p *parser) parseFor() *ForStmt {
+	stmt := &ForStmt{For: p.pos}
+	p.next()
+	p.expect("(")
+	if !p.is(";") {
+		stmt.Init = p.parseAssignment(p.pos)
+	}
+	p.expect(";")
+	if !p.is(";") {
+		stmt.Cond = p.parseExpression()
+	}
+	p.expect(";")
+	if !p.is(")") {
+		stmt.Post = p.parseAssignment(p.pos)
+	}
+	p.expect(")")
+	stmt.Body = p.parseLoopBody()
+
+	return stmt
+}
+
+// parseLoopBody parses a block in which break and continue are allowed.
+func (p *parser) parseLoopBody() []Stmt {
+	p.loops++
+	defer func() { p.loops-- }()
+
+	return p.parseBlock()
+}
+
+// ('break' | 'continue') ';'
+func (p *parser) parseBranch() *BranchStmt {
+	stmt := &BranchStmt{TokPos: p.pos, Tok: p.lit}
+	if p.loops == 0 {
+		p.error(p.pos, stmt.Tok+" is not in a loop")
+	}
+	p.next()
+	p.expect(";")
+
+	return stmt
+}
+
+// 'do' subroutineCall ';'
+func (p *parser) parseDo() *DoStmt {
+	stmt := &DoStmt{Do: p.pos}
+	p.next()
+	name := p.parseIdent()
+	stmt.Call = p.parseCall(name)
+	p.expect(";")
+
+	return stmt
+}
+
+// 'return' expression? ';'
+func (p *parser) parseReturn() *ReturnStmt {
+	stmt := &ReturnStmt{Return: p.pos}
+	p.next()
+	if !p.is(";") {
+		stmt.Value = p.parseExpression()
+	}
+	p.expect(";")
+
+	return stmt
+}
+
+// parseCall parses a subroutine call after its first identifier:
+// '(' expressionList ')' or '.' subroutineName '(' expressionList ')'.
+func (p *parser) parseCall(name *Ident) *CallExpr {
+	call := &CallExpr{Name: name}
+	if p.is(".") {
+		p.next()
+		call.Receiver = name
+		call.Name = p.parseIdent()
+	}
+
+	p.expect("(")
+	if !p.is(")") {
+		call.Args = append(call.Args, p.parseExpression())
+		for p.is(",") {
+			p.next()
+			call.Args = append(call.Args, p.parseExpression())
+		}
+	}
+	p.expect(")")
+
+	return call
+}
+
+// term (op term)*
+func (p *parser) parseExpression() Expr {
+	x := p.parseTerm()
+	for p.tok == SYMBOL && IsOp(p.lit) && p.lit != "~" {
+		op := &BinaryExpr{X: x, OpPos: p.pos, Op: p.lit}
+		p.next()
+		op.Y = p.parseTerm()
+		x = op
+	}
+
+	return x
+}
+
+func (p *parser) parseTerm() Expr {
+	pos := p.pos
+	switch p.tok {
+	case INT:
+		x := &IntLit{ValuePos: pos, Value: p.lit}
+		if len(p.lit) > 2 && (p.lit[1] == 'x' || p.lit[1] == 'X' || p.lit[1] == 'b' || p.lit[1] == 'B') {
+			n, _ := strconv.ParseUint(p.lit, 0, 16)
+			x.Value = strconv.FormatUint(n, 10)
+		}
+		p.next()
+		return x
+	case CHARACTER:
+		x := &IntLit{ValuePos: pos, Value: strconv.Itoa(int([]rune(Unescape(p.lit))[0]))}
+		p.next()
+		return x
+	case STRING:
+		x := &StringLit{ValuePos: pos, Value: p.lit}
+		if p.mode&Extended != 0 {
+			x.Value = Unescape(p.lit)
+		}
+		p.next()
+		return x
+	case KEYWORD:
+		switch p.lit {
+		case "true", "false", "null", "this":
+			x := &KeywordLit{ValuePos: pos, Value: p.lit}
+			p.next()
+			return x
+		}
+	case IDENT:
+		name := p.parseIdent()
+		switch {
+		case p.is("["):
+			p.next()
+			x := &IndexExpr{Name: name, Index: p.parseExpression()}
+			p.expect("]")
+			return x
+		case p.is("(") || p.is("."):
+			return p.parseCall(name)
+		}
+		return name
+	case SYMBOL:
+		switch p.lit {
+		case "(":
+			p.next()
+			x := &ParenExpr{Lparen: pos, X: p.parseExpression()}
+			p.expect(")")
+			return x
+		case "-", "~":
+			op := p.lit
+			p.next()
+			return &UnaryExpr{OpPos: pos, Op: op, X: p.parseTerm()}
+		}
+	}
+
+	p.errorExpected("expression")
+	return &BadExpr{From: pos}
+}
@@ -0,0 +1,201 @@
+package jack_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"jack"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	src := []byte(`class Main {
+    field int x;
+    method int get(int i) {
+        var Array a;
+        let a[i] = -x + 2 * i;
+        if (~(i = 0)) { do Output.printInt(a[i]); } else { return get(i - 1); }
+        return x;
+    }
+}`)
+
+	class, errs := jack.Parse(src)
+	assert.NoError(t, errs.Err())
+	assert.Equal(t, "Main", class.Name.Name)
+	assert.Equal(t, jack.Pos{Line: 2, Column: 15}, class.Vars[0].Names[0].Pos())
+
+	sub := class.Subroutines[0]
+	assert.Equal(t, "method", sub.Kind)
+	assert.Equal(t, "int", sub.ReturnType.Name)
+	assert.Equal(t, "i", sub.Params[0].Name.Name)
+	assert.Equal(t, "Array", sub.Vars[0].Type.Name)
+	assert.Len(t, sub.Body, 3)
+
+	let := sub.Body[0].(*jack.LetStmt)
+	assert.Equal(t, jack.Pos{Line: 5, Column: 9}, let.Pos())
+	assert.IsType(t, &jack.Ident{}, let.Index)
+	// no precedence: (-x + 2) * i
+	mul := let.Value.(*jack.BinaryExpr)
+	assert.Equal(t, "*", mul.Op)
+	assert.Equal(t, "+", mul.X.(*jack.BinaryExpr).Op)
+
+	ifStmt := sub.Body[1].(*jack.IfStmt)
+	assert.True(t, ifStmt.Else.IsValid())
+	call := ifStmt.Alt[0].(*jack.ReturnStmt).Value.(*jack.CallExpr)
+	assert.Nil(t, call.Receiver)
+	assert.Equal(t, "get", call.Name.Name)
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			"1. missing semicolon",
+			"class Main {\n  function void main() {\n    let x = 1\n    return;\n  }\n}",
+			"4:5: expected ';', found 'return'",
+		},
+		{
+			"2. unknown statement",
+			"class Main {\n  function void main() {\n    var int x;\n    x = 1;\n    return;\n  }\n}",
+			"4:5: expected statement, found 'x'",
+		},
+		{
+			"3. unexpected EOF",
+			"class Main {\n  function void main() {\n    do Output.printInt(",
+			"3:24: expected expression, found EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class, errs := jack.Parse([]byte(tt.src))
+			assert.NotNil(t, class)
+			if assert.NotEmpty(t, errs) {
+				assert.Equal(t, tt.want, errs[0].Error())
+			}
+		})
+	}
+}
+
+func TestParse_Programs(t *testing.T) {
+	files, err := filepath.Glob("../project11/test/*/*.jack")
+	assert.NoError(t, err)
+
+	for _, file := range files {
+		t.Run(file, func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			assert.NoError(t, err)
+			_, errs := jack.Parse(src)
+			assert.NoError(t, errs.Err())
+		})
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	wrap := func(body string) string {
+		return "class Main {\n  function void main() {\n    var int i;\n" + body + "\n    return;\n  }\n}"
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		standard string // first error in standard mode
+		extended string // first error in extended mode, "" if none
+	}{
+		{
+			"1. for loop",
+			"for (i = 0; i < 10; i += 1) { do f(i); }",
+			"4:1: expected statement, found 'for'",
+			"",
+		},
+		{
+			"2. for loop without clauses",
+			"for (;;) { break; }",
+			"4:1: expected statement, found 'for'",
+			"",
+		},
+		{
+			"3. else if",
+			"if (i = 0) { let i = 1; } else if (i = 1) { let i = 2; } else { let i = 3; }",
+			"4:32: expected '{', found 'if'",
+			"",
+		},
+		{
+			"4. compound assignments",
+			"let i -= 1; let a[i] *= 2; let i |= 4;",
+			"4:7: expected '=', found '-'",
+			"",
+		},
+		{
+			"5. break and continue in while",
+			"while (true) { if (i > 3) { break; } continue; }",
+			"4:29: expected statement, found 'break'",
+			"",
+		},
+		{
+			"6. break outside loop",
+			"break;",
+			"4:1: expected statement, found 'break'",
+			"4:1: break is not in a loop",
+		},
+		{
+			"7. separated compound operator",
+			"let i + = 1;",
+			"4:7: expected '=', found '+'",
+			"4:9: expected '=', found '='",
+		},
+		{
+			"8. for as a name",
+			"let for = 1;",
+			"",
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, mode := range []jack.Mode{0, jack.Extended} {
+				want := tt.standard
+				if mode == jack.Extended {
+					want = tt.extended
+				}
+				class, errs := jack.ParseMode([]byte(wrap(tt.body)), mode)
+				assert.NotNil(t, class)
+				if want == "" {
+					assert.NoError(t, errs.Err())
+				} else if assert.NotEmpty(t, errs) {
+					assert.Equal(t, want, errs[0].Error())
+				}
+			}
+		})
+	}
+
+	class, errs := jack.ParseMode([]byte(wrap("if (i) { } else if (i) { } else { let i = 1; }")), jack.Extended)
+	assert.NoError(t, errs.Err())
+	stmt := class.Subroutines[0].Body[0].(*jack.IfStmt)
+	if assert.Len(t, stmt.Alt, 1) {
+		assert.Len(t, stmt.Alt[0].(*jack.IfStmt).Alt, 1)
+	}
+}
+
+// FuzzParse checks that the parser terminates without panicking on
+// malformed input.
+func FuzzParse(f *testing.F) {
+	files, err := filepath.Glob("../project11/test/*/*.jack")
+	assert.NoError(f, err)
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		assert.NoError(f, err)
+		f.Add(src)
+	}
+
+	f.Fuzz(func(t *testing.T, src []byte) {
+		class, _ := jack.Parse(src)
+		assert.NotNil(t, class)
+	})
+}
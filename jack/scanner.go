@@ -0,0 +1,358 @@
+package jack
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type Scanner struct {
+	// immutable state
+	src  []byte
+	mode Mode
+
+	// scanning state
+	ch         rune // current character
+	offset     int  // character offset
+	rdOffset   int  // reading offset (position after current character)
+	line       int  // current line
+	lineOffset int  // offset of the current line
+	pos        Pos  // position of the last scanned token
+
+	errors ErrorList // lexical errors found so far
+}
+
+const (
+	eof = -1
+
+	// maxInt is the largest Jack integer constant.
+	maxInt = 32767
+
+	// maxBits is the largest hexadecimal or binary constant.
+	maxBits = 0xFFFF
+
+	// symbols are the characters of the Jack symbols.
+	symbolChars = "{}()[].,;+-*/&|<>=~"
+)
+
+func (s *Scanner) Init(src []byte) {
+	s.InitMode(src, 0)
+}
+
+// InitMode is Init for the language selected by mode. The extended
+// language adds character constants, hexadecimal and binary integer
+// constants and escape sequences in strings.
+func (s *Scanner) InitMode(src []byte, mode Mode) {
+	s.src = src
+	s.mode = mode
+	s.ch = ' '
+	s.offset = 0
+	s.rdOffset = 0
+	s.line = 1
+	s.lineOffset = 0
+	s.errors = nil
+
+	s.next()
+}
+
+// Errors returns the lexical errors found so far. Scan returns an ILLEGAL
+// token for each of them, except for illegal bytes inside string
+// constants and comments.
+func (s *Scanner) Errors() ErrorList {
+	return s.errors
+}
+
+func (s *Scanner) error(pos Pos, msg string) {
+	// one error per position is enough
+	if n := len(s.errors); n > 0 && s.errors[n-1].Pos == pos {
+		return
+	}
+	s.errors = append(s.errors, &Error{pos, msg})
+}
+
+// position returns the position of the current character.
+func (s *Scanner) position() Pos {
+	return Pos{s.line, s.offset - s.lineOffset + 1}
+}
+
+// Pos returns the position of the last scanned token.
+func (s *Scanner) Pos() Pos {
+	return s.pos
+}
+
+func (s *Scanner) Scan() (tok Token, lit string) {
+	s.skipWhiteSpace()
+	s.pos = s.position()
+
+	if s.isEOF() {
+		return EOF, ""
+	}
+
+	ok := true
+	switch ch := s.ch; {
+	case isLetter(ch):
+		lit = s.scanIdentifier()
+		tok = Lookup(lit)
+	case isDecimal(ch):
+		tok, lit = s.scanNumber()
+	default:
+		s.next()
+		switch ch {
+		case '"':
+			tok = STRING
+			if lit, ok = s.scanString(); !ok {
+				tok = ILLEGAL
+			}
+		case '\'':
+			if s.mode&Extended == 0 {
+				tok, lit = ILLEGAL, "'"
+				s.error(s.pos, fmt.Sprintf("illegal character %#U", ch))
+				break
+			}
+			tok = CHARACTER
+			if lit, ok = s.scanCharacter(); !ok {
+				tok = ILLEGAL
+			}
+		case '/':
+			if s.ch == '/' || s.ch == '*' {
+				// comment
+				tok = COMMENT
+				if lit, ok = s.scanComment(); !ok {
+					tok = ILLEGAL
+				}
+			} else {
+				// division
+				tok = SYMBOL
+				lit = "/"
+			}
+		default:
+			lit = string(ch)
+			tok = SYMBOL
+			if !strings.ContainsRune(symbolChars, ch) {
+				tok = ILLEGAL
+				s.error(s.pos, fmt.Sprintf("illegal character %#U", ch))
+			}
+		}
+	}
+
+	return
+}
+
+func (s *Scanner) next() {
+	if s.ch == '\n' {
+		s.line++
+		s.lineOffset = s.rdOffset
+	}
+	if s.rdOffset >= len(s.src) {
+		s.ch = eof
+		s.offset = len(s.src)
+		return
+	}
+
+	s.offset = s.rdOffset
+
+	r, w := rune(s.src[s.rdOffset]), 1
+	switch {
+	case r == 0:
+		s.error(s.position(), "illegal character NUL")
+	case r >= utf8.RuneSelf:
+		r, w = utf8.DecodeRune(s.src[s.rdOffset:])
+		if r == utf8.RuneError && w == 1 {
+			s.error(s.position(), "illegal UTF-8 encoding")
+		}
+	}
+	s.ch = r
+	s.rdOffset += w
+}
+
+func (s *Scanner) scanIdentifier() string {
+	offs := s.offset
+	for isLetter(s.ch) || isDecimal(s.ch) {
+		s.next()
+	}
+
+	return string(s.src[offs:s.offset])
+}
+
+// scanNumber scans a decimal constant, or in the extended language a 0x
+// hexadecimal or 0b binary constant. These can be up to 0xFFFF, the
+// 16-bit pattern of a negative number.
+func (s *Scanner) scanNumber() (tok Token, lit string) {
+	offs := s.offset
+	tok = INT
+	base, max := 10, maxInt
+	if s.mode&Extended != 0 && s.ch == '0' {
+		s.next()
+		switch lower(s.ch) {
+		case 'x':
+			base, max = 16, maxBits
+			s.next()
+		case 'b':
+			base, max = 2, maxBits
+			s.next()
+		}
+	}
+	for isDecimal(s.ch) || base == 16 && 'a' <= lower(s.ch) && lower(s.ch) <= 'f' {
+		s.next()
+	}
+	lit = string(s.src[offs:s.offset])
+
+	digits := lit
+	if base != 10 {
+		digits = lit[2:]
+	}
+	n, err := strconv.ParseUint(digits, base, 64)
+	switch {
+	case err != nil && errors.Is(err, strconv.ErrRange) || err == nil && n > uint64(max):
+		tok = ILLEGAL
+		s.error(s.pos, fmt.Sprintf("integer constant %v out of range", lit))
+	case err != nil:
+		tok = ILLEGAL
+		s.error(s.pos, fmt.Sprintf("invalid integer constant %v", lit))
+	}
+
+	return
+}
+
+// scanString returns the characters up to the closing quote, which must
+// be on the same line. Escape sequences are kept as they are.
+func (s *Scanner) scanString() (string, bool) {
+	offs := s.offset
+	ok := true
+	for s.ch != '"' {
+		if s.ch == '\n' || s.ch == '\r' || s.ch == eof {
+			s.error(s.pos, "string constant not terminated")
+			return string(s.src[offs:s.offset]), false
+		}
+		if s.ch == '\\' && s.mode&Extended != 0 {
+			ok = s.scanEscape() && ok
+			continue
+		}
+		s.next()
+	}
+	lit := string(s.src[offs:s.offset])
+	s.next() // closing quote
+
+	return lit, ok
+}
+
+// scanCharacter returns the character or escape sequence up to the
+// closing quote.
+func (s *Scanner) scanCharacter() (string, bool) {
+	offs := s.offset
+	ok := true
+	n := 0
+	for s.ch != '\'' {
+		if s.ch == '\n' || s.ch == '\r' || s.ch == eof {
+			s.error(s.pos, "character constant not terminated")
+			return string(s.src[offs:s.offset]), false
+		}
+		if s.ch == '\\' {
+			ok = s.scanEscape() && ok
+		} else {
+			s.next()
+		}
+		n++
+	}
+	lit := string(s.src[offs:s.offset])
+	s.next() // closing quote
+	if n != 1 && ok {
+		s.error(s.pos, "character constant must contain one character")
+		ok = false
+	}
+
+	return lit, ok
+}
+
+// scanEscape scans the escape sequence at the current backslash. It stops
+// at the end of the line, which ends the constant.
+func (s *Scanner) scanEscape() bool {
+	pos := s.position()
+	s.next()
+	if s.ch == '\n' || s.ch == '\r' || s.ch == eof {
+		return false
+	}
+	_, ok := escapes[s.ch]
+	if !ok {
+		s.error(pos, "unknown escape sequence")
+	}
+	s.next()
+
+	return ok
+}
+
+// escapes are the escape sequences of the extended language, with the
+// codes of the Jack character set, where newline is 128 and backspace 129.
+var escapes = map[rune]rune{
+	'n':  128,
+	'b':  129,
+	'\\': '\\',
+	'"':  '"',
+	'\'': '\'',
+}
+
+// Unescape returns the characters of a string or character constant of
+// the extended language, without escape sequences.
+func Unescape(lit string) string {
+	var b strings.Builder
+	escaped := false
+	for _, ch := range lit {
+		switch {
+		case escaped:
+			b.WriteRune(escapes[ch])
+			escaped = false
+		case ch == '\\':
+			escaped = true
+		default:
+			b.WriteRune(ch)
+		}
+	}
+
+	return b.String()
+}
+
+func (s *Scanner) scanComment() (string, bool) {
+	offs := s.offset - 1
+	//-style comment
+	if s.ch == '/' {
+		for s.ch != '\n' && s.ch != eof {
+			s.next()
+		}
+		return string(s.src[offs:s.offset]), true
+	}
+
+	//**-style comment
+	s.next()
+	for s.ch != eof {
+		ch := s.ch
+		s.next()
+		if ch == '*' && s.ch == '/' {
+			s.next()
+			return string(s.src[offs:s.offset]), true
+		}
+	}
+	s.error(s.pos, "comment not terminated")
+
+	return string(s.src[offs:s.offset]), false
+}
+
+func (s *Scanner) skipWhiteSpace() {
+	for s.ch == ' ' || s.ch == '\t' || s.ch == '\n' || s.ch == '\r' {
+		s.next()
+	}
+}
+
+func (s *Scanner) isEOF() bool {
+	return s.ch == eof
+}
+
+// isLetter reports whether ch may start an identifier. Jack identifiers
+// are ASCII only.
+func isLetter(ch rune) bool {
+	return 'a' <= lower(ch) && lower(ch) <= 'z' || ch == '_'
+}
+
+func lower(ch rune) rune     { return ('a' - 'A') | ch } // returns lower-case ch iff ch is ASCII letter
+func isDecimal(ch rune) bool { return '0' <= ch && ch <= '9' }
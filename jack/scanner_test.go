@@ -0,0 +1,505 @@
+package jack_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"jack"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanner_Scan(t *testing.T) {
+	type lexical struct {
+		tok jack.Token
+		lit string
+	}
+
+	tests := []struct {
+		name  string
+		src   []byte
+		wants []lexical
+	}{
+		{
+			name: "1. class Main {",
+			src:  []byte("class Main {"),
+			wants: []lexical{
+				{
+					tok: jack.KEYWORD,
+					lit: "class",
+				},
+				{
+					tok: jack.IDENT,
+					lit: "Main",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: "{",
+				},
+			},
+		},
+		{
+			name: "2. static boolean test;",
+			src:  []byte("static boolean test;"),
+			wants: []lexical{
+				{
+					tok: jack.KEYWORD,
+					lit: "static",
+				},
+				{
+					tok: jack.KEYWORD,
+					lit: "boolean",
+				},
+				{
+					tok: jack.IDENT,
+					lit: "test",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: ";",
+				},
+			},
+		},
+		{
+			name: "3. function void main() {",
+			src:  []byte("function void main() {"),
+			wants: []lexical{
+				{
+					tok: jack.KEYWORD,
+					lit: "function",
+				},
+				{
+					tok: jack.KEYWORD,
+					lit: "void",
+				},
+				{
+					tok: jack.IDENT,
+					lit: "main",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: "(",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: ")",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: "{",
+				},
+			},
+		},
+		{
+			name: "4. var SquareGame game;",
+			src:  []byte("var SquareGame game;"),
+			wants: []lexical{
+				{
+					tok: jack.KEYWORD,
+					lit: "var",
+				},
+				{
+					tok: jack.IDENT,
+					lit: "SquareGame",
+				},
+				{
+					tok: jack.IDENT,
+					lit: "game",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: ";",
+				},
+			},
+		},
+		{
+			name: "5. let game = game;",
+			src:  []byte("let game = game;"),
+			wants: []lexical{
+				{
+					tok: jack.KEYWORD,
+					lit: "let",
+				},
+				{
+					tok: jack.IDENT,
+					lit: "game",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: "=",
+				},
+				{
+					tok: jack.IDENT,
+					lit: "game",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: ";",
+				},
+			},
+		},
+		{
+			name: "6. if (x<0) {",
+			src:  []byte("if (x<0) {"),
+			wants: []lexical{
+				{
+					tok: jack.KEYWORD,
+					lit: "if",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: "(",
+				},
+				{
+					tok: jack.IDENT,
+					lit: "x",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: "<",
+				},
+				{
+					tok: jack.INT,
+					lit: "0",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: ")",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: "{",
+				},
+			},
+		},
+		{
+			name: "7. let state = \"negative\"",
+			src:  []byte(`let state = "negative"`),
+			wants: []lexical{
+				{
+					tok: jack.KEYWORD,
+					lit: "let",
+				},
+				{
+					tok: jack.IDENT,
+					lit: "state",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: "=",
+				},
+				{
+					tok: jack.STRING,
+					lit: `negative`,
+				},
+			},
+		},
+		{
+			name: "8. test comment //",
+			src:  []byte("// aaa"),
+			wants: []lexical{
+				{
+					tok: jack.COMMENT,
+					lit: "// aaa",
+				},
+			},
+		},
+		{
+			name: "9. test comment /** comment */",
+			src:  []byte("/** aaa */"),
+			wants: []lexical{
+				{
+					tok: jack.COMMENT,
+					lit: "/** aaa */",
+				},
+			},
+		},
+		{
+			name: `10.let length = Keyboard.readInt("HOW MANY NUMBERS? ");`,
+			src:  []byte(`let length = Keyboard.readInt("HOW MANY NUMBERS? ");`),
+			wants: []lexical{
+				{
+					tok: jack.KEYWORD,
+					lit: "let",
+				},
+				{
+					tok: jack.IDENT,
+					lit: "length",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: "=",
+				},
+				{
+					tok: jack.IDENT,
+					lit: "Keyboard",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: ".",
+				},
+				{
+					tok: jack.IDENT,
+					lit: "readInt",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: "(",
+				},
+				{
+					tok: jack.STRING,
+					lit: `HOW MANY NUMBERS? `,
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: ")",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: ";",
+				},
+			},
+		},
+		{
+			name: "11. let a[1] = a[2];",
+			src:  []byte(`let a[1] = a[2];`),
+			wants: []lexical{
+				{
+					tok: jack.KEYWORD,
+					lit: "let",
+				},
+				{
+					tok: jack.IDENT,
+					lit: "a",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: "[",
+				},
+				{
+					tok: jack.INT,
+					lit: "1",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: "]",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: "=",
+				},
+				{
+					tok: jack.IDENT,
+					lit: "a",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: "[",
+				},
+				{
+					tok: jack.INT,
+					lit: "2",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: "]",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: ";",
+				},
+			},
+		},
+		{
+			name: "12. var int length;",
+			src:  []byte("var int length;"),
+			wants: []lexical{
+				{
+					tok: jack.KEYWORD,
+					lit: "var",
+				},
+				{
+					tok: jack.KEYWORD,
+					lit: "int",
+				},
+				{
+					tok: jack.IDENT,
+					lit: "length",
+				},
+				{
+					tok: jack.SYMBOL,
+					lit: ";",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var scanner jack.Scanner
+			scanner.Init(tt.src)
+			for _, want := range tt.wants {
+				tok, lit := scanner.Scan()
+				got := lexical{
+					tok,
+					lit,
+				}
+				assert.Equalf(
+					t,
+					want,
+					got,
+					"got %v, want %v",
+					got,
+					want,
+				)
+
+			}
+
+			t.Run("test EOF", func(t *testing.T) {
+				tok, lit := scanner.Scan()
+				assert.Equal(
+					t,
+					jack.EOF,
+					tok,
+					"expexted EOF, got %v and %v",
+					tok,
+					lit,
+				)
+			})
+		})
+	}
+}
+
+func TestScanner_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		tok  jack.Token
+		lit  string
+		err  string
+	}{
+		{"1. largest integer", "32767", jack.INT, "32767", ""},
+		{"2. integer out of range", "x 32768", jack.ILLEGAL, "32768", "1:3: integer constant 32768 out of range"},
+		{"3. unterminated string", "\"abc\nx", jack.ILLEGAL, "abc", "1:1: string constant not terminated"},
+		{"4. string at EOF", "  \"abc", jack.ILLEGAL, "abc", "1:3: string constant not terminated"},
+		{"5. unterminated comment", "/* abc", jack.ILLEGAL, "/* abc", "1:1: comment not terminated"},
+		{"6. illegal character", "#", jack.ILLEGAL, "#", "1:1: illegal character U+0023 '#'"},
+		{"7. unicode letter", "\n\u00e9t\u00e9", jack.ILLEGAL, "\u00e9", "2:1: illegal character U+00E9 '\u00e9'"},
+		{"8. NUL", "\x00", jack.ILLEGAL, "\x00", "1:1: illegal character NUL"},
+		{"9. invalid UTF-8", "\xff", jack.ILLEGAL, "\ufffd", "1:1: illegal UTF-8 encoding"},
+		{"10. underscore", "_a1", jack.IDENT, "_a1", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s jack.Scanner
+			s.Init([]byte(tt.src))
+			tok, lit := s.Scan()
+			for tok != jack.EOF && tok != tt.tok {
+				tok, lit = s.Scan()
+			}
+			assert.Equal(t, tt.tok, tok)
+			assert.Equal(t, tt.lit, lit)
+			if tt.err == "" {
+				assert.Empty(t, s.Errors())
+			} else if assert.NotEmpty(t, s.Errors()) {
+				assert.Equal(t, tt.err, s.Errors()[0].Error())
+			}
+		})
+	}
+}
+
+func TestScanner_Extended(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		tok  jack.Token
+		lit  string
+		err  string
+	}{
+		{"1. character", "'a'", jack.CHARACTER, "a", ""},
+		{"2. escaped character", `'\''`, jack.CHARACTER, `\'`, ""},
+		{"3. hexadecimal", "0xFFFF", jack.INT, "0xFFFF", ""},
+		{"4. binary", "0b1010", jack.INT, "0b1010", ""},
+		{"5. escapes", `"a\"b\\c\n"`, jack.STRING, `a\"b\\c\n`, ""},
+		{"6. hexadecimal out of range", "0x10000", jack.ILLEGAL, "0x10000", "1:1: integer constant 0x10000 out of range"},
+		{"7. decimal out of range", "40000", jack.ILLEGAL, "40000", "1:1: integer constant 40000 out of range"},
+		{"8. no digits", "0x;", jack.ILLEGAL, "0x", "1:1: invalid integer constant 0x"},
+		{"9. binary digit", "0b12", jack.ILLEGAL, "0b12", "1:1: invalid integer constant 0b12"},
+		{"10. two characters", "'ab'", jack.ILLEGAL, "ab", "1:1: character constant must contain one character"},
+		{"11. empty character", "''", jack.ILLEGAL, "", "1:1: character constant must contain one character"},
+		{"12. unknown escape", `"a\qb" x`, jack.ILLEGAL, `a\qb`, "1:3: unknown escape sequence"},
+		{"13. unterminated character", "'a", jack.ILLEGAL, "a", "1:1: character constant not terminated"},
+		{"14. escaped end of line", "\"a\\\n\"", jack.ILLEGAL, "a\\", "1:1: string constant not terminated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s jack.Scanner
+			s.InitMode([]byte(tt.src), jack.Extended)
+			tok, lit := s.Scan()
+			assert.Equal(t, tt.tok, tok)
+			assert.Equal(t, tt.lit, lit)
+			if tt.err == "" {
+				assert.Empty(t, s.Errors())
+			} else if assert.NotEmpty(t, s.Errors()) {
+				assert.Equal(t, tt.err, s.Errors()[0].Error())
+			}
+		})
+	}
+
+	// standard Jack has none of them
+	var s jack.Scanner
+	s.Init([]byte(`'a' 0x1F "\n"`))
+	for _, want := range []jack.Token{jack.ILLEGAL, jack.IDENT, jack.ILLEGAL, jack.INT, jack.IDENT, jack.STRING} {
+		tok, _ := s.Scan()
+		assert.Equal(t, want, tok)
+	}
+	assert.Equal(t, `\n`, jack.Unescape(`\\n`))
+	assert.Equal(t, "a\u0080\"", jack.Unescape(`a\n\"`))
+}
+
+// FuzzScanner_Scan checks that Scan never panics, always terminates and
+// reports an error for each ILLEGAL token.
+func FuzzScanner_Scan(f *testing.F) {
+	files, err := filepath.Glob("../project11/test/*/*.jack")
+	assert.NoError(f, err)
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		assert.NoError(f, err)
+		f.Add(src)
+	}
+	f.Add([]byte("let s = \"abc"))
+	f.Add([]byte("/* abc *"))
+	f.Add([]byte("let x = 99999999999999999999;"))
+	f.Add([]byte("caf\u00e9 \x00 \xff"))
+
+	f.Add([]byte("'a' '\\n' 0x7fff 0b1 \"a\\\"b\\q\" '"))
+
+	f.Fuzz(func(t *testing.T, src []byte) {
+		for _, mode := range []jack.Mode{0, jack.Extended} {
+			var s jack.Scanner
+			s.InitMode(src, mode)
+			prev := jack.Pos{}
+			for n := 0; ; n++ {
+				// every token but EOF consumes at least one byte
+				if n > len(src) {
+					t.Fatalf("more than %d tokens", len(src))
+				}
+				tok, _ := s.Scan()
+				if tok == jack.EOF {
+					break
+				}
+				pos := s.Pos()
+				if pos.Line < prev.Line || pos.Line == prev.Line && pos.Column <= prev.Column {
+					t.Fatalf("position %v after %v", pos, prev)
+				}
+				prev = pos
+				if tok == jack.ILLEGAL && len(s.Errors()) == 0 {
+					t.Fatalf("ILLEGAL token at %v without error", pos)
+				}
+			}
+		}
+	})
+}
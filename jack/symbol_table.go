@@ -1,6 +1,10 @@
-package main
+package jack
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"os"
+)
 
 type VariableKind int
 
@@ -114,25 +118,42 @@ func (sb *SymbolTable) IsExists(name string) bool {
 }
 
 func (sb *SymbolTable) Print() {
-	fmt.Printf("%-10s", "name")
-	fmt.Printf("%-10s", "type")
-	fmt.Printf("%-10s", "kind")
-	fmt.Printf("%-10s\n", "index")
-	fmt.Println("-----------------------------------")
+	sb.Fprint(os.Stdout)
+	fmt.Println()
+	fmt.Println()
+}
+
+// Fprint writes the given variables, or all of them if no name is given,
+// to w as a table.
+func (sb *SymbolTable) Fprint(w io.Writer, names ...string) {
+	fmt.Fprintf(w, "%-10s", "name")
+	fmt.Fprintf(w, "%-10s", "type")
+	fmt.Fprintf(w, "%-10s", "kind")
+	fmt.Fprintf(w, "%-10s\n", "index")
+	fmt.Fprintln(w, "-----------------------------------")
+
+	symbols := make([]Symbol, 0, len(sb.m))
+	if len(names) == 0 {
+		for _, v := range sb.m {
+			symbols = append(symbols, v)
+		}
+	}
+	for _, name := range names {
+		if v, ok := sb.m[name]; ok {
+			symbols = append(symbols, v)
+		}
+	}
 
-	for _, v := range sb.m {
+	for _, v := range symbols {
 		vType := v.sType.String()
 		if v.sType == USR {
 			vType = v.uType
 		}
-		fmt.Printf("%-10s", v.name)
-		fmt.Printf("%-10s", vType)
-		fmt.Printf("%-10v", v.kind)
-		fmt.Printf("%-10d\n", v.index)
+		fmt.Fprintf(w, "%-10s", v.name)
+		fmt.Fprintf(w, "%-10s", vType)
+		fmt.Fprintf(w, "%-10v", v.kind)
+		fmt.Fprintf(w, "%-10d\n", v.index)
 	}
-
-	fmt.Println()
-	fmt.Println()
 }
 
 func (v VariableKind) String() string {
@@ -1,9 +1,9 @@
-package main_test
+package jack_test
 
 import (
 	"testing"
 
-	pkg "project11"
+	"jack"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -12,9 +12,9 @@ func TestSymbolTable(t *testing.T) {
 	type fields struct {
 		name      string
 		tok       string
-		kind      pkg.VariableKind
+		kind      jack.VariableKind
 		wantCount uint
-		wantTok   pkg.Token
+		wantTok   jack.Token
 	}
 
 	tests := []struct {
@@ -26,9 +26,9 @@ func TestSymbolTable(t *testing.T) {
 			fields{
 				name:      "value",
 				tok:       "int",
-				kind:      pkg.Var,
+				kind:      jack.Var,
 				wantCount: 0,
-				wantTok:   pkg.INT,
+				wantTok:   jack.INT,
 			},
 		},
 		{
@@ -36,14 +36,14 @@ func TestSymbolTable(t *testing.T) {
 			fields{
 				name:      "value2",
 				tok:       "int",
-				kind:      pkg.Var,
+				kind:      jack.Var,
 				wantCount: 1,
-				wantTok:   pkg.INT,
+				wantTok:   jack.INT,
 			},
 		},
 	}
 
-	sb := pkg.NewSymbolTable()
+	sb := jack.NewSymbolTable()
 
 	count := uint32(0)
 	for _, tt := range tests {
@@ -61,7 +61,7 @@ func TestSymbolTable(t *testing.T) {
 			assert.Equal(
 				t,
 				sb.KindOf(tt.fields.name),
-				pkg.Var,
+				jack.Var,
 				"KindOf",
 			)
 
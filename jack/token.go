@@ -1,6 +1,7 @@
-package main
+package jack
 
 import (
+	"fmt"
 	"strconv"
 )
 
@@ -13,6 +14,8 @@ const (
 	EOF
 	COMMENT
 	IDENT
+	STRING    // string constant
+	CHARACTER // character constant, in the extended language
 
 	KEYWORD
 	keyword_beg
@@ -74,6 +77,9 @@ var tokens = [...]string{
 	EOF:     "EOF",
 	COMMENT: "COMMENT",
 	IDENT:   "identifier",
+	STRING:  "stringConstant",
+
+	CHARACTER: "charConstant",
 
 	KEYWORD:     "keyword",
 	CLASS:       "class",
@@ -133,6 +139,21 @@ func (tok Token) String() string {
 	return s
 }
 
+// Pos is a position in a source file, line and column start at 1.
+type Pos struct {
+	Line   int
+	Column int
+}
+
+func (pos Pos) String() string {
+	return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+}
+
+// IsValid reports whether the position is set.
+func (pos Pos) IsValid() bool {
+	return pos.Line > 0
+}
+
 var keywords map[string]Token
 var symbols map[string]Token
 var ops map[string]Token
@@ -0,0 +1,236 @@
+// Package tree converts Jack syntax trees to a generic tree of nodes, for
+// tools written in other languages. The tree is written as JSON, with
+// positions, or as a compact S-expression without positions for golden
+// tests.
+package tree
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"jack"
+)
+
+// Node is a node of the tree. The JSON schema is that of Node, and is
+// stable: kinds and fields are only ever added.
+//
+// Value and the children depend on Kind, optional children are null:
+//
+//	class       value: name      children: classVar*, subroutine*
+//	classVar    value: kind      children: type, name+ (kind is static or field)
+//	subroutine  value: kind      children: returnType, name, params, vars, statements
+//	params                       children: param*
+//	param                        children: type, name
+//	vars                         children: var*
+//	var                          children: type, name+
+//	statements                   children: statement*
+//	let         value: op        children: name, index or null, value (op of +=, -=, ...)
+//	if                           children: cond, statements, statements or null (else)
+//	while                        children: cond, statements
+//	for                          children: let or null, cond or null, let or null, statements
+//	break
+//	continue
+//	do                           children: call
+//	return                       children: value or null
+//	ident       value: name
+//	int         value: decimal value, also of character constants
+//	string      value: characters, without quotes
+//	keyword     value: true, false, null or this
+//	index                        children: name, index
+//	call                         children: receiver or null, name, arg*
+//	unary       value: op        children: x
+//	binary      value: op        children: x, y
+//	paren                        children: x
+//	bad                          (an expression with syntax errors)
+//
+// Types and names are ident nodes.
+type Node struct {
+	Kind     string  `json:"kind"`
+	Line     int     `json:"line"`   // 1-based, 0 for list nodes
+	Column   int     `json:"column"` // 1-based, in bytes
+	Value    string  `json:"value,omitempty"`
+	Children []*Node `json:"children,omitempty"`
+}
+
+// New returns the tree of class.
+func New(class *jack.ClassDecl) *Node {
+	n := node("class", class.Pos(), class.Name.Name)
+	for _, dec := range class.Vars {
+		v := node("classVar", dec.Pos(), dec.Kind, ident(dec.Type))
+		for _, name := range dec.Names {
+			v.Children = append(v.Children, ident(name))
+		}
+		n.Children = append(n.Children, v)
+	}
+	for _, sub := range class.Subroutines {
+		n.Children = append(n.Children, subroutine(sub))
+	}
+
+	return n
+}
+
+// JSON returns the tree of class as indented JSON.
+func JSON(class *jack.ClassDecl) ([]byte, error) {
+	return json.MarshalIndent(New(class), "", "  ")
+}
+
+// SExpr returns the tree of class as an S-expression, one subroutine
+// per line. A node is (kind value children...), ident, int and keyword
+// nodes are bare atoms, string nodes are quoted and null is nil:
+//
+//	(class Main
+//	  (subroutine function void main (params) (vars) (statements (return nil))))
+func SExpr(class *jack.ClassDecl) []byte {
+	var b bytes.Buffer
+	n := New(class)
+	b.WriteString("(" + n.Kind + " " + n.Value)
+	for _, child := range n.Children {
+		b.WriteString("\n  ")
+		writeSExpr(&b, child)
+	}
+	b.WriteString(")\n")
+
+	return b.Bytes()
+}
+
+func writeSExpr(b *bytes.Buffer, n *Node) {
+	switch {
+	case n == nil:
+		b.WriteString("nil")
+		return
+	case n.Kind == "ident", n.Kind == "int", n.Kind == "keyword":
+		b.WriteString(n.Value)
+		return
+	case n.Kind == "string":
+		b.WriteString(strconv.Quote(n.Value))
+		return
+	}
+
+	b.WriteString("(" + n.Kind)
+	if n.Value != "" {
+		b.WriteString(" " + n.Value)
+	}
+	for _, child := range n.Children {
+		b.WriteString(" ")
+		writeSExpr(b, child)
+	}
+	b.WriteString(")")
+}
+
+func node(kind string, pos jack.Pos, value string, children ...*Node) *Node {
+	return &Node{Kind: kind, Line: pos.Line, Column: pos.Column, Value: value, Children: children}
+}
+
+// list returns a node without position for a sequence.
+func list(kind string, children []*Node) *Node {
+	return &Node{Kind: kind, Children: children}
+}
+
+func ident(x *jack.Ident) *Node {
+	return node("ident", x.Pos(), x.Name)
+}
+
+func subroutine(sub *jack.SubroutineDecl) *Node {
+	var params []*Node
+	for _, param := range sub.Params {
+		params = append(params, node("param", param.Pos(), "", ident(param.Type), ident(param.Name)))
+	}
+	var vars []*Node
+	for _, dec := range sub.Vars {
+		v := node("var", dec.Pos(), "", ident(dec.Type))
+		for _, name := range dec.Names {
+			v.Children = append(v.Children, ident(name))
+		}
+		vars = append(vars, v)
+	}
+
+	return node("subroutine", sub.Pos(), sub.Kind,
+		ident(sub.ReturnType),
+		ident(sub.Name),
+		list("params", params),
+		list("vars", vars),
+		statements(sub.Body),
+	)
+}
+
+func statements(stmts []jack.Stmt) *Node {
+	var children []*Node
+	for _, stmt := range stmts {
+		children = append(children, statement(stmt))
+	}
+
+	return list("statements", children)
+}
+
+func statement(stmt jack.Stmt) *Node {
+	switch s := stmt.(type) {
+	case *jack.LetStmt:
+		return let(s)
+	case *jack.IfStmt:
+		var alt *Node
+		if s.Else.IsValid() {
+			alt = statements(s.Alt)
+		}
+		return node("if", s.Pos(), "", expression(s.Cond), statements(s.Then), alt)
+	case *jack.WhileStmt:
+		return node("while", s.Pos(), "", expression(s.Cond), statements(s.Body))
+	case *jack.ForStmt:
+		var init, post *Node
+		if s.Init != nil {
+			init = let(s.Init)
+		}
+		if s.Post != nil {
+			post = let(s.Post)
+		}
+		return node("for", s.Pos(), "", init, expression(s.Cond), post, statements(s.Body))
+	case *jack.BranchStmt:
+		return node(s.Tok, s.Pos(), "")
+	case *jack.DoStmt:
+		return node("do", s.Pos(), "", expression(s.Call))
+	case *jack.ReturnStmt:
+		return node("return", s.Pos(), "", expression(s.Value))
+	}
+
+	panic("tree: unexpected statement")
+}
+
+func let(s *jack.LetStmt) *Node {
+	return node("let", s.Pos(), s.Op, ident(s.Name), expression(s.Index), expression(s.Value))
+}
+
+// expression returns nil for a nil expr.
+func expression(expr jack.Expr) *Node {
+	switch x := expr.(type) {
+	case nil:
+		return nil
+	case *jack.Ident:
+		return ident(x)
+	case *jack.IntLit:
+		return node("int", x.Pos(), x.Value)
+	case *jack.StringLit:
+		return node("string", x.Pos(), x.Value)
+	case *jack.KeywordLit:
+		return node("keyword", x.Pos(), x.Value)
+	case *jack.IndexExpr:
+		return node("index", x.Pos(), "", ident(x.Name), expression(x.Index))
+	case *jack.CallExpr:
+		var receiver *Node
+		if x.Receiver != nil {
+			receiver = ident(x.Receiver)
+		}
+		n := node("call", x.Pos(), "", receiver, ident(x.Name))
+		for _, arg := range x.Args {
+			n.Children = append(n.Children, expression(arg))
+		}
+		return n
+	case *jack.UnaryExpr:
+		return node("unary", x.Pos(), x.Op, expression(x.X))
+	case *jack.BinaryExpr:
+		return node("binary", x.Pos(), x.Op, expression(x.X), expression(x.Y))
+	case *jack.ParenExpr:
+		return node("paren", x.Pos(), "", expression(x.X))
+	}
+
+	return node("bad", expr.Pos(), "")
+}
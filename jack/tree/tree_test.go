@@ -0,0 +1,110 @@
+package tree_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"jack"
+	"jack/tree"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSExpr(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			"1. empty subroutine",
+			"class Main { function void main() { return; } }",
+			`(class Main
+  (subroutine function void main (params) (vars) (statements (return nil))))
+`,
+		},
+		{
+			"2. declarations",
+			`class Ball {
+    field int x, y;
+    method void move(int dx, Ball b) {
+        var Array a;
+        return;
+    }
+}`,
+			`(class Ball
+  (classVar field int x y)
+  (subroutine method void move (params (param int dx) (param Ball b)) (vars (var Array a)) (statements (return nil))))
+`,
+		},
+		{
+			"3. statements and expressions",
+			`class Main {
+    function int f(int i) {
+        let a[i] = -i + (2 * i);
+        if (~b) { do Output.printString("hi"); } else { do g(null, this); }
+        while (true) { let i = i - 1; }
+        if (false) { return 1; }
+        return a[0];
+    }
+}`,
+			`(class Main
+  (subroutine function int f (params (param int i)) (vars) (statements ` +
+				`(let a i (binary + (unary - i) (paren (binary * 2 i)))) ` +
+				`(if (unary ~ b) (statements (do (call Output printString "hi"))) (statements (do (call nil g null this)))) ` +
+				`(while true (statements (let i nil (binary - i 1)))) ` +
+				`(if false (statements (return 1)) nil) ` +
+				`(return (index a 0)))))
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class, errs := jack.Parse([]byte(tt.src))
+			assert.NoError(t, errs.Err())
+			assert.Equal(t, tt.want, string(tree.SExpr(class)))
+		})
+	}
+}
+
+func TestSExpr_Extended(t *testing.T) {
+	class, errs := jack.ParseMode([]byte(`class Main {
+    function void f() {
+        for (i = 0; ; i += 1) { if (i) { break; } else if (j) { continue; } }
+        return;
+    }
+}`), jack.Extended)
+	assert.NoError(t, errs.Err())
+	assert.Contains(t, string(tree.SExpr(class)), "(statements "+
+		"(for (let i nil 0) nil (let + i nil 1) (statements (if i (statements (break)) (statements (if j (statements (continue)) nil))))) "+
+		"(return nil))")
+}
+
+func TestJSON(t *testing.T) {
+	class, errs := jack.Parse([]byte(`class Main {
+    function void main() {
+        let x = 1;
+        return;
+    }
+}`))
+	assert.NoError(t, errs.Err())
+
+	data, err := tree.JSON(class)
+	assert.NoError(t, err)
+
+	var got tree.Node
+	assert.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, tree.New(class), &got)
+
+	let := got.Children[0].Children[4].Children[0]
+	assert.Equal(t, "let", let.Kind)
+	assert.Equal(t, 3, let.Line)
+	assert.Equal(t, 9, let.Column)
+	assert.Nil(t, let.Children[1])
+	assert.Equal(t, &tree.Node{Kind: "int", Line: 3, Column: 17, Value: "1"}, let.Children[2])
+	ret := got.Children[0].Children[4].Children[1]
+	assert.Equal(t, "return", ret.Kind)
+	assert.Equal(t, []*tree.Node{nil}, ret.Children)
+	assert.Contains(t, string(data), `"kind": "params",`)
+}
@@ -0,0 +1,451 @@
+// Package vm generates VM code from Jack syntax trees.
+package vm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"jack"
+)
+
+// generator translates a class to VM code.
+type generator struct {
+	out       strings.Builder
+	class     string
+	classSB   *jack.SymbolTable
+	routineSB *jack.SymbolTable
+	sub       *jack.SubroutineDecl
+	start     int // label after the prologue, for tail calls
+	labels    int
+	loops     []loop // innermost last
+	opts      Options
+	strings   map[string]int // static of each interned string
+	warnings  jack.ErrorList
+}
+
+// loop holds the labels that continue and break jump to.
+type loop struct {
+	next, end int
+}
+
+// Options configures Generate.
+type Options struct {
+	// InternStrings allocates each distinct string constant of a class
+	// once, the first time it is evaluated, and keeps it in a static
+	// variable after those of the class. Strings are mutable, so this
+	// is only correct if string constants are never modified. The strings
+	// past the static segment are allocated each time, as without it.
+	InternStrings bool
+
+	// TailCalls turns return f(...), where f is the enclosing function or
+	// method (of this), into a jump back to its start once the arguments
+	// are replaced by the new ones, so the recursion uses no stack. The
+	// local variables are cleared as by a call.
+	TailCalls bool
+}
+
+// Generate returns the VM code of class, which must be free of syntax
+// errors, and warnings about the local variables and arguments that
+// shadow a field or a static.
+func Generate(class *jack.ClassDecl, opts Options) (string, jack.ErrorList) {
+	g := &generator{
+		class:   class.Name.Name,
+		classSB: jack.NewSymbolTable(),
+		opts:    opts,
+		strings: make(map[string]int),
+	}
+	for _, dec := range class.Vars {
+		for _, name := range dec.Names {
+			g.classSB.Define(dec.Type.Name, name.Name, jack.WhichKind(dec.Kind))
+		}
+	}
+	for _, sub := range class.Subroutines {
+		g.subroutine(sub)
+	}
+
+	return strings.TrimSuffix(g.out.String(), "\n"), g.warnings
+}
+
+func (g *generator) emit(format string, args ...interface{}) {
+	g.out.WriteString("    " + fmt.Sprintf(format, args...) + "\n")
+}
+
+func (g *generator) label(l int) {
+	fmt.Fprintf(&g.out, "label L%d\n", l)
+}
+
+func (g *generator) newLabel() int {
+	g.labels++
+	return g.labels - 1
+}
+
+func (g *generator) define(vType string, name *jack.Ident, kind jack.VariableKind) {
+	if g.classSB.IsExists(name.Name) {
+		g.warnings = append(g.warnings, &jack.Error{
+			Pos: name.Pos(),
+			Msg: fmt.Sprintf("%v %v shadows %v %v",
+				kind, name.Name, classKind(g.classSB.KindOf(name.Name)), name.Name),
+		})
+	}
+	g.routineSB.Define(vType, name.Name, kind)
+}
+
+func (g *generator) subroutine(sub *jack.SubroutineDecl) {
+	g.routineSB = jack.NewSymbolTable()
+	if sub.Kind == "method" {
+		g.routineSB.Define(g.class, "this", jack.Arg)
+	}
+	for _, param := range sub.Params {
+		g.define(param.Type.Name, param.Name, jack.Arg)
+	}
+	for _, dec := range sub.Vars {
+		for _, name := range dec.Names {
+			g.define(dec.Type.Name, name, jack.Var)
+		}
+	}
+
+	fmt.Fprintf(&g.out, "function %v.%v %d\n", g.class, sub.Name.Name, g.routineSB.VarCount(jack.Var))
+	switch sub.Kind {
+	case "constructor":
+		g.emit("push constant %d", g.classSB.VarCount(jack.Field))
+		g.emit("call Memory.alloc 1")
+		g.emit("pop pointer 0")
+	case "method":
+		g.emit("push argument 0")
+		g.emit("pop pointer 0")
+	}
+	g.sub = sub
+	if g.opts.TailCalls && g.hasTailCall(sub.Body) {
+		g.start = g.newLabel()
+		g.label(g.start)
+	}
+	g.statements(sub.Body)
+}
+
+// hasTailCall reports whether stmts return a call of the subroutine.
+func (g *generator) hasTailCall(stmts []jack.Stmt) bool {
+	found := false
+	for _, stmt := range stmts {
+		jack.Inspect(stmt, func(node jack.Node) bool {
+			if s, ok := node.(*jack.ReturnStmt); ok && g.tailCall(s.Value) != nil {
+				found = true
+			}
+			return !found
+		})
+	}
+
+	return found
+}
+
+// tailCall returns value if it is a call of the subroutine by itself, nil
+// otherwise. A method must be called on this.
+func (g *generator) tailCall(value jack.Expr) *jack.CallExpr {
+	for {
+		paren, ok := value.(*jack.ParenExpr)
+		if !ok {
+			break
+		}
+		value = paren.X
+	}
+	call, ok := value.(*jack.CallExpr)
+	if !ok || call.Name.Name != g.sub.Name.Name || len(call.Args) != len(g.sub.Params) {
+		return nil
+	}
+
+	switch {
+	case g.sub.Kind == "method" && call.Receiver == nil:
+		return call
+	case g.sub.Kind == "function" && call.Receiver != nil &&
+		call.Receiver.Name == g.class && g.lookup(g.class) == nil:
+		return call
+	}
+
+	return nil
+}
+
+// lookup returns the table that defines name, nil if it is not a
+// variable. The subroutine scope shadows the class scope.
+func (g *generator) lookup(name string) *jack.SymbolTable {
+	if g.routineSB.IsExists(name) {
+		return g.routineSB
+	}
+	if g.classSB.IsExists(name) {
+		return g.classSB
+	}
+
+	return nil
+}
+
+func (g *generator) push(name string) {
+	if sb := g.lookup(name); sb != nil {
+		kind, idx := sb.GetSegment(name)
+		g.emit("push %v %d", kind, idx)
+	}
+}
+
+func (g *generator) pop(name string) {
+	if sb := g.lookup(name); sb != nil {
+		kind, idx := sb.GetSegment(name)
+		g.emit("pop %v %d", kind, idx)
+	}
+}
+
+func (g *generator) statements(stmts []jack.Stmt) {
+	for _, stmt := range stmts {
+		g.statement(stmt)
+	}
+}
+
+func (g *generator) statement(stmt jack.Stmt) {
+	switch s := stmt.(type) {
+	case *jack.LetStmt:
+		g.let(s)
+	case *jack.IfStmt:
+		g.expression(s.Cond)
+		end, alt := g.newLabel(), g.newLabel()
+		g.emit("not")
+		g.emit("if-goto L%d", alt)
+		g.statements(s.Then)
+		g.emit("goto L%d", end)
+		g.label(alt)
+		g.statements(s.Alt)
+		g.label(end)
+	case *jack.WhileStmt:
+		start := g.newLabel()
+		g.label(start)
+		end := g.newLabel()
+		g.expression(s.Cond)
+		g.emit("not")
+		g.emit("if-goto L%d", end)
+		g.loops = append(g.loops, loop{next: start, end: end})
+		g.statements(s.Body)
+		g.loops = g.loops[:len(g.loops)-1]
+		g.emit("goto L%d", start)
+		g.label(end)
+	case *jack.ForStmt:
+		if s.Init != nil {
+			g.let(s.Init)
+		}
+		start := g.newLabel()
+		g.label(start)
+		next, end := g.newLabel(), g.newLabel()
+		if s.Cond != nil {
+			g.expression(s.Cond)
+			g.emit("not")
+			g.emit("if-goto L%d", end)
+		}
+		g.loops = append(g.loops, loop{next: next, end: end})
+		g.statements(s.Body)
+		g.loops = g.loops[:len(g.loops)-1]
+		g.label(next)
+		if s.Post != nil {
+			g.let(s.Post)
+		}
+		g.emit("goto L%d", start)
+		g.label(end)
+	case *jack.BranchStmt:
+		l := g.loops[len(g.loops)-1]
+		if s.Tok == "break" {
+			g.emit("goto L%d", l.end)
+		} else {
+			g.emit("goto L%d", l.next)
+		}
+	case *jack.DoStmt:
+		g.expression(s.Call)
+		g.emit("pop temp 0")
+	case *jack.ReturnStmt:
+		if call := g.tailCall(s.Value); g.opts.TailCalls && call != nil {
+			g.jumpToStart(call)
+			break
+		}
+		if s.Value != nil {
+			g.expression(s.Value)
+		} else {
+			g.emit("push constant 0")
+		}
+		g.emit("return")
+	}
+}
+
+// jumpToStart replaces the arguments of the subroutine by those of call,
+// clears its local variables and jumps to its start.
+func (g *generator) jumpToStart(call *jack.CallExpr) {
+	for _, arg := range call.Args {
+		g.expression(arg)
+	}
+	first := 0
+	if g.sub.Kind == "method" {
+		first = 1
+	}
+	for i := len(call.Args) - 1; i >= 0; i-- {
+		g.emit("pop argument %d", first+i)
+	}
+	for i := 0; i < int(g.routineSB.VarCount(jack.Var)); i++ {
+		g.emit("push constant 0")
+		g.emit("pop local %d", i)
+	}
+	g.emit("goto L%d", g.start)
+}
+
+var opCommands = map[string]string{
+	"+": "add",
+	"-": "sub",
+	"*": "call Math.multiply 2",
+	"/": "call Math.divide 2",
+	"&": "and",
+	"|": "or",
+	"<": "lt",
+	">": "gt",
+	"=": "eq",
+}
+
+// let assigns a variable or an array element. A compound assignment
+// evaluates the index once: the address stays on the stack while the old
+// value is read through pointer 1.
+func (g *generator) let(s *jack.LetStmt) {
+	if s.Index == nil {
+		if s.Op != "" {
+			g.push(s.Name.Name)
+		}
+		g.expression(s.Value)
+		if s.Op != "" {
+			g.emit(opCommands[s.Op])
+		}
+		g.pop(s.Name.Name)
+		return
+	}
+
+	g.expression(s.Index)
+	g.push(s.Name.Name)
+	g.emit("add")
+	if s.Op != "" {
+		g.emit("pop pointer 1")
+		g.emit("push pointer 1")
+		g.emit("push that 0")
+	}
+	g.expression(s.Value)
+	if s.Op != "" {
+		g.emit(opCommands[s.Op])
+	}
+	g.emit("pop temp 0")
+	g.emit("pop pointer 1")
+	g.emit("push temp 0")
+	g.emit("pop that 0")
+}
+
+func (g *generator) expression(expr jack.Expr) {
+	switch x := expr.(type) {
+	case *jack.IntLit:
+		// push constant only takes 0..32767
+		if n, _ := strconv.Atoi(x.Value); n > 32767 {
+			g.emit("push constant %d", n^0xFFFF)
+			g.emit("not")
+		} else {
+			g.emit("push constant %v", x.Value)
+		}
+	case *jack.StringLit:
+		if g.opts.InternStrings {
+			g.internedString(x.Value)
+		} else {
+			g.newString(x.Value)
+		}
+	case *jack.KeywordLit:
+		switch x.Value {
+		case "true":
+			g.emit("push constant 1")
+			g.emit("neg")
+		case "this":
+			g.emit("push pointer 0")
+		default:
+			g.emit("push constant 0")
+		}
+	case *jack.Ident:
+		g.push(x.Name)
+	case *jack.IndexExpr:
+		g.expression(x.Index)
+		g.push(x.Name.Name)
+		g.emit("add")
+		g.emit("pop pointer 1")
+		g.emit("push that 0")
+	case *jack.CallExpr:
+		g.call(x)
+	case *jack.UnaryExpr:
+		g.expression(x.X)
+		if x.Op == "-" {
+			g.emit("neg")
+		} else {
+			g.emit("not")
+		}
+	case *jack.BinaryExpr:
+		g.expression(x.X)
+		g.expression(x.Y)
+		g.emit(opCommands[x.Op])
+	case *jack.ParenExpr:
+		g.expression(x.X)
+	}
+}
+
+func (g *generator) newString(value string) {
+	g.emit("push constant %d", utf8.RuneCountInString(value))
+	g.emit("call String.new 1")
+	for _, ch := range value {
+		g.emit("push constant %d", ch)
+		g.emit("call String.appendChar 2")
+	}
+}
+
+// statics is the size of the static segment, RAM 16 to 255.
+const statics = 240
+
+// internedString pushes the string kept in its static variable, which
+// is allocated if it is still 0 (null).
+func (g *generator) internedString(value string) {
+	index, ok := g.strings[value]
+	if !ok {
+		index = int(g.classSB.VarCount(jack.Static)) + len(g.strings)
+		if index >= statics {
+			g.newString(value)
+			return
+		}
+		g.strings[value] = index
+	}
+
+	done := g.newLabel()
+	g.emit("push static %d", index)
+	g.emit("if-goto L%d", done)
+	g.newString(value)
+	g.emit("pop static %d", index)
+	g.label(done)
+	g.emit("push static %d", index)
+}
+
+func (g *generator) call(x *jack.CallExpr) {
+	name, nArgs := x.Name.Name, len(x.Args)
+	switch {
+	case x.Receiver == nil:
+		g.emit("push pointer 0")
+		name = g.class + "." + name
+		nArgs++
+	case g.lookup(x.Receiver.Name) != nil:
+		g.push(x.Receiver.Name)
+		vType, _ := g.lookup(x.Receiver.Name).TypeOf(x.Receiver.Name)
+		name = vType + "." + name
+		nArgs++
+	default:
+		name = x.Receiver.Name + "." + name
+	}
+	for _, arg := range x.Args {
+		g.expression(arg)
+	}
+	g.emit("call %v %d", name, nArgs)
+}
+
+func classKind(kind jack.VariableKind) string {
+	if kind == jack.Field {
+		return "field"
+	}
+
+	return kind.String()
+}
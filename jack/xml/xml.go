@@ -0,0 +1,290 @@
+// Package xml writes Jack syntax trees and token streams in the XML format
+// of the nand2tetris analyzer (project 10).
+package xml
+
+import (
+	"bytes"
+	"strings"
+
+	"jack"
+)
+
+// Line endings. The reference files of the course use CRLF.
+const (
+	CRLF = "\r\n"
+	LF   = "\n"
+)
+
+var escaper = strings.NewReplacer("<", "&lt;", ">", "&gt;", "&", "&amp;", `"`, "&quot;")
+
+// printer writes nested elements, indented by two spaces.
+type printer struct {
+	out     bytes.Buffer
+	depth   int
+	newline string
+}
+
+func (p *printer) open(tag string) {
+	p.out.WriteString(strings.Repeat("  ", p.depth) + "<" + tag + ">" + p.newline)
+	p.depth++
+}
+
+func (p *printer) close(tag string) {
+	p.depth--
+	p.out.WriteString(strings.Repeat("  ", p.depth) + "</" + tag + ">" + p.newline)
+}
+
+// terminal writes a token.
+func (p *printer) terminal(tag, value string) {
+	p.out.WriteString(strings.Repeat("  ", p.depth) +
+		"<" + tag + "> " + escaper.Replace(value) + " </" + tag + ">" + p.newline)
+}
+
+func (p *printer) keyword(value string)    { p.terminal("keyword", value) }
+func (p *printer) symbol(value string)     { p.terminal("symbol", value) }
+func (p *printer) identifier(value string) { p.terminal("identifier", value) }
+
+// typ writes a type, primitive types are keywords.
+func (p *printer) typ(ident *jack.Ident) {
+	switch ident.Name {
+	case "int", "char", "boolean", "void":
+		p.keyword(ident.Name)
+	default:
+		p.identifier(ident.Name)
+	}
+}
+
+func (p *printer) names(names []*jack.Ident) {
+	for i, name := range names {
+		if i > 0 {
+			p.symbol(",")
+		}
+		p.identifier(name.Name)
+	}
+}
+
+// Tree returns the parse tree of class, which must be standard Jack free
+// of syntax errors, with lines ended by newline.
+func Tree(class *jack.ClassDecl, newline string) []byte {
+	p := printer{newline: newline}
+	p.open("class")
+	p.keyword("class")
+	p.identifier(class.Name.Name)
+	p.symbol("{")
+	for _, dec := range class.Vars {
+		p.open("classVarDec")
+		p.keyword(dec.Kind)
+		p.typ(dec.Type)
+		p.names(dec.Names)
+		p.symbol(";")
+		p.close("classVarDec")
+	}
+	for _, sub := range class.Subroutines {
+		p.subroutine(sub)
+	}
+	p.symbol("}")
+	p.close("class")
+
+	return p.out.Bytes()
+}
+
+func (p *printer) subroutine(sub *jack.SubroutineDecl) {
+	p.open("subroutineDec")
+	p.keyword(sub.Kind)
+	p.typ(sub.ReturnType)
+	p.identifier(sub.Name.Name)
+	p.symbol("(")
+	p.open("parameterList")
+	for i, param := range sub.Params {
+		if i > 0 {
+			p.symbol(",")
+		}
+		p.typ(param.Type)
+		p.identifier(param.Name.Name)
+	}
+	p.close("parameterList")
+	p.symbol(")")
+
+	p.open("subroutineBody")
+	p.symbol("{")
+	for _, dec := range sub.Vars {
+		p.open("varDec")
+		p.keyword("var")
+		p.typ(dec.Type)
+		p.names(dec.Names)
+		p.symbol(";")
+		p.close("varDec")
+	}
+	p.statements(sub.Body)
+	p.symbol("}")
+	p.close("subroutineBody")
+	p.close("subroutineDec")
+}
+
+func (p *printer) statements(stmts []jack.Stmt) {
+	p.open("statements")
+	for _, stmt := range stmts {
+		p.statement(stmt)
+	}
+	p.close("statements")
+}
+
+func (p *printer) statement(stmt jack.Stmt) {
+	switch s := stmt.(type) {
+	case *jack.LetStmt:
+		p.open("letStatement")
+		p.keyword("let")
+		p.identifier(s.Name.Name)
+		if s.Index != nil {
+			p.symbol("[")
+			p.expression(s.Index)
+			p.symbol("]")
+		}
+		p.symbol("=")
+		p.expression(s.Value)
+		p.symbol(";")
+		p.close("letStatement")
+	case *jack.IfStmt:
+		p.open("ifStatement")
+		p.keyword("if")
+		p.condition(s.Cond)
+		p.block(s.Then)
+		if s.Else.IsValid() {
+			p.keyword("else")
+			p.block(s.Alt)
+		}
+		p.close("ifStatement")
+	case *jack.WhileStmt:
+		p.open("whileStatement")
+		p.keyword("while")
+		p.condition(s.Cond)
+		p.block(s.Body)
+		p.close("whileStatement")
+	case *jack.DoStmt:
+		p.open("doStatement")
+		p.keyword("do")
+		p.call(s.Call)
+		p.symbol(";")
+		p.close("doStatement")
+	case *jack.ReturnStmt:
+		p.open("returnStatement")
+		p.keyword("return")
+		if s.Value != nil {
+			p.expression(s.Value)
+		}
+		p.symbol(";")
+		p.close("returnStatement")
+	}
+}
+
+func (p *printer) condition(cond jack.Expr) {
+	p.symbol("(")
+	p.expression(cond)
+	p.symbol(")")
+}
+
+func (p *printer) block(stmts []jack.Stmt) {
+	p.symbol("{")
+	p.statements(stmts)
+	p.symbol("}")
+}
+
+// expression writes term (op term)*. Binary expressions nest to the left
+// only, parentheses aside, so the terms are those of the left spine.
+func (p *printer) expression(expr jack.Expr) {
+	p.open("expression")
+	p.operands(expr)
+	p.close("expression")
+}
+
+func (p *printer) operands(expr jack.Expr) {
+	if x, ok := expr.(*jack.BinaryExpr); ok {
+		p.operands(x.X)
+		p.symbol(x.Op)
+		p.term(x.Y)
+		return
+	}
+	p.term(expr)
+}
+
+func (p *printer) term(expr jack.Expr) {
+	p.open("term")
+	switch x := expr.(type) {
+	case *jack.IntLit:
+		p.terminal("integerConstant", x.Value)
+	case *jack.StringLit:
+		p.terminal("stringConstant", x.Value)
+	case *jack.KeywordLit:
+		p.keyword(x.Value)
+	case *jack.Ident:
+		p.identifier(x.Name)
+	case *jack.IndexExpr:
+		p.identifier(x.Name.Name)
+		p.symbol("[")
+		p.expression(x.Index)
+		p.symbol("]")
+	case *jack.CallExpr:
+		p.call(x)
+	case *jack.ParenExpr:
+		p.condition(x.X)
+	case *jack.UnaryExpr:
+		p.symbol(x.Op)
+		p.term(x.X)
+	case *jack.BinaryExpr:
+		// only as the operand of a unary operator
+		p.operands(x)
+	}
+	p.close("term")
+}
+
+func (p *printer) call(x *jack.CallExpr) {
+	if x.Receiver != nil {
+		p.identifier(x.Receiver.Name)
+		p.symbol(".")
+	}
+	p.identifier(x.Name.Name)
+	p.symbol("(")
+	p.open("expressionList")
+	for i, arg := range x.Args {
+		if i > 0 {
+			p.symbol(",")
+		}
+		p.expression(arg)
+	}
+	p.close("expressionList")
+	p.symbol(")")
+}
+
+var tokenTags = map[jack.Token]string{
+	jack.KEYWORD: "keyword",
+	jack.SYMBOL:  "symbol",
+	jack.IDENT:   "identifier",
+	jack.INT:     "integerConstant",
+	jack.STRING:  "stringConstant",
+}
+
+// Tokens returns the tokens of src, without the comments, with lines
+// ended by newline, or the lexical errors of src.
+func Tokens(src []byte, newline string) ([]byte, error) {
+	var s jack.Scanner
+	s.Init(src)
+
+	var out bytes.Buffer
+	out.WriteString("<tokens>" + newline)
+	for {
+		tok, lit := s.Scan()
+		if tok == jack.EOF {
+			break
+		}
+		if tag, ok := tokenTags[tok]; ok {
+			out.WriteString("<" + tag + "> " + escaper.Replace(lit) + " </" + tag + ">" + newline)
+		}
+	}
+	out.WriteString("</tokens>" + newline)
+
+	if err := s.Errors().Err(); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,146 @@
+package xml_test
+
+import (
+	"strings"
+	"testing"
+
+	"jack"
+	"jack/xml"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTree(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			"1. empty parameter list",
+			"class Main { function void main() { return; } }",
+			[]string{
+				"<class>",
+				"  <keyword> class </keyword>",
+				"  <identifier> Main </identifier>",
+				"  <symbol> { </symbol>",
+				"  <subroutineDec>",
+				"    <keyword> function </keyword>",
+				"    <keyword> void </keyword>",
+				"    <identifier> main </identifier>",
+				"    <symbol> ( </symbol>",
+				"    <parameterList>",
+				"    </parameterList>",
+				"    <symbol> ) </symbol>",
+				"    <subroutineBody>",
+				"      <symbol> { </symbol>",
+				"      <statements>",
+				"        <returnStatement>",
+				"          <keyword> return </keyword>",
+				"          <symbol> ; </symbol>",
+				"        </returnStatement>",
+				"      </statements>",
+				"      <symbol> } </symbol>",
+				"    </subroutineBody>",
+				"  </subroutineDec>",
+				"  <symbol> } </symbol>",
+				"</class>",
+			},
+		},
+		{
+			"2. operators in one expression",
+			"class Main { function int f() { return 1 + (2 < 3) & -x; } }",
+			[]string{
+				"          <expression>",
+				"            <term>",
+				"              <integerConstant> 1 </integerConstant>",
+				"            </term>",
+				"            <symbol> + </symbol>",
+				"            <term>",
+				"              <symbol> ( </symbol>",
+				"              <expression>",
+				"                <term>",
+				"                  <integerConstant> 2 </integerConstant>",
+				"                </term>",
+				"                <symbol> &lt; </symbol>",
+				"                <term>",
+				"                  <integerConstant> 3 </integerConstant>",
+				"                </term>",
+				"              </expression>",
+				"              <symbol> ) </symbol>",
+				"            </term>",
+				"            <symbol> &amp; </symbol>",
+				"            <term>",
+				"              <symbol> - </symbol>",
+				"              <term>",
+				"                <identifier> x </identifier>",
+				"              </term>",
+				"            </term>",
+				"          </expression>",
+			},
+		},
+		{
+			"3. do statement without term",
+			"class Main { function void f() { do Output.printString(\"a\"); return; } }",
+			[]string{
+				"        <doStatement>",
+				"          <keyword> do </keyword>",
+				"          <identifier> Output </identifier>",
+				"          <symbol> . </symbol>",
+				"          <identifier> printString </identifier>",
+				"          <symbol> ( </symbol>",
+				"          <expressionList>",
+				"            <expression>",
+				"              <term>",
+				"                <stringConstant> a </stringConstant>",
+				"              </term>",
+				"            </expression>",
+				"          </expressionList>",
+				"          <symbol> ) </symbol>",
+				"          <symbol> ; </symbol>",
+				"        </doStatement>",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class, errs := jack.Parse([]byte(tt.src))
+			assert.NoError(t, errs.Err())
+
+			got := string(xml.Tree(class, xml.CRLF))
+			assert.True(t, strings.HasSuffix(got, "\r\n"))
+			assert.Contains(t, got, strings.Join(tt.want, "\r\n")+"\r\n")
+		})
+	}
+}
+
+func TestTokens(t *testing.T) {
+	got, err := xml.Tokens([]byte("if (x < 1) { let s = \"a & b\"; } // done"), xml.CRLF)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"<tokens>",
+		"<keyword> if </keyword>",
+		"<symbol> ( </symbol>",
+		"<identifier> x </identifier>",
+		"<symbol> &lt; </symbol>",
+		"<integerConstant> 1 </integerConstant>",
+		"<symbol> ) </symbol>",
+		"<symbol> { </symbol>",
+		"<keyword> let </keyword>",
+		"<identifier> s </identifier>",
+		"<symbol> = </symbol>",
+		"<stringConstant> a &amp; b </stringConstant>",
+		"<symbol> ; </symbol>",
+		"<symbol> } </symbol>",
+		"</tokens>",
+		"",
+	}, "\r\n"), string(got))
+
+	got, err = xml.Tokens([]byte("return;"), xml.LF)
+	assert.NoError(t, err)
+	assert.Equal(t, "<tokens>\n<keyword> return </keyword>\n<symbol> ; </symbol>\n</tokens>\n", string(got))
+
+	_, err = xml.Tokens([]byte("let s = \"abc"), xml.CRLF)
+	assert.EqualError(t, err, "1:9: string constant not terminated")
+}
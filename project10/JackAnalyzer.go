@@ -1,63 +1,111 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+
+	"jack"
+	"jack/tree"
+	"jack/xml"
+)
+
+var (
+	tokens  = flag.Bool("tokens", false, "also write the tokens of each file to xxxT.xml")
+	newline = flag.String("newline", "crlf", "line ending of the XML files, crlf or lf")
+	format  = flag.String("format", "xml", "format of the parse tree: xml, json or sexp")
+	outDir  = flag.String("o", "out", "output `dir`, other than that of the .jack files and their expected .xml files")
 )
 
 func main() {
-	os.Args = []string{"", "test/ExpressionLessSquare"}
-	os.Args[1] = "test/ExpressionLessSquare"
-	if len(os.Args) < 2 {
-		printErr("invalid number of arguments")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: JackAnalyzer [-tokens] [-newline crlf|lf] [-format xml|json|sexp] [-o dir] <file or directory>")
+		flag.PrintDefaults()
 	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	path := flag.Arg(0)
 
-	jackFiles := make([]string, 0)
-
-	// Open file
-	file, err := os.OpenFile(os.Args[1], os.O_RDONLY, 0)
-	if err != nil {
-		printErr(fmt.Sprintf("%s file not exists\n", os.Args[1]))
+	nl := xml.CRLF
+	switch *newline {
+	case "crlf":
+	case "lf":
+		nl = xml.LF
+	default:
+		printErr(fmt.Sprintf("invalid line ending %q, want crlf or lf\n", *newline))
+	}
+	switch *format {
+	case "xml", "json", "sexp":
+	default:
+		printErr(fmt.Sprintf("invalid format %q, want xml, json or sexp\n", *format))
 	}
-	defer file.Close()
 
-	fileInfo, err := file.Stat()
+	info, err := os.Stat(path)
 	if err != nil {
-		printErr(err.Error())
+		printErr(fmt.Sprintf("%s file not exists\n", path))
 	}
 
-	isDir := false
-	if fileInfo.IsDir() {
-		isDir = true
-		jackFiles = getJackFiles(file.Name())
-	} else {
-		jackFiles = append(jackFiles, file.Name())
+	jackFiles := []string{path}
+	if info.IsDir() {
+		jackFiles = make([]string, 0)
+		for _, name := range getJackFiles(path) {
+			jackFiles = append(jackFiles, filepath.Join(path, name))
+		}
 	}
 
-	for _, jack := range jackFiles {
-		var parser Parser
-		readFile := jack
-		if isDir {
-			readFile = os.Args[1] + "/" + jack
+	// the .xml files next to the .jack files are the expected outputs
+	out, _ := filepath.Abs(*outDir)
+	for _, file := range jackFiles {
+		if dir, _ := filepath.Abs(filepath.Dir(file)); dir == out {
+			printErr(fmt.Sprintf("output directory %s is that of %s\n", *outDir, file))
 		}
-		src, err := os.ReadFile(readFile)
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		printErr(err.Error())
+	}
+
+	for _, file := range jackFiles {
+		src, err := os.ReadFile(file)
 		if err != nil {
 			printErr(err.Error())
 		}
-		parser.Init(jack, src)
-		parser.ParseFile()
-
-		fileName := ""
-		if isDir {
-			fileName = os.Args[1] + "/" + jack[:strings.Index(jack, ".jack")]
-		} else {
-			fileName = os.Args[1][:strings.Index(os.Args[1], ".jack")]
+		base := filepath.Join(*outDir, strings.TrimSuffix(filepath.Base(file), ".jack"))
+
+		if *tokens {
+			out, err := xml.Tokens(src, nl)
+			if err != nil {
+				printErr(fmt.Sprintf("%s:%v\n", file, err))
+			}
+			writeFile(base+"T.xml", out)
 		}
-		writeErr := os.WriteFile(fileName+".xml", []byte(parser.Out()), 0644)
-		if writeErr != nil {
-			printErr(err.Error())
+
+		class, errs := jack.Parse(src)
+		if len(errs) > 0 {
+			printErr(fmt.Sprintf("%s:%v\n", file, errs[0]))
 		}
+		switch *format {
+		case "xml":
+			writeFile(base+".xml", xml.Tree(class, nl))
+		case "json":
+			out, err := tree.JSON(class)
+			if err != nil {
+				printErr(err.Error())
+			}
+			writeFile(base+".json", out)
+		case "sexp":
+			writeFile(base+".sexp", tree.SExpr(class))
+		}
+	}
+}
+
+func writeFile(name string, data []byte) {
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		printErr(err.Error())
 	}
 }
 
@@ -2,8 +2,13 @@ package main
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"jack"
+	"jack/xml"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -78,16 +83,39 @@ func TestParser_ParseFile(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			src, err := os.ReadFile(tt.fields.dest)
 			assert.NoError(t, err)
-			var p Parser
-			p.Init("", src)
-			p.ParseFile()
+			class, errs := jack.Parse(src)
+			assert.NoError(t, errs.Err())
+			out := xml.Tree(class, xml.CRLF)
 
-			err = os.WriteFile(tt.fields.out, []byte(p.Out()), 0644)
+			err = os.WriteFile(tt.fields.out, out, 0644)
 			assert.NoError(t, err)
 
 			want, err := os.ReadFile(tt.fields.want)
 			assert.NoError(t, err)
-			assert.Equal(t, string(want), p.Out())
+			assert.Equal(t, string(want), string(out))
+		})
+	}
+}
+
+// TestTokens compares the tokens with the xxxT.xml files of the course.
+func TestTokens(t *testing.T) {
+	files, err := filepath.Glob("./test/*/*.jack")
+	assert.NoError(t, err)
+
+	for _, file := range files {
+		t.Run(file, func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			assert.NoError(t, err)
+			out, err := xml.Tokens(src, xml.CRLF)
+			assert.NoError(t, err)
+
+			base := strings.TrimSuffix(file, ".jack")
+			err = os.WriteFile(base+"T.test.xml", out, 0644)
+			assert.NoError(t, err)
+
+			want, err := os.ReadFile(base + "T.xml")
+			assert.NoError(t, err)
+			assert.Equal(t, string(want), string(out))
 		})
 	}
 }
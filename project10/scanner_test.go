@@ -1,7 +1,7 @@
 package main_test
 
 import (
-	pkg "project10"
+	pkg "jack"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -187,7 +187,7 @@ func TestScanner_Scan(t *testing.T) {
 					lit: "=",
 				},
 				{
-					tok: pkg.CHAR,
+					tok: pkg.STRING,
 					lit: `negative`,
 				},
 			},
@@ -245,7 +245,7 @@ func TestScanner_Scan(t *testing.T) {
 					lit: "(",
 				},
 				{
-					tok: pkg.CHAR,
+					tok: pkg.STRING,
 					lit: `HOW MANY NUMBERS? `,
 				},
 				{
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"jack"
+	"jack/vm"
+)
+
+// BuildCacheFile is the cache of Build in the output directory.
+const BuildCacheFile = ".jackcache"
+
+// CheckShadow reports the variables of a subroutine that hide a variable
+// of the class. It is a warning of the compiler, not of Lint.
+const CheckShadow = "shadow"
+
+// BuildOptions configures Build.
+type BuildOptions struct {
+	Optimize bool // optimize the generated VM code, tail calls included
+	Extended bool // accept the extended language, see jack.Extended
+	Workers  int  // number of files compiled at once, NumCPU if 0
+	Force    bool // compile every file, even if up to date
+
+	// InternStrings allocates each string constant once, see
+	// vm.Options.
+	InternStrings bool
+
+	// Inline expands the calls to the leaf functions and methods of the
+	// program that have at most Inline VM commands, see Inline. Calls are
+	// not expanded if it is 0.
+	Inline int
+
+	// Warn is called with the warnings of the compiled files, one call at
+	// a time. Warnings are ignored if it is nil.
+	Warn func(Diagnostic)
+}
+
+func (opts BuildOptions) mode() jack.Mode {
+	if opts.Extended {
+		return jack.Extended
+	}
+	return 0
+}
+
+// buildEntry records how the output of a file was built.
+type buildEntry struct {
+	Hash      string            `json:"hash"`      // hash of the source
+	Signature string            `json:"signature"` // hash of the subroutine declarations
+	Refs      map[string]string `json:"refs"`      // signatures of the classes used
+	Optimize  bool              `json:"optimize"`
+	Extended  bool              `json:"extended"`
+	Intern    bool              `json:"intern"`
+	Inline    int               `json:"inline"`
+}
+
+// Build compiles the .jack files of dir, given by name, to .an files in
+// dir. The files are compiled concurrently. A file is skipped if neither
+// its source nor the subroutine declarations of the classes it uses
+// changed since the last build recorded in BuildCacheFile; when calls are
+// inlined, a change anywhere in a class used by the file counts. Build
+// returns the names of the compiled files.
+func Build(dir string, files []string, opts BuildOptions) ([]string, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	cache := make(map[string]*buildEntry)
+	if data, err := os.ReadFile(filepath.Join(dir, BuildCacheFile)); err == nil {
+		// a broken cache is rebuilt
+		_ = json.Unmarshal(data, &cache)
+	}
+
+	// the signatures of every class of the program are needed, even if
+	// only some files are built
+	all, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	classes := make(map[string]bool)
+	for _, f := range all {
+		if strings.HasSuffix(f.Name(), ".jack") {
+			classes[strings.TrimSuffix(f.Name(), ".jack")] = true
+		}
+	}
+
+	srcs := make(map[string][]byte)
+	entries := make(map[string]*buildEntry)
+	changed := make(map[string]bool)
+	signatures := make(map[string]string)
+	for class := range classes {
+		file := class + ".jack"
+		src, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		srcs[file] = src
+
+		entry := &buildEntry{
+			Hash:     hash(src),
+			Optimize: opts.Optimize,
+			Extended: opts.Extended,
+			Intern:   opts.InternStrings,
+			Inline:   opts.Inline,
+		}
+		if old, ok := cache[file]; ok && old != nil && old.Hash == entry.Hash {
+			entry.Signature, entry.Refs = old.Signature, old.Refs
+		} else {
+			class, _ := jack.ParseMode(src, opts.mode())
+			entry.Signature = classSignature(class)
+			if opts.Inline > 0 {
+				// the callers may contain the code of any subroutine
+				entry.Signature = entry.Hash
+			}
+			entry.Refs = classRefs(class, classes)
+			changed[file] = true
+		}
+		entries[file] = entry
+		signatures[class] = entry.Signature
+	}
+
+	todo := make([]string, 0, len(files))
+	build := make(map[string]bool, len(files))
+	for _, file := range files {
+		build[file] = true
+		entry, old := entries[file], cache[file]
+		if entry == nil {
+			return nil, fmt.Errorf("%v: not a .jack file of %v", file, dir)
+		}
+		stale := opts.Force || changed[file] ||
+			old.Optimize != opts.Optimize || old.Extended != opts.Extended ||
+			old.Intern != opts.InternStrings || old.Inline != opts.Inline
+		for class := range entry.Refs {
+			if !changed[file] && old.Refs[class] != signatures[class] {
+				stale = true
+			}
+		}
+		if _, err := os.Stat(outputPath(dir, file)); err != nil {
+			stale = true
+		}
+		if stale {
+			todo = append(todo, file)
+		}
+	}
+
+	// the files that are not built keep their entry if it is up to date
+	for file := range entries {
+		if build[file] {
+			continue
+		}
+		if changed[file] {
+			delete(entries, file)
+		} else {
+			entries[file] = cache[file]
+		}
+	}
+	for file := range build {
+		refs := entries[file].Refs
+		for class := range refs {
+			refs[class] = signatures[class]
+		}
+	}
+
+	// the expanded functions may be in any class of the program
+	var inlinable Inlinable
+	if opts.Inline > 0 && len(todo) > 0 {
+		inlinable = make(Inlinable)
+		for _, src := range srcs {
+			// the errors are reported when the file is built
+			if out, _, err := compile(src, opts, nil); err == nil {
+				inlinable.Add(out, opts.Inline)
+			}
+		}
+	}
+
+	jobs := make(chan int)
+	errs := make([]error, len(todo))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				out, warnings, err := compile(srcs[todo[i]], opts, inlinable)
+				if err != nil {
+					lines := make([]error, 0)
+					for _, e := range err.(jack.ErrorList) {
+						lines = append(lines, fmt.Errorf("%v:%v", filepath.Join(dir, todo[i]), e))
+					}
+					errs[i] = errors.Join(lines...)
+					continue
+				}
+				errs[i] = os.WriteFile(outputPath(dir, todo[i]), []byte(out), 0644)
+				if opts.Warn == nil {
+					continue
+				}
+				mu.Lock()
+				for _, d := range warnings {
+					d.File = filepath.Join(dir, todo[i])
+					opts.Warn(d)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range todo {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			delete(entries, todo[i])
+		}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, BuildCacheFile), data, 0644)
+	}
+
+	return todo, errors.Join(append(errs, err)...)
+}
+
+// Compile translates the source of a class to VM code.
+func Compile(src []byte, optimize bool) (string, error) {
+	out, _, err := compile(src, BuildOptions{Optimize: optimize}, nil)
+	return out, err
+}
+
+// compile translates the source of a class and expands the calls to the
+// functions of inlinable, if it is not nil.
+func compile(src []byte, opts BuildOptions, inlinable Inlinable) (string, []Diagnostic, error) {
+	class, errs := jack.ParseMode(src, opts.mode())
+	if err := errs.Err(); err != nil {
+		return "", nil, err
+	}
+	out, shadows := vm.Generate(class, vm.Options{
+		InternStrings: opts.InternStrings,
+		TailCalls:     opts.Optimize,
+	})
+	if opts.Optimize {
+		out = Optimize(out)
+	}
+	if inlinable != nil {
+		out = Inline(out, inlinable)
+		if opts.Optimize {
+			out = Optimize(out)
+		}
+	}
+
+	warnings := make([]Diagnostic, 0, len(shadows))
+	for _, e := range shadows {
+		warnings = append(warnings, Diagnostic{Pos: e.Pos, Check: CheckShadow, Msg: e.Msg})
+	}
+
+	return out, warnings, nil
+}
+
+func outputPath(dir, file string) string {
+	return filepath.Join(dir, strings.TrimSuffix(file, ".jack")+".an")
+}
+
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// classSignature hashes the declarations of the subroutines of class,
+// which is all that other classes depend on.
+func classSignature(class *jack.ClassDecl) string {
+	var b strings.Builder
+	for _, sub := range class.Subroutines {
+		b.WriteString(signature(class, sub) + "\n")
+	}
+
+	return hash([]byte(b.String()))
+}
+
+// classRefs returns the classes of the program used by class, with empty
+// signatures.
+func classRefs(class *jack.ClassDecl, classes map[string]bool) map[string]string {
+	refs := make(map[string]string)
+	jack.Inspect(class, func(node jack.Node) bool {
+		if ident, ok := node.(*jack.Ident); ok && classes[ident.Name] && ident.Name != class.Name.Name {
+			refs[ident.Name] = ""
+		}
+		return true
+	})
+
+	return refs
+}
@@ -0,0 +1,136 @@
+package main_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pkg "project11"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, src string) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(src), 0644))
+	}
+	write("Main.jack", `class Main {
+    function void main() {
+        do Ball.draw(1);
+        return;
+    }
+}`)
+	write("Ball.jack", `class Ball {
+    function void draw(int x) {
+        return;
+    }
+}`)
+	write("Bat.jack", `class Bat {
+    function void draw() {
+        return;
+    }
+}`)
+	files := []string{"Ball.jack", "Bat.jack", "Main.jack"}
+
+	tests := []struct {
+		name   string
+		change func()
+		opts   pkg.BuildOptions
+		want   []string
+	}{
+		{"1. first build", func() {}, pkg.BuildOptions{}, files},
+		{"2. up to date", func() {}, pkg.BuildOptions{}, []string{}},
+		{
+			"3. body change",
+			func() { write("Ball.jack", "class Ball { function void draw(int x) { let x = 1; return; } }") },
+			pkg.BuildOptions{},
+			[]string{"Ball.jack"},
+		},
+		{
+			"4. signature change",
+			func() { write("Ball.jack", "class Ball { function void draw(int x, int y) { return; } }") },
+			pkg.BuildOptions{Workers: 1},
+			[]string{"Ball.jack", "Main.jack"},
+		},
+		{"5. options change", func() {}, pkg.BuildOptions{Optimize: true}, files},
+		{
+			"6. missing output",
+			func() { assert.NoError(t, os.Remove(filepath.Join(dir, "Bat.an"))) },
+			pkg.BuildOptions{Optimize: true},
+			[]string{"Bat.jack"},
+		},
+		{"7. forced", func() {}, pkg.BuildOptions{Optimize: true, Force: true}, files},
+		{"8. language change", func() {}, pkg.BuildOptions{Optimize: true, Extended: true}, files},
+		{"9. interned strings", func() {}, pkg.BuildOptions{Optimize: true, Extended: true, InternStrings: true}, files},
+		{"10. inlining", func() {}, pkg.BuildOptions{Optimize: true, Inline: 10}, files},
+		{
+			"11. body change with inlining",
+			func() { write("Ball.jack", "class Ball { function void draw(int x, int y) { let x = 2; return; } }") },
+			pkg.BuildOptions{Optimize: true, Inline: 10},
+			[]string{"Ball.jack", "Main.jack"},
+		},
+		{
+			"12. null cache entry",
+			func() {
+				name := filepath.Join(dir, pkg.BuildCacheFile)
+				data, err := os.ReadFile(name)
+				assert.NoError(t, err)
+				cache := make(map[string]json.RawMessage)
+				assert.NoError(t, json.Unmarshal(data, &cache))
+				cache["Ball.jack"] = json.RawMessage("null")
+				data, err = json.Marshal(cache)
+				assert.NoError(t, err)
+				assert.NoError(t, os.WriteFile(name, data, 0644))
+			},
+			pkg.BuildOptions{Optimize: true, Inline: 10},
+			[]string{"Ball.jack"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change()
+			got, err := pkg.Build(dir, files, tt.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestBuild_Programs checks that a parallel build gives the output of
+// the sequential compiler.
+func TestBuild_Programs(t *testing.T) {
+	dirs, err := filepath.Glob("./test/*")
+	assert.NoError(t, err)
+
+	for _, dir := range dirs {
+		t.Run(dir, func(t *testing.T) {
+			out := t.TempDir()
+			files, err := filepath.Glob(filepath.Join(dir, "*.jack"))
+			assert.NoError(t, err)
+			names := make([]string, 0)
+			for _, file := range files {
+				src, err := os.ReadFile(file)
+				assert.NoError(t, err)
+				name := filepath.Base(file)
+				assert.NoError(t, os.WriteFile(filepath.Join(out, name), src, 0644))
+				names = append(names, name)
+			}
+
+			_, err = pkg.Build(out, names, pkg.BuildOptions{Workers: 4})
+			assert.NoError(t, err)
+			for _, file := range files {
+				src, err := os.ReadFile(file)
+				assert.NoError(t, err)
+				got, err := os.ReadFile(filepath.Join(out, strings.TrimSuffix(filepath.Base(file), ".jack")+".an"))
+				assert.NoError(t, err)
+				want, err := pkg.Compile(src, false)
+				assert.NoError(t, err)
+				assert.Equal(t, want, string(got))
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"strings"
+
+	"jack"
+)
+
+// indent is one level of indentation in formatted Jack code.
+const indent = "    "
+
+// lexeme is a token together with the source lines it spans.
+type lexeme struct {
+	tok     jack.Token
+	lit     string
+	line    int
+	endLine int
+}
+
+// formatter re-emits the tokens of a file in the canonical layout:
+// one declaration or statement per line, blocks indented by four spaces,
+// spaces around binary operators and at most one blank line in a row.
+type formatter struct {
+	out     strings.Builder
+	depth   int    // block nesting
+	parens  int    // parenthesis nesting
+	prev    lexeme // previous code token or comment
+	unary   bool   // prev is a unary operator
+	pending bool   // a newline must be written before the next token
+}
+
+// Format returns src in the canonical Jack layout, comments included.
+// Formatting is idempotent. Sources with syntax errors are not formatted,
+// Format returns the errors instead.
+func Format(src []byte) ([]byte, error) {
+	if _, errs := jack.Parse(src); len(errs) > 0 {
+		return nil, errs
+	}
+
+	var s jack.Scanner
+	s.Init(src)
+	lexemes := make([]lexeme, 0)
+	for {
+		tok, lit := s.Scan()
+		if tok == jack.EOF {
+			break
+		}
+		if tok == jack.COMMENT {
+			lit = strings.TrimRight(lit, "\r")
+		}
+		pos := s.Pos()
+		lexemes = append(lexemes, lexeme{
+			tok:     tok,
+			lit:     lit,
+			line:    pos.Line,
+			endLine: pos.Line + strings.Count(lit, "\n"),
+		})
+	}
+
+	var f formatter
+	for i, lex := range lexemes {
+		next := lexeme{tok: jack.EOF}
+		if i+1 < len(lexemes) {
+			next = lexemes[i+1]
+		}
+		if lex.tok == jack.COMMENT {
+			f.comment(lex, next)
+		} else {
+			f.token(lex, next)
+		}
+		f.prev = lex
+	}
+	f.out.WriteString("\n")
+
+	return []byte(f.out.String()), nil
+}
+
+// newline starts a new line for lex, keeping one blank line if there was
+// at least one in the source.
+func (f *formatter) newline(lex lexeme) {
+	if f.out.Len() > 0 {
+		f.out.WriteString("\n")
+		if lex.line > f.prev.endLine+1 && !f.isSymbol(f.prev, "{") && !f.isSymbol(lex, "}") {
+			f.out.WriteString("\n")
+		}
+	}
+	f.out.WriteString(strings.Repeat(indent, f.depth))
+	f.pending = false
+}
+
+func (f *formatter) isSymbol(lex lexeme, lit string) bool {
+	return lex.tok == jack.SYMBOL && lex.lit == lit
+}
+
+func (f *formatter) comment(lex lexeme, next lexeme) {
+	text := lex.lit
+	if f.out.Len() > 0 && lex.line == f.prev.endLine {
+		// trailing comment
+		f.out.WriteString(" ")
+	} else {
+		f.newline(lex)
+		text = f.reindent(text)
+	}
+	f.out.WriteString(text)
+
+	if strings.HasPrefix(text, "//") || next.line > lex.endLine {
+		f.pending = true
+	}
+}
+
+// reindent aligns the lines of a block comment that start with '*'.
+func (f *formatter) reindent(text string) string {
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(line, "*") {
+			lines[i] = strings.Repeat(indent, f.depth) + " " + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (f *formatter) token(lex lexeme, next lexeme) {
+	if f.isSymbol(lex, "}") {
+		f.depth--
+	}
+
+	unary := f.isUnary(lex)
+	if f.pending || f.out.Len() == 0 {
+		f.newline(lex)
+	} else if f.space(lex) {
+		f.out.WriteString(" ")
+	}
+	f.unary = unary
+
+	if lex.tok == jack.STRING {
+		f.out.WriteString(`"` + lex.lit + `"`)
+	} else {
+		f.out.WriteString(lex.lit)
+	}
+
+	switch {
+	case f.isSymbol(lex, "("):
+		f.parens++
+	case f.isSymbol(lex, ")"):
+		f.parens--
+	case f.isSymbol(lex, "{"):
+		f.depth++
+		f.pending = true
+	case f.isSymbol(lex, ";") && f.parens == 0:
+		f.pending = true
+	case f.isSymbol(lex, "}"):
+		// } else {
+		f.pending = next.tok != jack.KEYWORD || next.lit != "else"
+	}
+}
+
+// isUnary reports whether lex is a unary - or ~.
+func (f *formatter) isUnary(lex lexeme) bool {
+	if f.isSymbol(lex, "~") {
+		return true
+	}
+	if !f.isSymbol(lex, "-") {
+		return false
+	}
+
+	switch f.prev.tok {
+	case jack.SYMBOL:
+		return f.prev.lit != ")" && f.prev.lit != "]"
+	case jack.KEYWORD:
+		return f.prev.lit == "return"
+	}
+
+	return false
+}
+
+// space reports whether a space separates lex from the previous token of
+// the same line.
+func (f *formatter) space(lex lexeme) bool {
+	if f.prev.tok == jack.COMMENT {
+		return true
+	}
+	if f.unary {
+		return false
+	}
+	if f.prev.tok == jack.SYMBOL {
+		switch f.prev.lit {
+		case "(", "[", ".":
+			return false
+		}
+	}
+	if lex.tok == jack.SYMBOL {
+		switch lex.lit {
+		case ";", ",", ")", "]", ".", "[":
+			return false
+		case "(":
+			return f.prev.tok != jack.IDENT
+		}
+	}
+
+	return true
+}
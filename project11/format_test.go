@@ -0,0 +1,116 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"jack"
+	pkg "project11"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			"1. indentation and spacing",
+			"class Main{\nfunction void main(){var int x,y;\nlet x=-1+(2*y);let y = Math.max( x,~y );return;}}",
+			"class Main {\n" +
+				"    function void main() {\n" +
+				"        var int x, y;\n" +
+				"        let x = -1 + (2 * y);\n" +
+				"        let y = Math.max(x, ~y);\n" +
+				"        return;\n" +
+				"    }\n" +
+				"}\n",
+		},
+		{
+			"2. if else and while",
+			"class Main {\n  method void f() {\n    if(x<0)\n    {\n      let a[i]=x-(-1);\n    }\n    else\n    {\n      while(i>0){let i=i-1;}\n    }\n    return;\n  }\n}",
+			"class Main {\n" +
+				"    method void f() {\n" +
+				"        if (x < 0) {\n" +
+				"            let a[i] = x - (-1);\n" +
+				"        } else {\n" +
+				"            while (i > 0) {\n" +
+				"                let i = i - 1;\n" +
+				"            }\n" +
+				"        }\n" +
+				"        return;\n" +
+				"    }\n" +
+				"}\n",
+		},
+		{
+			"3. comments and blank lines",
+			"// header\nclass Main {\n\n\n  /** Entry point.\n      * Prints a string. */\n  function void main() {\n    do Output.printString(\"a  b\"); // trailing\n\n\n    // leading\n    return;\n  }\n}\n",
+			"// header\n" +
+				"class Main {\n" +
+				"    /** Entry point.\n" +
+				"     * Prints a string. */\n" +
+				"    function void main() {\n" +
+				"        do Output.printString(\"a  b\"); // trailing\n" +
+				"\n" +
+				"        // leading\n" +
+				"        return;\n" +
+				"    }\n" +
+				"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pkg.Format([]byte(tt.src))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestFormat_SyntaxError(t *testing.T) {
+	_, err := pkg.Format([]byte("class Main { function void main() { let x = ; } }"))
+	assert.Error(t, err)
+}
+
+// TestFormat_Programs checks that formatting keeps the tokens of the test
+// programs and that it is idempotent.
+func TestFormat_Programs(t *testing.T) {
+	files, err := filepath.Glob("./test/*/*.jack")
+	assert.NoError(t, err)
+
+	for _, file := range files {
+		t.Run(file, func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			assert.NoError(t, err)
+
+			once, err := pkg.Format(src)
+			assert.NoError(t, err)
+			twice, err := pkg.Format(once)
+			assert.NoError(t, err)
+			assert.Equal(t, string(once), string(twice))
+			assert.Equal(t, scanAll(src), scanAll(once))
+		})
+	}
+}
+
+// scanAll returns the tokens of src, without the comments which are
+// reindented by Format.
+func scanAll(src []byte) []string {
+	var s jack.Scanner
+	s.Init(src)
+	lits := make([]string, 0)
+	for {
+		tok, lit := s.Scan()
+		if tok == jack.EOF {
+			return lits
+		}
+		if tok == jack.COMMENT {
+			continue
+		}
+		lits = append(lits, tok.String()+" "+lit)
+	}
+}
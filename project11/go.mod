@@ -2,10 +2,18 @@ module project11
 
 go 1.22.5
 
-require github.com/stretchr/testify v1.9.0
+require (
+	github.com/stretchr/testify v1.9.0
+	hack v0.0.0-00010101000000-000000000000
+	jack v0.0.0-00010101000000-000000000000
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace jack => ../jack
+
+replace hack => ../hack
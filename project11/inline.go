@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// inlineFunction is a function that Inline expands at its call sites.
+type inlineFunction struct {
+	name    string
+	nLocals int
+	body    []vmCommand // without the function command
+	static  bool        // uses the static segment, only expanded in its class
+	this    bool        // sets pointer 0, which the caller must keep
+}
+
+// Inlinable is the set of functions of a program that Inline expands.
+type Inlinable map[string]*inlineFunction
+
+// Add adds the leaf functions and methods of vm, the code of a class, that
+// have at most size commands. A leaf calls no function.
+func (fs Inlinable) Add(vm string, size int) {
+	cmds := parseVmCommands(vm)
+	for i := 0; i < len(cmds); {
+		end := i + 1
+		for end < len(cmds) && cmds[end].name != "function" {
+			end++
+		}
+		fn, body := cmds[i], cmds[i+1:end]
+		i = end
+		if fn.name != "function" || len(body) > size {
+			continue
+		}
+
+		f := &inlineFunction{name: fn.arg1, nLocals: fn.arg2, body: body}
+		leaf := true
+		for _, cmd := range body {
+			switch {
+			case cmd.name == "call":
+				leaf = false
+			case cmd.arg1 == "static":
+				f.static = true
+			case cmd.name == "pop" && cmd.arg1 == "pointer" && cmd.arg2 == 0:
+				f.this = true
+			}
+		}
+		if leaf {
+			fs[f.name] = f
+		}
+	}
+}
+
+// Inline expands the calls of vm to the functions of fs. The arguments and
+// the local variables of an expanded function become local variables of
+// the caller, after its own, and its returns jump to the end of the
+// expansion with the return value on the stack. If the function sets
+// pointer 0, the pointer of the caller is saved in one more local
+// variable. Expansions never overlap, since the functions of fs are
+// leaves, so all of them share the same local variables.
+func Inline(vm string, fs Inlinable) string {
+	cmds := parseVmCommands(vm)
+	out := make([]vmCommand, 0, len(cmds))
+	fn, extra, site := -1, 0, 0
+	for _, cmd := range cmds {
+		switch cmd.name {
+		case "function":
+			if fn >= 0 {
+				out[fn].arg2 += extra
+			}
+			fn, extra = len(out), 0
+		case "call":
+			f := fs[cmd.arg1]
+			if f == nil || fn < 0 || f.static && className(f.name) != className(out[fn].arg1) {
+				break
+			}
+			out = append(out, f.expand(out[fn].arg2, cmd.arg2, site)...)
+			extra = max(extra, f.frame(cmd.arg2))
+			site++
+			continue
+		}
+		out = append(out, cmd)
+	}
+	if fn >= 0 {
+		out[fn].arg2 += extra
+	}
+
+	return formatVmCommands(out)
+}
+
+// frame returns the number of local variables that an expansion with
+// nArgs arguments needs.
+func (f *inlineFunction) frame(nArgs int) int {
+	if f.this {
+		return nArgs + f.nLocals + 1
+	}
+
+	return nArgs + f.nLocals
+}
+
+// expand returns the commands that replace a call with nArgs arguments,
+// given the number of local variables of the caller. site numbers the
+// labels of the expansion.
+func (f *inlineFunction) expand(base, nArgs, site int) []vmCommand {
+	args, locals, save := base, base+nArgs, base+nArgs+f.nLocals
+	label := func(name string) string {
+		return fmt.Sprintf("%v.%v.%d", f.name, name, site)
+	}
+	end := fmt.Sprintf("%v.%d", f.name, site)
+
+	var cmds []vmCommand
+	if f.this {
+		cmds = append(cmds, vmCommand{"push", "pointer", 0}, vmCommand{"pop", "local", save})
+	}
+	for i := nArgs - 1; i >= 0; i-- {
+		cmds = append(cmds, vmCommand{"pop", "local", args + i})
+	}
+	for i := 0; i < f.nLocals; i++ {
+		cmds = append(cmds, vmCommand{"push", "constant", 0}, vmCommand{"pop", "local", locals + i})
+	}
+
+	jumps := false
+	for i, cmd := range f.body {
+		switch {
+		case cmd.arg1 == "argument":
+			cmd.arg1, cmd.arg2 = "local", args+cmd.arg2
+		case cmd.arg1 == "local":
+			cmd.arg2 += locals
+		case cmd.name == "label" || cmd.name == "goto" || cmd.name == "if-goto":
+			cmd.arg1 = label(cmd.arg1)
+		case cmd.name == "return":
+			if i == len(f.body)-1 {
+				continue
+			}
+			cmd = vmCommand{name: "goto", arg1: end}
+			jumps = true
+		}
+		cmds = append(cmds, cmd)
+	}
+	if jumps {
+		cmds = append(cmds, vmCommand{name: "label", arg1: end})
+	}
+	if f.this {
+		cmds = append(cmds, vmCommand{"push", "local", save}, vmCommand{"pop", "pointer", 0})
+	}
+
+	return cmds
+}
+
+// className returns the class of a function name such as Main.main.
+func className(function string) string {
+	class, _, _ := strings.Cut(function, ".")
+	return class
+}
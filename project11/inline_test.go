@@ -0,0 +1,198 @@
+package main_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"hack"
+	"hack/vmemu"
+	pkg "project11"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInline(t *testing.T) {
+	tests := []struct {
+		name    string
+		classes []string // code of the classes with the inlined functions
+		vm      string
+		want    string
+	}{
+		{
+			"1. getter in a method",
+			[]string{"function Ball.getX 0\npush argument 0\npop pointer 0\npush this 0\nreturn"},
+			"function Game.run 1\npush argument 0\npop pointer 0\npush this 1\ncall Ball.getX 1\npop local 0",
+			"function Game.run 3\n" +
+				"    push argument 0\n    pop pointer 0\n    push this 1\n" +
+				"    push pointer 0\n    pop local 2\n    pop local 1\n" +
+				"    push local 1\n    pop pointer 0\n    push this 0\n" +
+				"    push local 2\n    pop pointer 0\n" +
+				"    pop local 0",
+		},
+		{
+			"2. arguments, locals and returns",
+			[]string{"function Math.max 1\n" +
+				"push argument 0\npush argument 1\ngt\nif-goto L0\n" +
+				"push argument 1\nreturn\n" +
+				"label L0\npush argument 0\npop local 0\npush local 0\nreturn"},
+			"function Main.main 1\npush local 0\npush constant 2\ncall Math.max 2\npop local 0",
+			"function Main.main 4\n" +
+				"    push local 0\n    push constant 2\n" +
+				"    pop local 2\n    pop local 1\n" +
+				"    push constant 0\n    pop local 3\n" +
+				"    push local 1\n    push local 2\n    gt\n    if-goto Math.max.L0.0\n" +
+				"    push local 2\n    goto Math.max.0\n" +
+				"label Math.max.L0.0\n" +
+				"    push local 1\n    pop local 3\n    push local 3\n" +
+				"label Math.max.0\n" +
+				"    pop local 0",
+		},
+		{
+			"3. expansions share the locals and number their labels",
+			[]string{"function Main.abs 0\npush argument 0\npush constant 0\nlt\nif-goto L0\npush argument 0\nreturn\nlabel L0\npush argument 0\nneg\nreturn"},
+			"function Main.main 0\npush constant 1\ncall Main.abs 1\npush constant 2\ncall Main.abs 1\nadd\nreturn",
+			"function Main.main 1\n" +
+				"    push constant 1\n    pop local 0\n" +
+				"    push local 0\n    push constant 0\n    lt\n    if-goto Main.abs.L0.0\n" +
+				"    push local 0\n    goto Main.abs.0\n" +
+				"label Main.abs.L0.0\n    push local 0\n    neg\n" +
+				"label Main.abs.0\n" +
+				"    push constant 2\n    pop local 0\n" +
+				"    push local 0\n    push constant 0\n    lt\n    if-goto Main.abs.L0.1\n" +
+				"    push local 0\n    goto Main.abs.1\n" +
+				"label Main.abs.L0.1\n    push local 0\n    neg\n" +
+				"label Main.abs.1\n" +
+				"    add\n    return",
+		},
+		{
+			"4. no leaf",
+			[]string{"function Ball.draw 0\npush constant 0\ncall Screen.setColor 1\nreturn"},
+			"function Main.main 0\ncall Ball.draw 0\npop temp 0",
+			"function Main.main 0\n    call Ball.draw 0\n    pop temp 0",
+		},
+		{
+			"5. too large",
+			[]string{"function Ball.get 0\n" + strings.Repeat("push constant 1\npop temp 1\n", 6) + "push constant 0\nreturn"},
+			"function Main.main 0\ncall Ball.get 0\npop temp 0",
+			"function Main.main 0\n    call Ball.get 0\n    pop temp 0",
+		},
+		{
+			"6. statics only in their class",
+			[]string{"function Ball.count 0\npush static 0\nreturn"},
+			"function Ball.new 0\ncall Ball.count 0\npop temp 0\nfunction Main.main 0\ncall Ball.count 0\npop temp 0",
+			"function Ball.new 0\n    push static 0\n    pop temp 0\n" +
+				"function Main.main 0\n    call Ball.count 0\n    pop temp 0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := make(pkg.Inlinable)
+			for _, class := range tt.classes {
+				fs.Add(class, 12)
+			}
+			assert.Equal(t, tt.want, pkg.Inline(tt.vm, fs))
+		})
+	}
+}
+
+// TestInline_Programs runs the test programs with the compiled project 12
+// OS, with and without inlining, and compares the calls of the OS made by
+// the program. Most programs wait for the keyboard forever, so each run
+// stops after a number of commands, and the shorter sequence of calls must
+// start the longer one.
+func TestInline_Programs(t *testing.T) {
+	lib, osClasses := compileOS(t)
+
+	// run returns the calls of the OS and the machine after the program
+	// called Sys.halt or ran out of commands.
+	run := func(t *testing.T, dir string, opts pkg.BuildOptions) ([]string, *vmemu.Machine) {
+		out := t.TempDir()
+		files, err := filepath.Glob(filepath.Join(dir, "*.jack"))
+		assert.NoError(t, err)
+		names := make([]string, 0)
+		for _, file := range files {
+			src, err := os.ReadFile(file)
+			assert.NoError(t, err)
+			names = append(names, filepath.Base(file))
+			assert.NoError(t, os.WriteFile(filepath.Join(out, filepath.Base(file)), src, 0644))
+		}
+		_, err = pkg.Build(out, names, opts)
+		assert.NoError(t, err)
+
+		program := append([]vmemu.File(nil), lib...)
+		for _, name := range names {
+			class := strings.TrimSuffix(name, ".jack")
+			src, err := os.ReadFile(filepath.Join(out, class+".an"))
+			assert.NoError(t, err)
+			program = append(program, vmemu.File{Name: class, Src: src})
+		}
+		p, err := vmemu.Load(program...)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		m := vmemu.New(p)
+		var calls []string
+		for i := 0; i < 3_000_000 && !m.Halted(); i++ {
+			cmd := m.Next()
+			if cmd.Op == vmemu.Call && osClasses[className(cmd.Name)] && !osClasses[className(p.Function(m.PC))] {
+				sp := int(m.RAM[hack.SP])
+				calls = append(calls, fmt.Sprint(cmd.Name, m.RAM[sp-cmd.Index:sp]))
+			}
+			if cmd.Op == vmemu.Call && cmd.Name == "Sys.halt" {
+				break
+			}
+			if !assert.NoError(t, m.Step()) {
+				t.FailNow()
+			}
+		}
+
+		return calls, m
+	}
+
+	for _, dir := range []string{"ArrayTest", "Average", "ComplexArrays", "ConvertToBin", "Pong", "Seven", "Square"} {
+		t.Run(dir, func(t *testing.T) {
+			dir := filepath.Join("./test", dir)
+			want, m1 := run(t, dir, pkg.BuildOptions{Optimize: true})
+			got, m2 := run(t, dir, pkg.BuildOptions{Optimize: true, Inline: 10})
+			assert.NotEmpty(t, want)
+
+			n := min(len(want), len(got))
+			assert.Equal(t, want[:n], got[:n])
+			if m1.Next().Name == "Sys.halt" || m2.Next().Name == "Sys.halt" {
+				// the statics, the heap and the screen are the same
+				assert.Equal(t, want, got)
+				assert.Equal(t, m1.RAM[hack.Static:hack.Stack], m2.RAM[hack.Static:hack.Stack])
+				assert.Equal(t, m1.RAM[hack.Heap:], m2.RAM[hack.Heap:])
+			}
+		})
+	}
+}
+
+// compileOS returns the VM code of the project 12 OS, and its classes.
+func compileOS(t *testing.T) ([]vmemu.File, map[string]bool) {
+	t.Helper()
+	os12, err := filepath.Glob("../project12/*.jack")
+	assert.NoError(t, err)
+	var lib []vmemu.File
+	classes := make(map[string]bool)
+	for _, file := range os12 {
+		src, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		out, err := pkg.Compile(src, true)
+		assert.NoError(t, err)
+		class := strings.TrimSuffix(filepath.Base(file), ".jack")
+		lib = append(lib, vmemu.File{Name: class, Src: []byte(out)})
+		classes[class] = true
+	}
+
+	return lib, classes
+}
+
+func className(function string) string {
+	class, _, _ := strings.Cut(function, ".")
+	return class
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"jack"
+)
+
+// Checks reported by Lint.
+const (
+	CheckUnused           = "unused"
+	CheckUnreachable      = "unreachable"
+	CheckMissingReturn    = "missing-return"
+	CheckUnusedSubroutine = "unused-subroutine"
+	CheckRecursion        = "infinite-recursion"
+)
+
+// ignoreDirective in a comment suppresses the diagnostics of its line.
+// It can be followed by the checks to suppress, all checks otherwise:
+//
+//	let x = 1; // jacklint:ignore unused
+const ignoreDirective = "jacklint:ignore"
+
+// Diagnostic is a problem found by Lint.
+type Diagnostic struct {
+	File  string
+	Pos   jack.Pos
+	Check string
+	Msg   string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%v:%v: %v (%v)", d.File, d.Pos, d.Msg, d.Check)
+}
+
+// entry is a declared variable or subroutine.
+type entry struct {
+	ident *jack.Ident
+	kind  string // static, field, argument, local or the subroutine kind
+	vType string
+	used  bool
+}
+
+// linter holds the state of Lint over a whole program.
+type linter struct {
+	diagnostics []Diagnostic
+	subroutines map[string]*entry // by Class.name
+	subFiles    map[string]string // file of each subroutine
+	calls       map[string]map[string]bool
+	file        string
+
+	// per class and per subroutine scopes
+	class     string
+	classVars map[string]*entry
+	locals    map[string]*entry
+}
+
+// Lint checks a program, given as the parsed classes by file name, for
+//   - unused local variables, arguments, fields and statics
+//   - statements that follow a return
+//   - subroutines that do not end with a return
+//   - subroutines that are never called
+//   - subroutines that call themselves, directly or not, on every path,
+//     so the recursion never ends
+//
+// Main.main and Sys.init are the entry points of a program and are never
+// reported as unused.
+func Lint(files map[string]*jack.ClassDecl) []Diagnostic {
+	l := &linter{
+		subroutines: make(map[string]*entry),
+		subFiles:    make(map[string]string),
+		calls:       make(map[string]map[string]bool),
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		class := files[name]
+		for _, sub := range class.Subroutines {
+			fName := class.Name.Name + "." + sub.Name.Name
+			l.subroutines[fName] = &entry{ident: sub.Name, kind: sub.Kind}
+			l.subFiles[fName] = name
+		}
+	}
+
+	for _, name := range names {
+		l.file = name
+		l.lintClass(files[name])
+	}
+
+	l.checkRecursion()
+
+	for fName, sub := range l.subroutines {
+		if sub.used || fName == "Main.main" || fName == "Sys.init" {
+			continue
+		}
+		l.file = l.subFiles[fName]
+		l.report(sub.ident.Pos(), CheckUnusedSubroutine,
+			fmt.Sprintf("%v %v is never called", sub.kind, fName))
+	}
+
+	diagnostics := make([]Diagnostic, 0, len(l.diagnostics))
+	for _, d := range l.diagnostics {
+		if !ignored(files[d.File], d) {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	sort.Slice(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Pos.Line != b.Pos.Line {
+			return a.Pos.Line < b.Pos.Line
+		}
+		return a.Pos.Column < b.Pos.Column
+	})
+
+	return diagnostics
+}
+
+// ignored reports whether d is suppressed by a comment on its line.
+func ignored(class *jack.ClassDecl, d Diagnostic) bool {
+	for _, comment := range class.Comments {
+		if comment.Slash.Line != d.Pos.Line {
+			continue
+		}
+		i := strings.Index(comment.Text, ignoreDirective)
+		if i < 0 {
+			continue
+		}
+
+		text := strings.TrimSuffix(comment.Text[i+len(ignoreDirective):], "*/")
+		checks := strings.FieldsFunc(text, func(r rune) bool {
+			return r == ' ' || r == ','
+		})
+		if len(checks) == 0 {
+			return true
+		}
+		for _, check := range checks {
+			if check == d.Check {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (l *linter) report(pos jack.Pos, check, msg string) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		File:  l.file,
+		Pos:   pos,
+		Check: check,
+		Msg:   msg,
+	})
+}
+
+func (l *linter) lintClass(class *jack.ClassDecl) {
+	l.class = class.Name.Name
+	l.classVars = make(map[string]*entry)
+	declared := make([]*entry, 0)
+	for _, dec := range class.Vars {
+		for _, name := range dec.Names {
+			e := &entry{ident: name, kind: dec.Kind, vType: dec.Type.Name}
+			l.classVars[name.Name] = e
+			declared = append(declared, e)
+		}
+	}
+
+	for _, sub := range class.Subroutines {
+		l.lintSubroutine(sub)
+	}
+
+	l.reportUnused(declared)
+}
+
+func (l *linter) lintSubroutine(sub *jack.SubroutineDecl) {
+	l.locals = make(map[string]*entry)
+	declared := make([]*entry, 0)
+	for _, param := range sub.Params {
+		e := &entry{ident: param.Name, kind: "argument", vType: param.Type.Name}
+		l.locals[param.Name.Name] = e
+		declared = append(declared, e)
+	}
+	for _, dec := range sub.Vars {
+		for _, name := range dec.Names {
+			e := &entry{ident: name, kind: "local", vType: dec.Type.Name}
+			l.locals[name.Name] = e
+			declared = append(declared, e)
+		}
+	}
+
+	for _, stmt := range sub.Body {
+		jack.Inspect(stmt, l.visit)
+	}
+	l.calls[l.class+"."+sub.Name.Name] = l.mustCalls(sub.Body, nil)
+
+	if returns, _ := l.checkBlock(sub.Body); !returns {
+		l.report(sub.Rbrace, CheckMissingReturn,
+			fmt.Sprintf("missing return at end of %v %v.%v", sub.Kind, l.class, sub.Name.Name))
+	}
+
+	l.reportUnused(declared)
+}
+
+func (l *linter) reportUnused(declared []*entry) {
+	for _, e := range declared {
+		if !e.used {
+			l.report(e.ident.Pos(), CheckUnused,
+				fmt.Sprintf("%v %v declared and not used", e.kind, e.ident.Name))
+		}
+	}
+}
+
+// lookup resolves a variable name, the subroutine scope shadows the class
+// scope.
+func (l *linter) lookup(name string) *entry {
+	if e, ok := l.locals[name]; ok {
+		return e
+	}
+
+	return l.classVars[name]
+}
+
+// visit marks the variables and subroutines that node uses.
+func (l *linter) visit(node jack.Node) bool {
+	switch n := node.(type) {
+	case *jack.LetStmt:
+		// assigning a variable is not a use, indexing an array or a
+		// compound assignment, which reads the variable, is
+		if n.Index != nil || n.Op != "" {
+			l.use(n.Name)
+		}
+		inspectExprs(l.visit, n.Index, n.Value)
+		return false
+	case *jack.CallExpr:
+		if n.Receiver != nil {
+			l.use(n.Receiver)
+		}
+		if sub, ok := l.subroutines[l.callee(n)]; ok {
+			sub.used = true
+		}
+		inspectExprs(l.visit, n.Args...)
+		return false
+	case *jack.Ident:
+		l.use(n)
+	}
+
+	return true
+}
+
+// callee returns the name of the subroutine that call calls, as Class.name.
+func (l *linter) callee(call *jack.CallExpr) string {
+	if call.Receiver == nil {
+		return l.class + "." + call.Name.Name
+	}
+	if e := l.lookup(call.Receiver.Name); e != nil {
+		return e.vType + "." + call.Name.Name
+	}
+
+	return call.Receiver.Name + "." + call.Name.Name
+}
+
+func (l *linter) use(ident *jack.Ident) *entry {
+	e := l.lookup(ident.Name)
+	if e != nil {
+		e.used = true
+	}
+
+	return e
+}
+
+// checkBlock reports statements that follow a return, a break or a
+// continue. It returns whether the block always ends with a return, and
+// whether it always ends with one of them.
+func (l *linter) checkBlock(stmts []jack.Stmt) (returns, ends bool) {
+	for _, stmt := range stmts {
+		if ends {
+			l.report(stmt.Pos(), CheckUnreachable, "unreachable code")
+			return returns, true
+		}
+
+		switch s := stmt.(type) {
+		case *jack.ReturnStmt:
+			returns, ends = true, true
+		case *jack.BranchStmt:
+			ends = true
+		case *jack.IfStmt:
+			thenReturns, thenEnds := l.checkBlock(s.Then)
+			altReturns, altEnds := l.checkBlock(s.Alt)
+			returns = thenReturns && altReturns && s.Else.IsValid()
+			ends = thenEnds && altEnds && s.Else.IsValid()
+		case *jack.WhileStmt:
+			l.checkBlock(s.Body)
+		case *jack.ForStmt:
+			l.checkBlock(s.Body)
+		}
+	}
+
+	return returns, ends
+}
+
+// mustCalls returns the subroutines that are called on every path from
+// stmts to the end of the subroutine, given those called on every path
+// after stmts. Loop bodies may not run, so only their conditions count.
+func (l *linter) mustCalls(stmts []jack.Stmt, after map[string]bool) map[string]bool {
+	calls := make(map[string]bool)
+	for name := range after {
+		calls[name] = true
+	}
+	add := func(exprs ...jack.Expr) {
+		inspectExprs(func(node jack.Node) bool {
+			if call, ok := node.(*jack.CallExpr); ok {
+				calls[l.callee(call)] = true
+			}
+			return true
+		}, exprs...)
+	}
+
+	for i := len(stmts) - 1; i >= 0; i-- {
+		switch s := stmts[i].(type) {
+		case *jack.LetStmt:
+			add(s.Index, s.Value)
+		case *jack.DoStmt:
+			add(s.Call)
+		case *jack.ReturnStmt:
+			clear(calls)
+			add(s.Value)
+		case *jack.IfStmt:
+			then, alt := l.mustCalls(s.Then, calls), l.mustCalls(s.Alt, calls)
+			clear(calls)
+			for name := range then {
+				if alt[name] {
+					calls[name] = true
+				}
+			}
+			add(s.Cond)
+		case *jack.WhileStmt:
+			if returns(s.Body) {
+				clear(calls)
+			}
+			add(s.Cond)
+		case *jack.ForStmt:
+			if returns(s.Body) {
+				clear(calls)
+			}
+			add(s.Cond)
+			if s.Init != nil {
+				add(s.Init.Index, s.Init.Value)
+			}
+		}
+	}
+
+	return calls
+}
+
+// returns reports whether stmts contain a return statement.
+func returns(stmts []jack.Stmt) bool {
+	found := false
+	inspectStmts(func(node jack.Node) bool {
+		if _, ok := node.(*jack.ReturnStmt); ok {
+			found = true
+		}
+		return !found
+	}, stmts)
+
+	return found
+}
+
+// checkRecursion reports the subroutines that call themselves on every
+// path, through the subroutines that they call on every path.
+func (l *linter) checkRecursion() {
+	names := make([]string, 0, len(l.calls))
+	for name := range l.calls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		// breadth first, so the shortest cycle is reported
+		from := map[string]string{}
+		queue := []string{name}
+		for len(queue) > 0 && from[name] == "" {
+			caller := queue[0]
+			queue = queue[1:]
+			callees := make([]string, 0, len(l.calls[caller]))
+			for callee := range l.calls[caller] {
+				callees = append(callees, callee)
+			}
+			sort.Strings(callees)
+			for _, callee := range callees {
+				if _, ok := from[callee]; !ok {
+					from[callee] = caller
+					queue = append(queue, callee)
+				}
+			}
+		}
+		if from[name] == "" {
+			continue
+		}
+
+		var through []string
+		for caller := from[name]; caller != name; caller = from[caller] {
+			through = append([]string{caller}, through...)
+		}
+		sub := l.subroutines[name]
+		msg := fmt.Sprintf("%v %v calls itself on every path", sub.kind, name)
+		if len(through) > 0 {
+			msg = fmt.Sprintf("%v %v calls itself through %v on every path", sub.kind, name, strings.Join(through, ", "))
+		}
+		l.file = l.subFiles[name]
+		l.report(sub.ident.Pos(), CheckRecursion, msg)
+	}
+}
+
+func inspectStmts(f func(jack.Node) bool, stmts []jack.Stmt) {
+	for _, stmt := range stmts {
+		jack.Inspect(stmt, f)
+	}
+}
+
+func inspectExprs(f func(jack.Node) bool, exprs ...jack.Expr) {
+	for _, expr := range exprs {
+		if expr != nil {
+			jack.Inspect(expr, f)
+		}
+	}
+}
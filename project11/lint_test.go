@@ -0,0 +1,256 @@
+package main_test
+
+import (
+	"testing"
+
+	"jack"
+	pkg "project11"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  jack.Mode
+		files map[string]string
+		wants []string
+	}{
+		{
+			name: "1. unused variables",
+			files: map[string]string{
+				"Main.jack": `class Main {
+    static int count;
+    field int size;
+    function void main() {
+        var int x, y;
+        let x = size;
+        do Main.run(x);
+        return;
+    }
+    function void run(int a, int b) {
+        return;
+    }
+}`,
+			},
+			wants: []string{
+				"Main.jack:2:16: static count declared and not used (unused)",
+				"Main.jack:5:20: local y declared and not used (unused)",
+				"Main.jack:10:27: argument a declared and not used (unused)",
+				"Main.jack:10:34: argument b declared and not used (unused)",
+			},
+		},
+		{
+			name: "2. unreachable code and missing return",
+			files: map[string]string{
+				"Main.jack": `class Main {
+    function void main() {
+        do Main.sign(1);
+        return;
+        do Main.sign(2);
+    }
+    function int sign(int x) {
+        if (x < 0) {
+            return -1;
+        } else {
+            return 1;
+        }
+        return 0;
+    }
+    function int loop(int x) {
+        while (x > 0) {
+            let x = x - 1;
+        }
+    }
+}`,
+			},
+			wants: []string{
+				"Main.jack:5:9: unreachable code (unreachable)",
+				"Main.jack:13:9: unreachable code (unreachable)",
+				"Main.jack:15:18: function Main.loop is never called (unused-subroutine)",
+				"Main.jack:19:5: missing return at end of function Main.loop (missing-return)",
+			},
+		},
+		{
+			name: "3. unused subroutines across classes",
+			files: map[string]string{
+				"Main.jack": `class Main {
+    function void main() {
+        var Ball ball;
+        let ball = Ball.new();
+        do ball.move();
+        return;
+    }
+}`,
+				"Ball.jack": `class Ball {
+    constructor Ball new() {
+        return this;
+    }
+    method void move() {
+        do draw();
+        return;
+    }
+    method void draw() {
+        return;
+    }
+    method void erase() {
+        return;
+    }
+}`,
+			},
+			wants: []string{
+				"Ball.jack:12:17: method Ball.erase is never called (unused-subroutine)",
+			},
+		},
+		{
+			name: "4. suppression comments",
+			files: map[string]string{
+				"Main.jack": `class Main {
+    field int size; // jacklint:ignore
+    function void main() {
+        var int x; /* jacklint:ignore unused */
+        var int y; // jacklint:ignore unreachable
+        return;
+    }
+}`,
+			},
+			wants: []string{
+				"Main.jack:5:17: local y declared and not used (unused)",
+			},
+		},
+		{
+			name: "5. infinite recursion",
+			files: map[string]string{
+				"Main.jack": `class Main {
+    function void main() {
+        do Main.count(10);
+        do Main.ping(1);
+        do Main.loop(1);
+        return;
+    }
+    function int count(int n) {
+        if (n = 0) {
+            return 0;
+        }
+        return Main.count(n - 1);
+    }
+    function int loop(int n) {
+        if (n = 0) {
+            let n = 1;
+        }
+        return Main.loop(n - 1);
+    }
+    function int ping(int n) {
+        while (n > 0) {
+            let n = n - 1;
+        }
+        return Ball.pong(n);
+    }
+}`,
+				"Ball.jack": `class Ball {
+    function int pong(int n) {
+        if (n > 0) {
+            do Output.printInt(n);
+            return Main.ping(n) + 1;
+        } else {
+            return Ball.pong(n) - Main.ping(n);
+        }
+    }
+}`,
+			},
+			wants: []string{
+				"Ball.jack:2:18: function Ball.pong calls itself through Main.ping on every path (infinite-recursion)",
+				"Main.jack:14:18: function Main.loop calls itself on every path (infinite-recursion)",
+				"Main.jack:20:18: function Main.ping calls itself through Ball.pong on every path (infinite-recursion)",
+			},
+		},
+		{
+			name: "6. unreachable code in for loops",
+			mode: jack.Extended,
+			files: map[string]string{
+				"Main.jack": `class Main {
+    function void main() {
+        var int i;
+        for (i = 0; i < 10; i = i + 1) {
+            return;
+            do Main.main();
+        }
+        return;
+    }
+}`,
+			},
+			wants: []string{
+				"Main.jack:6:13: unreachable code (unreachable)",
+			},
+		},
+		{
+			name: "7. unreachable code after break and continue",
+			mode: jack.Extended,
+			files: map[string]string{
+				"Main.jack": `class Main {
+    function int main() {
+        var int i;
+        while (i < 10) {
+            let i = i + 1;
+            continue;
+            let i = 0;
+        }
+        for (;;) {
+            if (i > 0) {
+                break;
+            } else {
+                continue;
+            }
+            let i = 1;
+        }
+        for (;;) {
+            break;
+        }
+        return i;
+    }
+}`,
+			},
+			wants: []string{
+				"Main.jack:7:13: unreachable code (unreachable)",
+				"Main.jack:15:13: unreachable code (unreachable)",
+			},
+		},
+		{
+			name: "8. compound assignments",
+			mode: jack.Extended,
+			files: map[string]string{
+				"Main.jack": `class Main {
+    static int count;
+    function void main() {
+        var int x, y;
+        let count += 1;
+        let x = 2;
+        let x *= 3;
+        let y = 4;
+        return;
+    }
+}`,
+			},
+			wants: []string{
+				"Main.jack:4:20: local y declared and not used (unused)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			classes := make(map[string]*jack.ClassDecl)
+			for name, src := range tt.files {
+				class, errs := jack.ParseMode([]byte(src), tt.mode)
+				assert.NoError(t, errs.Err())
+				classes[name] = class
+			}
+
+			gots := make([]string, 0)
+			for _, d := range pkg.Lint(classes) {
+				gots = append(gots, d.String())
+			}
+			assert.Equal(t, tt.wants, gots)
+		})
+	}
+}
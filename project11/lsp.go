@@ -0,0 +1,971 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"jack"
+)
+
+// JSON-RPC error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInternalError  = -32603
+)
+
+// LSP enum values used by the server.
+const (
+	lspSeverityError   = 1
+	lspSeverityWarning = 2
+
+	lspCompletionMethod      = 2
+	lspCompletionFunction    = 3
+	lspCompletionConstructor = 4
+	lspCompletionField       = 5
+	lspCompletionVariable    = 6
+	lspCompletionClass       = 7
+
+	lspSymbolClass       = 5
+	lspSymbolMethod      = 6
+	lspSymbolField       = 8
+	lspSymbolConstructor = 9
+	lspSymbolFunction    = 12
+	lspSymbolVariable    = 13
+)
+
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result"`
+}
+
+type rpcErrorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   rpcError        `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+// LSP positions are 0-based, Pos is 1-based. LSP columns are counted in
+// UTF-16 code units, Pos columns in bytes: lspFile converts them.
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code,omitempty"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspHover struct {
+	Contents struct {
+		Kind  string `json:"kind"`
+		Value string `json:"value"`
+	} `json:"contents"`
+	Range lspRange `json:"range"`
+}
+
+type lspCompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type lspDocumentSymbol struct {
+	Name           string              `json:"name"`
+	Detail         string              `json:"detail,omitempty"`
+	Kind           int                 `json:"kind"`
+	Range          lspRange            `json:"range"`
+	SelectionRange lspRange            `json:"selectionRange"`
+	Children       []lspDocumentSymbol `json:"children,omitempty"`
+}
+
+type lspTextDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type lspPositionParams struct {
+	TextDocument lspTextDocument `json:"textDocument"`
+	Position     lspPosition     `json:"position"`
+}
+
+// lspFile is a .jack file of the workspace. The files of a directory are
+// one program.
+type lspFile struct {
+	uri   string
+	dir   string
+	src   []byte
+	lines []string
+	class *jack.ClassDecl
+	errs  jack.ErrorList
+	open  bool // opened by the client, which shows its diagnostics
+}
+
+// lspServer is a language server for Jack speaking JSON-RPC over a stream.
+type lspServer struct {
+	in       *bufio.Reader
+	out      io.Writer
+	files    map[string]*lspFile        // by URI
+	os       map[string]*jack.ClassDecl // the OS classes, by name
+	shutdown bool
+}
+
+// ServeLSP runs a language server reading requests from in and writing
+// responses and diagnostics to out, until the exit notification or the end
+// of in. The server provides diagnostics, definitions, hovers, completions
+// and document symbols, it keeps working on files with syntax errors.
+func ServeLSP(in io.Reader, out io.Writer) error {
+	s := &lspServer{
+		in:    bufio.NewReader(in),
+		out:   out,
+		files: make(map[string]*lspFile),
+		os:    osClasses(),
+	}
+
+	for {
+		body, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg rpcMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			// the id is unknown, and the next message may be fine
+			err := s.write(rpcErrorResponse{
+				JSONRPC: "2.0",
+				ID:      json.RawMessage("null"),
+				Error:   rpcError{rpcParseError, err.Error()},
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// read returns the body of the next message.
+func (s *lspServer) read() ([]byte, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("missing Content-Length header")
+	}
+
+	body := make([]byte, length)
+	_, err := io.ReadFull(s.in, body)
+
+	return body, err
+}
+
+func (s *lspServer) write(msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+
+	return err
+}
+
+func (s *lspServer) notify(method string, params interface{}) error {
+	return s.write(rpcNotification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// handle dispatches a message. A request gets a response even if its
+// handler panics on unexpected input.
+func (s *lspServer) handle(msg rpcMessage) (err error) {
+	reply := func(result interface{}, rpcErr *rpcError) error {
+		if msg.ID == nil {
+			return nil
+		}
+		if rpcErr != nil {
+			return s.write(rpcErrorResponse{JSONRPC: "2.0", ID: msg.ID, Error: *rpcErr})
+		}
+		return s.write(rpcResponse{JSONRPC: "2.0", ID: msg.ID, Result: result})
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = reply(nil, &rpcError{rpcInternalError, fmt.Sprint(r)})
+		}
+	}()
+
+	if s.shutdown && msg.Method != "shutdown" {
+		return reply(nil, &rpcError{rpcInvalidRequest, "server is shut down"})
+	}
+
+	var params lspPositionParams
+	if msg.Params != nil {
+		// every handled method but initialize and didChange has (a subset
+		// of) these params
+		_ = json.Unmarshal(msg.Params, &params)
+	}
+	uri := params.TextDocument.URI
+
+	switch msg.Method {
+	case "initialize":
+		return reply(s.initialize(msg.Params), nil)
+	case "shutdown":
+		s.shutdown = true
+		return reply(nil, nil)
+	case "textDocument/didOpen":
+		return s.update(uri, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var change struct {
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(msg.Params, &change); err != nil || len(change.ContentChanges) == 0 {
+			return nil
+		}
+		return s.update(uri, change.ContentChanges[len(change.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		return s.close(uri)
+	case "textDocument/definition":
+		return reply(s.definition(uri, params.Position), nil)
+	case "textDocument/hover":
+		return reply(s.hover(uri, params.Position), nil)
+	case "textDocument/completion":
+		return reply(s.completion(uri, params.Position), nil)
+	case "textDocument/documentSymbol":
+		return reply(s.documentSymbols(uri), nil)
+	}
+
+	if strings.HasPrefix(msg.Method, "$/") {
+		return nil
+	}
+	return reply(nil, &rpcError{rpcMethodNotFound, "method not found: " + msg.Method})
+}
+
+// initialize indexes the .jack files of the workspace folders.
+func (s *lspServer) initialize(raw json.RawMessage) interface{} {
+	var params struct {
+		RootURI          string `json:"rootUri"`
+		WorkspaceFolders []struct {
+			URI string `json:"uri"`
+		} `json:"workspaceFolders"`
+	}
+	_ = json.Unmarshal(raw, &params)
+
+	roots := []string{params.RootURI}
+	for _, folder := range params.WorkspaceFolders {
+		roots = append(roots, folder.URI)
+	}
+	for _, root := range roots {
+		dir := uriToPath(root)
+		if dir == "" {
+			continue
+		}
+		_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() && path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() && strings.HasSuffix(path, ".jack") {
+				s.load(pathToURI(path))
+			}
+			return nil
+		})
+	}
+
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync":       1, // full
+			"definitionProvider":     true,
+			"hoverProvider":          true,
+			"documentSymbolProvider": true,
+			"completionProvider": map[string]interface{}{
+				"triggerCharacters": []string{"."},
+			},
+		},
+		"serverInfo": map[string]string{"name": "jack-lsp"},
+	}
+}
+
+// load reads a file of the workspace from disk.
+func (s *lspServer) load(uri string) bool {
+	src, err := os.ReadFile(uriToPath(uri))
+	if err != nil {
+		return false
+	}
+	s.parse(uri, src)
+
+	return true
+}
+
+func (s *lspServer) parse(uri string, src []byte) *lspFile {
+	class, errs := jack.Parse(src)
+	f := &lspFile{
+		uri:   uri,
+		dir:   uri[:strings.LastIndex(uri, "/")+1],
+		src:   src,
+		lines: strings.Split(string(src), "\n"),
+		class: class,
+		errs:  errs,
+	}
+	s.files[uri] = f
+
+	return f
+}
+
+// update sets the content of an open file and publishes its diagnostics.
+func (s *lspServer) update(uri, text string) error {
+	if uri == "" {
+		return nil
+	}
+	f := s.parse(uri, []byte(text))
+	f.open = true
+
+	if err := s.publish(f); err != nil {
+		return err
+	}
+	return s.publishProgram(f)
+}
+
+// close gives the file back to the disk and clears its diagnostics.
+func (s *lspServer) close(uri string) error {
+	f, ok := s.files[uri]
+	if !s.load(uri) {
+		delete(s.files, uri)
+	}
+
+	err := s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         uri,
+		"diagnostics": []lspDiagnostic{},
+	})
+	if err != nil || !ok {
+		return err
+	}
+	return s.publishProgram(f)
+}
+
+func (s *lspServer) publish(f *lspFile) error {
+	return s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         f.uri,
+		"diagnostics": s.diagnostics(f),
+	})
+}
+
+// publishProgram publishes the diagnostics of the other open files of the
+// program of f, which Lint checks with f.
+func (s *lspServer) publishProgram(f *lspFile) error {
+	uris := make([]string, 0)
+	for uri, other := range s.files {
+		if other.open && other.dir == f.dir && uri != f.uri {
+			uris = append(uris, uri)
+		}
+	}
+	sort.Strings(uris)
+	for _, uri := range uris {
+		if err := s.publish(s.files[uri]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// diagnostics returns the syntax errors of f, or the problems found by
+// Lint in the program of f if there is none.
+func (s *lspServer) diagnostics(f *lspFile) []lspDiagnostic {
+	diagnostics := make([]lspDiagnostic, 0)
+	for _, e := range f.errs {
+		diagnostics = append(diagnostics, lspDiagnostic{
+			Range:    lspRange{f.toLSP(e.Pos), f.toLSP(e.Pos)},
+			Severity: lspSeverityError,
+			Source:   "jack",
+			Message:  e.Msg,
+		})
+	}
+	if len(diagnostics) > 0 {
+		return diagnostics
+	}
+
+	program := make(map[string]*jack.ClassDecl)
+	for uri, other := range s.files {
+		if other.dir == f.dir && len(other.errs) == 0 {
+			program[uri] = other.class
+		}
+	}
+	for _, d := range Lint(program) {
+		if d.File != f.uri {
+			continue
+		}
+		diagnostics = append(diagnostics, lspDiagnostic{
+			Range:    lspRange{f.toLSP(d.Pos), f.toLSP(d.Pos)},
+			Severity: lspSeverityWarning,
+			Code:     d.Check,
+			Source:   "jacklint",
+			Message:  d.Msg,
+		})
+	}
+
+	return diagnostics
+}
+
+// lspSymbol is the declaration of a class, a subroutine or a variable.
+type lspSymbol struct {
+	kind  string // class, the subroutine kind or the variable kind
+	name  string
+	vType string          // variable type or subroutine return type
+	file  *lspFile        // nil for the OS classes
+	ident *jack.Ident     // declared name
+	class *jack.ClassDecl // declaring class
+	sub   *jack.SubroutineDecl
+	table *jack.SymbolTable // table of a variable
+}
+
+// lspRef is an identifier and what it refers to.
+type lspRef struct {
+	ident *jack.Ident
+	sym   *lspSymbol
+}
+
+// lspScope resolves the variable names of a class and of one of its
+// subroutines. The subroutine scope shadows the class scope.
+type lspScope struct {
+	classSB   *jack.SymbolTable
+	routineSB *jack.SymbolTable
+	classVars map[string]*lspSymbol
+	locals    map[string]*lspSymbol
+}
+
+func newScope(f *lspFile) *lspScope {
+	sc := &lspScope{
+		classSB:   jack.NewSymbolTable(),
+		classVars: make(map[string]*lspSymbol),
+		locals:    make(map[string]*lspSymbol),
+	}
+	for _, dec := range f.class.Vars {
+		for _, name := range dec.Names {
+			sc.classSB.Define(dec.Type.Name, name.Name, jack.WhichKind(dec.Kind))
+			if _, ok := sc.classVars[name.Name]; !ok {
+				sc.classVars[name.Name] = &lspSymbol{
+					kind: dec.Kind, name: name.Name, vType: dec.Type.Name,
+					file: f, ident: name, class: f.class, table: sc.classSB,
+				}
+			}
+		}
+	}
+
+	return sc
+}
+
+// enter starts the scope of sub, nil leaves the subroutine scope empty.
+func (sc *lspScope) enter(f *lspFile, sub *jack.SubroutineDecl) {
+	sc.routineSB = jack.NewSymbolTable()
+	sc.locals = make(map[string]*lspSymbol)
+	if sub == nil {
+		return
+	}
+
+	define := func(vType string, name *jack.Ident, kind jack.VariableKind) {
+		sc.routineSB.Define(vType, name.Name, kind)
+		if _, ok := sc.locals[name.Name]; !ok {
+			sc.locals[name.Name] = &lspSymbol{
+				kind: kind.String(), name: name.Name, vType: vType,
+				file: f, ident: name, class: f.class, sub: sub, table: sc.routineSB,
+			}
+		}
+	}
+	if sub.Kind == "method" {
+		sc.routineSB.Define(f.class.Name.Name, "this", jack.Arg)
+	}
+	for _, param := range sub.Params {
+		define(param.Type.Name, param.Name, jack.Arg)
+	}
+	for _, dec := range sub.Vars {
+		for _, name := range dec.Names {
+			define(dec.Type.Name, name, jack.Var)
+		}
+	}
+}
+
+func (sc *lspScope) lookup(name string) *lspSymbol {
+	if sym, ok := sc.locals[name]; ok {
+		return sym
+	}
+
+	return sc.classVars[name]
+}
+
+// findClass returns a class by name, looking first in the program of dir,
+// then in the rest of the workspace and last in the OS.
+func (s *lspServer) findClass(dir, name string) *lspSymbol {
+	var found *lspFile
+	for _, f := range s.files {
+		if f.class.Name.Name != name {
+			continue
+		}
+		if found == nil || f.dir == dir && found.dir != dir ||
+			(f.dir == dir) == (found.dir == dir) && f.uri < found.uri {
+			found = f
+		}
+	}
+	if found != nil {
+		return &lspSymbol{kind: "class", name: name, file: found, ident: found.class.Name, class: found.class}
+	}
+	if class, ok := s.os[name]; ok {
+		return &lspSymbol{kind: "class", name: name, ident: class.Name, class: class}
+	}
+
+	return nil
+}
+
+// findSubroutine returns a subroutine of a class by name.
+func (s *lspServer) findSubroutine(dir, className, name string) *lspSymbol {
+	class := s.findClass(dir, className)
+	if class == nil {
+		return nil
+	}
+	for _, sub := range class.class.Subroutines {
+		if sub.Name.Name == name {
+			return &lspSymbol{
+				kind: sub.Kind, name: name, vType: sub.ReturnType.Name,
+				file: class.file, ident: sub.Name, class: class.class, sub: sub,
+			}
+		}
+	}
+
+	return nil
+}
+
+// refs returns the resolved identifiers of f.
+func (s *lspServer) refs(f *lspFile) []lspRef {
+	refs := make([]lspRef, 0)
+	add := func(ident *jack.Ident, sym *lspSymbol) {
+		if ident != nil && sym != nil {
+			refs = append(refs, lspRef{ident, sym})
+		}
+	}
+	className := f.class.Name.Name
+	typeRef := func(ident *jack.Ident) {
+		if ident != nil {
+			add(ident, s.findClass(f.dir, ident.Name))
+		}
+	}
+
+	sc := newScope(f)
+	add(f.class.Name, s.findClass(f.dir, className))
+	for _, dec := range f.class.Vars {
+		typeRef(dec.Type)
+		for _, name := range dec.Names {
+			add(name, sc.lookup(name.Name))
+		}
+	}
+
+	for _, sub := range f.class.Subroutines {
+		sc.enter(f, sub)
+		typeRef(sub.ReturnType)
+		add(sub.Name, s.findSubroutine(f.dir, className, sub.Name.Name))
+		for _, param := range sub.Params {
+			typeRef(param.Type)
+			add(param.Name, sc.lookup(param.Name.Name))
+		}
+		for _, dec := range sub.Vars {
+			typeRef(dec.Type)
+			for _, name := range dec.Names {
+				add(name, sc.lookup(name.Name))
+			}
+		}
+
+		var visit func(jack.Node) bool
+		visit = func(node jack.Node) bool {
+			switch n := node.(type) {
+			case *jack.CallExpr:
+				receiver := className
+				if n.Receiver != nil {
+					receiver = n.Receiver.Name
+					if v := sc.lookup(n.Receiver.Name); v != nil {
+						add(n.Receiver, v)
+						receiver = v.vType
+					} else {
+						typeRef(n.Receiver)
+					}
+				}
+				add(n.Name, s.findSubroutine(f.dir, receiver, n.Name.Name))
+				inspectExprs(visit, n.Args...)
+				return false
+			case *jack.Ident:
+				add(n, sc.lookup(n.Name))
+			}
+			return true
+		}
+		inspectStmts(visit, sub.Body)
+	}
+
+	return refs
+}
+
+// refAt returns the resolved identifier at pos in the file uri.
+func (s *lspServer) refAt(uri string, pos lspPosition) (lspRef, bool) {
+	f, ok := s.files[uri]
+	if !ok {
+		return lspRef{}, false
+	}
+
+	p := f.fromLSP(pos)
+	for _, ref := range s.refs(f) {
+		start := ref.ident.Pos()
+		if start.Line == p.Line && start.Column <= p.Column &&
+			p.Column <= start.Column+len(ref.ident.Name) {
+			return ref, true
+		}
+	}
+
+	return lspRef{}, false
+}
+
+func (s *lspServer) definition(uri string, pos lspPosition) interface{} {
+	ref, ok := s.refAt(uri, pos)
+	if !ok || ref.sym.file == nil {
+		return nil
+	}
+
+	return lspLocation{URI: ref.sym.file.uri, Range: ref.sym.file.identRange(ref.sym.ident)}
+}
+
+// hover shows variables as a row of SymbolTable.Print and subroutines by
+// their signature.
+func (s *lspServer) hover(uri string, pos lspPosition) interface{} {
+	ref, ok := s.refAt(uri, pos)
+	if !ok {
+		return nil
+	}
+
+	var text strings.Builder
+	sym := ref.sym
+	switch {
+	case sym.table != nil:
+		sym.table.Fprint(&text, sym.name)
+	case sym.sub != nil:
+		text.WriteString(signature(sym.class, sym.sub))
+	default:
+		text.WriteString("class " + sym.name)
+	}
+
+	var h lspHover
+	h.Contents.Kind = "markdown"
+	h.Contents.Value = "```\n" + strings.TrimSuffix(text.String(), "\n") + "\n```"
+	h.Range = s.files[uri].identRange(ref.ident)
+
+	return h
+}
+
+// signature returns the declaration line of sub.
+func signature(class *jack.ClassDecl, sub *jack.SubroutineDecl) string {
+	params := make([]string, 0, len(sub.Params))
+	for _, param := range sub.Params {
+		params = append(params, param.Type.Name+" "+param.Name.Name)
+	}
+
+	return fmt.Sprintf("%v %v %v.%v(%v)", sub.Kind, sub.ReturnType.Name,
+		class.Name.Name, sub.Name.Name, strings.Join(params, ", "))
+}
+
+// memberAccess matches a receiver and a dot before the cursor.
+var memberAccess = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)\s*\.\s*[A-Za-z0-9_]*$`)
+
+// completion lists the subroutines that can be called on 'name.' and the
+// names in scope otherwise. It only looks at the text before the cursor
+// and at the declarations, so the statement being typed may be incomplete.
+func (s *lspServer) completion(uri string, pos lspPosition) interface{} {
+	items := make([]lspCompletionItem, 0)
+	f, ok := s.files[uri]
+	if !ok {
+		return items
+	}
+
+	sc := newScope(f)
+	p := f.fromLSP(pos)
+	var current *jack.SubroutineDecl
+	for _, sub := range f.class.Subroutines {
+		if !before(p, sub.Pos()) {
+			current = sub
+		}
+	}
+	sc.enter(f, current)
+
+	if m := memberAccess.FindStringSubmatch(f.linePrefix(pos)); m != nil {
+		receiver, methods := m[1], false
+		if v := sc.lookup(receiver); v != nil {
+			receiver, methods = v.vType, true
+		}
+		class := s.findClass(f.dir, receiver)
+		if class == nil {
+			return items
+		}
+		for _, sub := range class.class.Subroutines {
+			if (sub.Kind == "method") != methods {
+				continue
+			}
+			kind := lspCompletionFunction
+			switch sub.Kind {
+			case "method":
+				kind = lspCompletionMethod
+			case "constructor":
+				kind = lspCompletionConstructor
+			}
+			items = append(items, lspCompletionItem{
+				Label:  sub.Name.Name,
+				Kind:   kind,
+				Detail: signature(class.class, sub),
+			})
+		}
+		return items
+	}
+
+	for _, vars := range []map[string]*lspSymbol{sc.locals, sc.classVars} {
+		for name, v := range vars {
+			kind := lspCompletionVariable
+			if v.kind == "field" || v.kind == "static" {
+				kind = lspCompletionField
+			}
+			items = append(items, lspCompletionItem{Label: name, Kind: kind, Detail: v.kind + " " + v.vType})
+		}
+	}
+	for _, sub := range f.class.Subroutines {
+		if sub.Kind == "method" {
+			items = append(items, lspCompletionItem{
+				Label:  sub.Name.Name,
+				Kind:   lspCompletionMethod,
+				Detail: signature(f.class, sub),
+			})
+		}
+	}
+	classes := make(map[string]bool)
+	for _, other := range s.files {
+		if other.dir == f.dir {
+			classes[other.class.Name.Name] = true
+		}
+	}
+	for name := range s.os {
+		classes[name] = true
+	}
+	for name := range classes {
+		items = append(items, lspCompletionItem{Label: name, Kind: lspCompletionClass})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+
+	return items
+}
+
+// documentSymbols returns the class of the file with its variables and
+// subroutines.
+func (s *lspServer) documentSymbols(uri string) interface{} {
+	f, ok := s.files[uri]
+	if !ok || f.class.Name.Name == "_" {
+		return []lspDocumentSymbol{}
+	}
+
+	class := f.class
+	symbol := lspDocumentSymbol{
+		Name:           class.Name.Name,
+		Kind:           lspSymbolClass,
+		Range:          f.blockRange(class.Pos(), class.Rbrace),
+		SelectionRange: f.identRange(class.Name),
+	}
+	for _, dec := range class.Vars {
+		kind := lspSymbolField
+		if dec.Kind == "static" {
+			kind = lspSymbolVariable
+		}
+		for _, name := range dec.Names {
+			symbol.Children = append(symbol.Children, lspDocumentSymbol{
+				Name:           name.Name,
+				Detail:         dec.Kind + " " + dec.Type.Name,
+				Kind:           kind,
+				Range:          f.identRange(name),
+				SelectionRange: f.identRange(name),
+			})
+		}
+	}
+	for _, sub := range class.Subroutines {
+		kind := lspSymbolFunction
+		switch sub.Kind {
+		case "method":
+			kind = lspSymbolMethod
+		case "constructor":
+			kind = lspSymbolConstructor
+		}
+		symbol.Children = append(symbol.Children, lspDocumentSymbol{
+			Name:           sub.Name.Name,
+			Detail:         signature(class, sub),
+			Kind:           kind,
+			Range:          f.blockRange(sub.Pos(), sub.Rbrace),
+			SelectionRange: f.identRange(sub.Name),
+		})
+	}
+
+	return []lspDocumentSymbol{symbol}
+}
+
+// line returns line i of f, counted from 0, without its line break.
+func (f *lspFile) line(i int) string {
+	if i < 0 || i >= len(f.lines) {
+		return ""
+	}
+
+	return strings.TrimSuffix(f.lines[i], "\r")
+}
+
+// toLSP returns the LSP position of pos in f. Columns past the end of the
+// line count one code unit each.
+func (f *lspFile) toLSP(pos jack.Pos) lspPosition {
+	line := f.line(pos.Line - 1)
+	col := pos.Column - 1
+	past := 0
+	if col > len(line) {
+		col, past = len(line), col-len(line)
+	}
+
+	return lspPosition{Line: pos.Line - 1, Character: len(utf16.Encode([]rune(line[:col]))) + past}
+}
+
+// fromLSP returns the position in f of the LSP position pos.
+func (f *lspFile) fromLSP(pos lspPosition) jack.Pos {
+	line := f.line(pos.Line)
+	units := 0
+	for i, r := range line {
+		if units >= pos.Character {
+			return jack.Pos{Line: pos.Line + 1, Column: i + 1}
+		}
+		units++
+		if r >= 0x10000 {
+			// a surrogate pair
+			units++
+		}
+	}
+
+	return jack.Pos{Line: pos.Line + 1, Column: len(line) + pos.Character - units + 1}
+}
+
+// before reports whether a is before b.
+func before(a, b jack.Pos) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+func (f *lspFile) identRange(ident *jack.Ident) lspRange {
+	end := ident.Pos()
+	end.Column += len(ident.Name)
+
+	return lspRange{f.toLSP(ident.Pos()), f.toLSP(end)}
+}
+
+// blockRange returns the range from pos to the closing brace rbrace, or to
+// the end of the file if the brace is missing: rbrace is then the position
+// of the token found instead.
+func (f *lspFile) blockRange(pos, rbrace jack.Pos) lspRange {
+	end := jack.Pos{Line: len(f.lines), Column: len(f.line(len(f.lines)-1)) + 1}
+	if line := f.line(rbrace.Line - 1); rbrace.Column >= 1 && rbrace.Column <= len(line) && line[rbrace.Column-1] == '}' {
+		end = rbrace
+		end.Column++
+	}
+
+	return lspRange{f.toLSP(pos), f.toLSP(end)}
+}
+
+// linePrefix returns the text of the line of pos before pos.
+func (f *lspFile) linePrefix(pos lspPosition) string {
+	line := f.line(pos.Line)
+	if col := f.fromLSP(pos).Column - 1; col < len(line) {
+		line = line[:col]
+	}
+
+	return line
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
@@ -0,0 +1,294 @@
+package main_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	pkg "project11"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const lspBall = `class Ball {
+    field int x, y;
+    constructor Ball new(int ax) {
+        let x = ax;
+        return this;
+    }
+    method int getX() {
+        return x;
+    }
+    function void reset() {
+        return;
+    }
+}`
+
+const lspMain = `class Main {
+    function void main() {
+        var int unused;
+        var Ball ball;
+        let ball = Ball.new(3);
+        do Output.printInt(ball.getX());
+        do Output.
+        return;
+    }
+}`
+
+// lspSession runs the server on the given messages and returns the
+// responses by id and the notifications.
+func lspSession(t *testing.T, messages ...map[string]interface{}) (map[int]json.RawMessage, []map[string]interface{}) {
+	var in bytes.Buffer
+	for _, msg := range messages {
+		msg["jsonrpc"] = "2.0"
+		body, err := json.Marshal(msg)
+		assert.NoError(t, err)
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	var out bytes.Buffer
+	assert.NoError(t, pkg.ServeLSP(&in, &out))
+
+	responses := make(map[int]json.RawMessage)
+	notifications := make([]map[string]interface{}, 0)
+	r := bufio.NewReader(&out)
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		length, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "Content-Length:")))
+		assert.NoError(t, err)
+		_, _ = r.ReadString('\n')
+		body := make([]byte, length)
+		_, err = io.ReadFull(r, body)
+		assert.NoError(t, err)
+
+		var msg struct {
+			ID     *int                   `json:"id"`
+			Result json.RawMessage        `json:"result"`
+			Error  json.RawMessage        `json:"error"`
+			Params map[string]interface{} `json:"params"`
+		}
+		assert.NoError(t, json.Unmarshal(body, &msg))
+		assert.Nil(t, msg.Error, "%s", body)
+		if msg.ID != nil {
+			responses[*msg.ID] = msg.Result
+		} else {
+			notifications = append(notifications, msg.Params)
+		}
+	}
+
+	return responses, notifications
+}
+
+func lspRequest(id int, method, uri string, line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"id":     id,
+		"method": method,
+		"params": map[string]interface{}{
+			"textDocument": map[string]string{"uri": uri},
+			"position":     map[string]int{"line": line, "character": character},
+		},
+	}
+}
+
+func lspChange(uri, text string) map[string]interface{} {
+	return map[string]interface{}{
+		"method": "textDocument/didChange",
+		"params": map[string]interface{}{
+			"textDocument":   map[string]string{"uri": uri},
+			"contentChanges": []map[string]string{{"text": text}},
+		},
+	}
+}
+
+func TestServeLSP(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "Ball.jack"), []byte(lspBall), 0644))
+	root := (&url.URL{Scheme: "file", Path: filepath.ToSlash(dir)}).String()
+	mainURI := root + "/Main.jack"
+	ballURI := root + "/Ball.jack"
+
+	responses, notifications := lspSession(t,
+		map[string]interface{}{"id": 1, "method": "initialize", "params": map[string]interface{}{"rootUri": root}},
+		map[string]interface{}{"method": "initialized", "params": map[string]interface{}{}},
+		map[string]interface{}{"method": "textDocument/didOpen", "params": map[string]interface{}{
+			"textDocument": map[string]string{"uri": mainURI, "text": lspMain},
+		}},
+		lspRequest(2, "textDocument/definition", mainURI, 5, 33), // getX
+		lspRequest(3, "textDocument/hover", mainURI, 5, 29),      // ball
+		lspRequest(4, "textDocument/completion", mainURI, 6, 18), // Output.
+		lspRequest(5, "textDocument/documentSymbol", ballURI, 0, 0),
+		lspRequest(6, "textDocument/hover", ballURI, 7, 15), // x
+		lspChange(mainURI, strings.Replace(lspMain, "do Output.\n", "let ball = Ball.", 1)),
+		lspRequest(7, "textDocument/completion", mainURI, 6, 24), // Ball.
+		lspChange(mainURI, strings.Replace(lspMain, "do Output.\n", "do ball.", 1)),
+		lspRequest(8, "textDocument/completion", mainURI, 6, 16), // ball.
+		lspChange(mainURI, strings.Replace(lspMain, "do Output.\n", "", 1)),
+		map[string]interface{}{"id": 9, "method": "shutdown"},
+		map[string]interface{}{"method": "exit"},
+	)
+
+	assert.Contains(t, string(responses[1]), `"definitionProvider":true`)
+
+	// syntax errors while editing, then lint once the file is fixed
+	if assert.Len(t, notifications, 4) {
+		assert.Contains(t, fmt.Sprint(notifications[0]["diagnostics"]), "expected identifier, found 'return'")
+		assert.Contains(t, fmt.Sprint(notifications[3]["diagnostics"]), "local unused declared and not used")
+	}
+
+	assert.JSONEq(t, `{"uri": "`+ballURI+`", "range": {
+		"start": {"line": 6, "character": 15}, "end": {"line": 6, "character": 19}}}`,
+		string(responses[2]))
+	assert.Contains(t, string(responses[3]), `ball      Ball      local     1`)
+	assert.Contains(t, string(responses[6]), `x         int       this      0`)
+
+	labels := func(raw json.RawMessage) []string {
+		var items []struct {
+			Label string `json:"label"`
+		}
+		assert.NoError(t, json.Unmarshal(raw, &items))
+		names := make([]string, 0)
+		for _, item := range items {
+			names = append(names, item.Label)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"moveCursor", "printChar", "printString", "printInt", "println", "backSpace"},
+		labels(responses[4]))
+	assert.Equal(t, []string{"new", "reset"}, labels(responses[7]))
+	assert.Equal(t, []string{"getX"}, labels(responses[8]))
+
+	var symbols []struct {
+		Name     string `json:"name"`
+		Children []struct {
+			Name   string `json:"name"`
+			Detail string `json:"detail"`
+		} `json:"children"`
+	}
+	assert.NoError(t, json.Unmarshal(responses[5], &symbols))
+	if assert.Len(t, symbols, 1) {
+		assert.Equal(t, "Ball", symbols[0].Name)
+		assert.Len(t, symbols[0].Children, 5)
+		assert.Equal(t, "method int Ball.getX()", symbols[0].Children[3].Detail)
+	}
+}
+
+// TestServeLSP_Incomplete checks that the server answers on every prefix
+// of a program, as while it is being typed.
+func TestServeLSP_Incomplete(t *testing.T) {
+	uri := "file:///tmp/Main.jack"
+	messages := make([]map[string]interface{}, 0)
+	for i := 0; i <= len(lspMain); i++ {
+		text := lspMain[:i]
+		lines := strings.Split(text, "\n")
+		line, character := len(lines)-1, len(lines[len(lines)-1])
+		messages = append(messages,
+			map[string]interface{}{"method": "textDocument/didOpen", "params": map[string]interface{}{
+				"textDocument": map[string]string{"uri": uri, "text": text},
+			}},
+			lspRequest(3*i, "textDocument/completion", uri, line, character),
+			lspRequest(3*i+1, "textDocument/hover", uri, line, character),
+			lspRequest(3*i+2, "textDocument/documentSymbol", uri, line, character),
+		)
+	}
+	messages = append(messages, map[string]interface{}{"id": -1, "method": "shutdown"})
+
+	// lspSession fails on error responses
+	responses, _ := lspSession(t, messages...)
+	assert.Len(t, responses, len(messages)/4*3+1)
+}
+
+func TestServeLSP_UTF16(t *testing.T) {
+	// columns count UTF-16 code units: é is one, 😀 is two
+	uri := "file:///tmp/Main.jack"
+	src := "class Main {\n    function void main() {\n        var int a;\n" +
+		"        let a = 1; /* é😀 */ let a = a;\n        return;\n    }\n}"
+	responses, _ := lspSession(t,
+		map[string]interface{}{"method": "textDocument/didOpen", "params": map[string]interface{}{
+			"textDocument": map[string]string{"uri": uri, "text": src},
+		}},
+		lspRequest(1, "textDocument/hover", uri, 3, 37),
+		map[string]interface{}{"id": 2, "method": "shutdown"},
+	)
+
+	assert.Contains(t, string(responses[1]), `a         int       local     0`)
+	assert.Contains(t, string(responses[1]), `"range":{"start":{"line":3,"character":37},"end":{"line":3,"character":38}}`)
+}
+
+func TestServeLSP_ParseError(t *testing.T) {
+	// a body that is not JSON gets an error, and the server goes on
+	in := strings.NewReader("Content-Length: 5\r\n\r\n{oops" +
+		"Content-Length: 46\r\n\r\n" + `{"jsonrpc":"2.0","id":2,"method":"shutdown"}  `)
+	var out bytes.Buffer
+	assert.NoError(t, pkg.ServeLSP(in, &out))
+	assert.Contains(t, out.String(), `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,`)
+	assert.Contains(t, out.String(), `{"jsonrpc":"2.0","id":2,"result":null}`)
+}
+
+func TestServeLSP_Program(t *testing.T) {
+	// lint works on the program, so a change republishes the diagnostics
+	// of the other open files of its directory
+	mainURI, ballURI := "file:///tmp/Main.jack", "file:///tmp/Ball.jack"
+	_, notifications := lspSession(t,
+		map[string]interface{}{"method": "textDocument/didOpen", "params": map[string]interface{}{
+			"textDocument": map[string]string{"uri": ballURI, "text": lspBall},
+		}},
+		map[string]interface{}{"method": "textDocument/didOpen", "params": map[string]interface{}{
+			"textDocument": map[string]string{"uri": mainURI, "text": "class Main {\n    function void main() {\n        do Ball.reset();\n        return;\n    }\n}"},
+		}},
+		map[string]interface{}{"method": "textDocument/didOpen", "params": map[string]interface{}{
+			"textDocument": map[string]string{"uri": "file:///other/Main.jack", "text": "class Main {}"},
+		}},
+		map[string]interface{}{"method": "textDocument/didClose", "params": map[string]interface{}{
+			"textDocument": map[string]string{"uri": mainURI},
+		}},
+		map[string]interface{}{"id": 1, "method": "shutdown"},
+	)
+
+	uris := make([]string, 0)
+	for _, n := range notifications {
+		uris = append(uris, fmt.Sprint(n["uri"]))
+	}
+	assert.Equal(t, []string{ballURI, mainURI, ballURI, "file:///other/Main.jack", mainURI, ballURI}, uris)
+	if assert.Len(t, notifications, 6) {
+		assert.Contains(t, fmt.Sprint(notifications[0]["diagnostics"]), "function Ball.reset is never called")
+		assert.NotContains(t, fmt.Sprint(notifications[2]["diagnostics"]), "function Ball.reset is never called")
+		assert.Contains(t, fmt.Sprint(notifications[5]["diagnostics"]), "function Ball.reset is never called")
+	}
+}
+
+func TestServeLSP_MissingBrace(t *testing.T) {
+	// without its closing brace, a subroutine runs to the end of the file
+	uri := "file:///tmp/Main.jack"
+	src := "class Main {\n    function void main() {\n        return;\n    }\n    function void run() {\n        return;\n"
+	responses, _ := lspSession(t,
+		map[string]interface{}{"method": "textDocument/didOpen", "params": map[string]interface{}{
+			"textDocument": map[string]string{"uri": uri, "text": src},
+		}},
+		lspRequest(1, "textDocument/documentSymbol", uri, 0, 0),
+		map[string]interface{}{"id": 2, "method": "shutdown"},
+	)
+
+	var symbols []struct {
+		Children []struct {
+			Name  string          `json:"name"`
+			Range json.RawMessage `json:"range"`
+		} `json:"children"`
+	}
+	assert.NoError(t, json.Unmarshal(responses[1], &symbols))
+	if assert.Len(t, symbols, 1) && assert.Len(t, symbols[0].Children, 2) {
+		assert.JSONEq(t, `{"start":{"line":1,"character":4},"end":{"line":3,"character":5}}`, string(symbols[0].Children[0].Range))
+		assert.JSONEq(t, `{"start":{"line":4,"character":4},"end":{"line":6,"character":0}}`, string(symbols[0].Children[1].Range))
+	}
+}
@@ -1,63 +1,186 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+
+	"jack"
+)
+
+var (
+	inline   = flag.Int("inline", 10, "largest subroutine, in VM commands, that -O=2 inlines")
+	intern   = flag.Bool("intern", false, "allocate each string constant once instead of at every evaluation")
+	extended = flag.Bool("x", false, "accept the extended language: for, else if, break, continue, +=, character and hexadecimal constants")
+	workers  = flag.Int("j", 0, "number of files compiled in parallel, the number of CPUs by default")
+	force    = flag.Bool("a", false, "recompile the files that are up to date")
+	shadow   = flag.Bool("shadow", false, "warn when a local variable or argument shadows a field or static")
 )
 
+// optimize is the optimization level: 1 optimizes the VM code and the self
+// tail calls, and 2 also inlines small leaf subroutines. -O alone is
+// level 1.
+var optimize level
+
+func init() {
+	flag.Var(&optimize, "O", "optimization level, -O or -O=1 optimizes the VM code and tail calls, -O=2 also inlines small subroutines")
+}
+
 func main() {
 	//os.Args = []string{"", "test/Pong/PongGame.jack"}
-	if len(os.Args) < 2 {
-		printErr("invalid number of arguments")
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		lintMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		fmtMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "lsp" {
+		// the client talks to the server over stdin and stdout
+		if err := ServeLSP(os.Stdin, os.Stdout); err != nil {
+			printErr(err.Error())
+		}
+		return
 	}
 
-	jackFiles := make([]string, 0)
+	flag.Parse()
+	if flag.NArg() < 1 {
+		printErr("invalid number of arguments")
+	}
+	path := flag.Arg(0)
 
-	// Open file
-	file, err := os.OpenFile(os.Args[1], os.O_RDONLY, 0)
+	info, err := os.Stat(path)
 	if err != nil {
-		printErr(fmt.Sprintf("%s file not exists\n", os.Args[1]))
+		printErr(fmt.Sprintf("%s file not exists\n", path))
+	}
+
+	dir, jackFiles := filepath.Dir(path), []string{filepath.Base(path)}
+	if info.IsDir() {
+		dir, jackFiles = path, getJackFiles(path)
 	}
-	defer file.Close()
 
-	fileInfo, err := file.Stat()
+	opts := BuildOptions{
+		Optimize: optimize > 0,
+		Extended: *extended,
+		Workers:  *workers,
+		Force:    *force,
+
+		InternStrings: *intern,
+	}
+	if optimize > 1 {
+		opts.Inline = *inline
+	}
+	if *shadow {
+		opts.Warn = func(d Diagnostic) {
+			fmt.Fprintln(os.Stderr, d)
+		}
+	}
+	_, err = Build(dir, jackFiles, opts)
 	if err != nil {
 		printErr(err.Error())
 	}
+}
 
-	isDir := false
-	if fileInfo.IsDir() {
-		isDir = true
-		jackFiles = getJackFiles(file.Name())
-	} else {
-		jackFiles = append(jackFiles, file.Name())
+// lintMain reports the problems found by Lint in the given files and
+// directories, which are checked together as one program.
+func lintMain(args []string) {
+	if len(args) < 1 {
+		printErr("usage: project11 lint <file or directory>...")
 	}
 
-	for _, jack := range jackFiles {
-		var parser Parser
-		readFile := jack
-		if isDir {
-			readFile = os.Args[1] + "/" + jack
-		}
-		src, err := os.ReadFile(readFile)
-		if err != nil {
-			printErr(err.Error())
-		}
-		parser.Init(src)
-		parser.ParseFile()
-
-		fileName := ""
-		if isDir {
-			fileName = os.Args[1] + "/" + jack[:strings.Index(jack, ".jack")]
-		} else {
-			fileName = os.Args[1][:strings.Index(os.Args[1], ".jack")]
+	classes := make(map[string]*jack.ClassDecl)
+	failed := false
+	for _, path := range args {
+		for _, file := range jackPaths(path) {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				printErr(err.Error())
+			}
+			class, errs := jack.Parse(src)
+			for _, e := range errs {
+				fmt.Fprintf(os.Stderr, "%v:%v\n", file, e)
+				failed = true
+			}
+			classes[file] = class
 		}
-		writeErr := os.WriteFile(fileName+".an", []byte(parser.VmOut()), 0644)
-		if writeErr != nil {
-			printErr(err.Error())
+	}
+
+	for _, d := range Lint(classes) {
+		fmt.Println(d)
+		failed = true
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// fmtMain formats the given files and directories. Like gofmt it prints
+// the formatted sources unless -l or -w is given.
+func fmtMain(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	list := flags.Bool("l", false, "list files whose formatting differs")
+	write := flags.Bool("w", false, "write result to the source file")
+	_ = flags.Parse(args)
+	if flags.NArg() < 1 {
+		printErr("usage: project11 fmt [-l] [-w] <file or directory>...")
+	}
+
+	failed := false
+	for _, path := range flags.Args() {
+		for _, file := range jackPaths(path) {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				printErr(err.Error())
+			}
+			res, err := Format(src)
+			if err != nil {
+				for _, e := range err.(jack.ErrorList) {
+					fmt.Fprintf(os.Stderr, "%v:%v\n", file, e)
+				}
+				failed = true
+				continue
+			}
+
+			changed := string(res) != string(src)
+			if *list && changed {
+				fmt.Println(file)
+			}
+			if *write && changed {
+				if err := os.WriteFile(file, res, 0644); err != nil {
+					printErr(err.Error())
+				}
+			}
+			if !*list && !*write {
+				fmt.Print(string(res))
+			}
 		}
 	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// jackPaths returns path if it is a file, or the .jack files in it if it
+// is a directory.
+func jackPaths(path string) []string {
+	info, err := os.Stat(path)
+	if err != nil {
+		printErr(fmt.Sprintf("%s file not exists\n", path))
+	}
+	if !info.IsDir() {
+		return []string{path}
+	}
+
+	paths := make([]string, 0)
+	for _, name := range getJackFiles(path) {
+		paths = append(paths, filepath.Join(path, name))
+	}
+
+	return paths
 }
 
 func printErr(err string) {
@@ -83,3 +206,28 @@ func getJackFiles(dir string) []string {
 
 	return jackFiles
 }
+
+// level is a flag for optimization levels that may be given without a
+// value, like a boolean flag.
+type level int
+
+func (l *level) String() string { return strconv.Itoa(int(*l)) }
+
+func (l *level) IsBoolFlag() bool { return true }
+
+func (l *level) Set(s string) error {
+	switch s {
+	case "true":
+		*l = 1
+	case "false":
+		*l = 0
+	default:
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > 2 {
+			return fmt.Errorf("invalid level %q, want 0, 1 or 2", s)
+		}
+		*l = level(n)
+	}
+
+	return nil
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxDoublings is the largest k for which x * 2^k is rewritten into
+// repeated additions. Math.multiply loops over all 16 bits of its operand,
+// so even a few additions are far cheaper than the call.
+const maxDoublings = 4
+
+// scratch is the temp segment slot used to duplicate the top of the stack.
+// The compiler only uses temp 0 itself.
+const scratch = 1
+
+type vmCommand struct {
+	name string // push, pop, add, label, call, function, ...
+	arg1 string // segment, label or function name
+	arg2 int    // index, number of arguments or locals
+}
+
+func (c vmCommand) String() string {
+	switch c.name {
+	case "push", "pop", "call", "function":
+		return fmt.Sprintf("%v %v %d", c.name, c.arg1, c.arg2)
+	case "label", "goto", "if-goto":
+		return c.name + " " + c.arg1
+	}
+
+	return c.name
+}
+
+func parseVmCommands(vm string) []vmCommand {
+	lines := strings.Split(vm, "\n")
+	cmds := make([]vmCommand, 0, len(lines))
+	for _, line := range lines {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		cmd := vmCommand{name: fields[0]}
+		if len(fields) > 1 {
+			cmd.arg1 = fields[1]
+		}
+		if len(fields) > 2 {
+			cmd.arg2, _ = strconv.Atoi(fields[2])
+		}
+		cmds = append(cmds, cmd)
+	}
+
+	return cmds
+}
+
+func formatVmCommands(cmds []vmCommand) string {
+	var out strings.Builder
+	for _, cmd := range cmds {
+		// same layout as VmWriter: only function and label are not indented
+		if cmd.name != "function" && cmd.name != "label" {
+			out.WriteString("    ")
+		}
+		out.WriteString(cmd.String())
+		out.WriteString("\n")
+	}
+
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// CountCommands returns the number of VM commands in vm.
+func CountCommands(vm string) int {
+	return len(parseVmCommands(vm))
+}
+
+// Optimize rewrites the VM code produced by the compiler:
+//   - folds constant arithmetic with 16-bit wraparound
+//   - removes x + 0, x - 0, x * 1, x / 1, ~~x and --x
+//   - turns x * 2^k into repeated additions
+//   - drops branches on constant conditions, so if (false) and while (false)
+//     bodies disappear together with any other unreachable code
+//
+// The passes run until none of them changes the code anymore.
+func Optimize(vm string) string {
+	cmds := parseVmCommands(vm)
+	passes := []func([]vmCommand) ([]vmCommand, bool){
+		foldConstants,
+		simplifyIdentities,
+		strengthReduce,
+		foldBranches,
+		removeDeadCode,
+		removeUnusedLabels,
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, pass := range passes {
+			var ok bool
+			cmds, ok = pass(cmds)
+			changed = changed || ok
+		}
+	}
+
+	return formatVmCommands(cmds)
+}
+
+// constantAt reports whether cmds[i:] starts with a constant, that is a
+// push constant followed by any number of neg or not, and returns its value
+// and the number of commands it spans.
+func constantAt(cmds []vmCommand, i int) (int16, int, bool) {
+	if i >= len(cmds) || cmds[i].name != "push" || cmds[i].arg1 != "constant" {
+		return 0, 0, false
+	}
+	if cmds[i].arg2 < 0 || cmds[i].arg2 > 32767 {
+		return 0, 0, false
+	}
+
+	value := int16(cmds[i].arg2)
+	n := 1
+	for ; i+n < len(cmds); n++ {
+		switch cmds[i+n].name {
+		case "neg":
+			value = -value
+			continue
+		case "not":
+			value = ^value
+			continue
+		}
+		break
+	}
+
+	return value, n, true
+}
+
+// pushConstant returns the shortest commands that push value.
+// push constant only accepts 0..32767, so negative values are negated.
+func pushConstant(value int16) []vmCommand {
+	switch {
+	case value >= 0:
+		return []vmCommand{{"push", "constant", int(value)}}
+	case value == -32768:
+		return []vmCommand{{"push", "constant", 32767}, {name: "not"}}
+	default:
+		return []vmCommand{{"push", "constant", int(-value)}, {name: "neg"}}
+	}
+}
+
+// evalBinary evaluates a binary command on two constants.
+func evalBinary(cmd vmCommand, x, y int16) (int16, bool) {
+	boolean := func(b bool) int16 {
+		if b {
+			return -1
+		}
+		return 0
+	}
+
+	switch cmd.name {
+	case "add":
+		return x + y, true
+	case "sub":
+		return x - y, true
+	case "and":
+		return x & y, true
+	case "or":
+		return x | y, true
+	case "eq":
+		return boolean(x == y), true
+	case "gt":
+		return boolean(x > y), true
+	case "lt":
+		return boolean(x < y), true
+	case "call":
+		switch {
+		case cmd.arg1 == "Math.multiply" && cmd.arg2 == 2:
+			return x * y, true
+		case cmd.arg1 == "Math.divide" && cmd.arg2 == 2:
+			if y == 0 || x == -32768 && y == -1 {
+				return 0, false
+			}
+			return x / y, true
+		}
+	}
+
+	return 0, false
+}
+
+func isCall(cmd vmCommand, fName string) bool {
+	return cmd.name == "call" && cmd.arg1 == fName && cmd.arg2 == 2
+}
+
+// isSimplePush reports whether cmd pushes a value without side effects,
+// so it can be evaluated a second time.
+func isSimplePush(cmd vmCommand) bool {
+	return cmd.name == "push" && cmd.arg1 != "constant"
+}
+
+func equalCommands(a, b []vmCommand) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func splice(cmds []vmCommand, i, n int, with ...vmCommand) []vmCommand {
+	out := make([]vmCommand, 0, len(cmds)-n+len(with))
+	out = append(out, cmds[:i]...)
+	out = append(out, with...)
+
+	return append(out, cmds[i+n:]...)
+}
+
+// foldConstants replaces constant expressions by their value.
+func foldConstants(cmds []vmCommand) ([]vmCommand, bool) {
+	changed := false
+	for i := 0; i < len(cmds); i++ {
+		x, n, ok := constantAt(cmds, i)
+		if !ok {
+			continue
+		}
+
+		if y, m, ok := constantAt(cmds, i+n); ok && i+n+m < len(cmds) {
+			if v, ok := evalBinary(cmds[i+n+m], x, y); ok {
+				cmds = splice(cmds, i, n+m+1, pushConstant(v)...)
+				changed = true
+				continue
+			}
+		}
+
+		canonical := pushConstant(x)
+		if len(canonical) <= n && !equalCommands(canonical, cmds[i:i+n]) {
+			cmds = splice(cmds, i, n, canonical...)
+			changed = true
+		}
+	}
+
+	return cmds, changed
+}
+
+// simplifyIdentities removes operations that leave their operand unchanged.
+func simplifyIdentities(cmds []vmCommand) ([]vmCommand, bool) {
+	changed := false
+	for i := 0; i < len(cmds); i++ {
+		// x op c
+		if c, n, ok := constantAt(cmds, i); ok && i+n < len(cmds) {
+			op := cmds[i+n]
+			if c == 0 && (op.name == "add" || op.name == "sub" || op.name == "or") ||
+				c == -1 && op.name == "and" ||
+				c == 1 && (isCall(op, "Math.multiply") || isCall(op, "Math.divide")) {
+				cmds = splice(cmds, i, n+1)
+				changed = true
+				i--
+				continue
+			}
+		}
+
+		// c op x, only when x has no side effects
+		if c, n, ok := constantAt(cmds, i); ok && i+n+1 < len(cmds) && isSimplePush(cmds[i+n]) {
+			op := cmds[i+n+1]
+			if c == 0 && (op.name == "add" || op.name == "or") ||
+				c == -1 && op.name == "and" ||
+				c == 1 && isCall(op, "Math.multiply") {
+				cmds = splice(cmds, i, n+2, cmds[i+n])
+				changed = true
+				continue
+			}
+		}
+
+		// ~~x and --x
+		if i+1 < len(cmds) &&
+			(cmds[i].name == "not" || cmds[i].name == "neg") &&
+			cmds[i].name == cmds[i+1].name {
+			cmds = splice(cmds, i, 2)
+			changed = true
+		}
+	}
+
+	return cmds, changed
+}
+
+// log2 returns k when c is 2^k.
+func log2(c int16) (int, bool) {
+	if c <= 0 || c&(c-1) != 0 {
+		return 0, false
+	}
+
+	k := 0
+	for ; c > 1; c >>= 1 {
+		k++
+	}
+
+	return k, true
+}
+
+// endsWithConstant reports whether the last commands of cmds push a constant.
+func endsWithConstant(cmds []vmCommand) bool {
+	i := len(cmds) - 1
+	for i >= 0 && (cmds[i].name == "neg" || cmds[i].name == "not") {
+		i--
+	}
+
+	return i >= 0 && cmds[i].name == "push" && cmds[i].arg1 == "constant"
+}
+
+// double returns the commands that double the top of the stack.
+func double() []vmCommand {
+	return []vmCommand{
+		{"pop", "temp", scratch},
+		{"push", "temp", scratch},
+		{"push", "temp", scratch},
+		{name: "add"},
+	}
+}
+
+// strengthReduce turns multiplications by a power of two into additions.
+func strengthReduce(cmds []vmCommand) ([]vmCommand, bool) {
+	changed := false
+	for i := 0; i < len(cmds); i++ {
+		c, n, ok := constantAt(cmds, i)
+		if !ok {
+			continue
+		}
+		k, ok := log2(c)
+		if !ok || k == 0 || k > maxDoublings {
+			continue
+		}
+
+		var with []vmCommand
+		switch {
+		case i+n < len(cmds) && isCall(cmds[i+n], "Math.multiply"):
+			// x * 2^k
+			if endsWithConstant(cmds[:i]) {
+				// left to foldConstants
+				continue
+			}
+			if i > 0 && isSimplePush(cmds[i-1]) {
+				with = []vmCommand{cmds[i-1], cmds[i-1], {name: "add"}}
+				i--
+				n++
+			} else {
+				with = double()
+			}
+		case i+n+1 < len(cmds) && isSimplePush(cmds[i+n]) &&
+			isCall(cmds[i+n+1], "Math.multiply"):
+			// 2^k * x
+			with = []vmCommand{cmds[i+n], cmds[i+n], {name: "add"}}
+			n++
+		default:
+			continue
+		}
+
+		for j := 1; j < k; j++ {
+			with = append(with, double()...)
+		}
+		cmds = splice(cmds, i, n+1, with...)
+		changed = true
+	}
+
+	return cmds, changed
+}
+
+// foldBranches replaces if-goto on a constant by goto or removes it.
+func foldBranches(cmds []vmCommand) ([]vmCommand, bool) {
+	changed := false
+	for i := 0; i < len(cmds); i++ {
+		c, n, ok := constantAt(cmds, i)
+		if !ok || i+n >= len(cmds) || cmds[i+n].name != "if-goto" {
+			continue
+		}
+
+		if c == 0 {
+			cmds = splice(cmds, i, n+1)
+		} else {
+			cmds = splice(cmds, i, n+1, vmCommand{name: "goto", arg1: cmds[i+n].arg1})
+		}
+		changed = true
+		i--
+	}
+
+	return cmds, changed
+}
+
+// removeDeadCode removes commands that follow goto or return and that
+// cannot be jumped to, plus gotos to the very next command.
+func removeDeadCode(cmds []vmCommand) ([]vmCommand, bool) {
+	out := make([]vmCommand, 0, len(cmds))
+	reachable := true
+	for _, cmd := range cmds {
+		if cmd.name == "label" || cmd.name == "function" {
+			reachable = true
+		}
+		if !reachable {
+			continue
+		}
+
+		if cmd.name == "label" && len(out) > 0 {
+			last := out[len(out)-1]
+			if last.name == "goto" && last.arg1 == cmd.arg1 {
+				out = out[:len(out)-1]
+			}
+		}
+
+		out = append(out, cmd)
+		if cmd.name == "goto" || cmd.name == "return" {
+			reachable = false
+		}
+	}
+
+	return out, len(out) != len(cmds)
+}
+
+// removeUnusedLabels removes labels that are never jumped to.
+func removeUnusedLabels(cmds []vmCommand) ([]vmCommand, bool) {
+	used := make(map[string]bool)
+	for _, cmd := range cmds {
+		if cmd.name == "goto" || cmd.name == "if-goto" {
+			used[cmd.arg1] = true
+		}
+	}
+
+	out := make([]vmCommand, 0, len(cmds))
+	for _, cmd := range cmds {
+		if cmd.name == "label" && !used[cmd.arg1] {
+			continue
+		}
+		out = append(out, cmd)
+	}
+
+	return out, len(out) != len(cmds)
+}
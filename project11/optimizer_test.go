@@ -0,0 +1,158 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	pkg "project11"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		name string
+		vm   string
+		want string
+	}{
+		{
+			"1. fold addition",
+			"push constant 2\npush constant 3\nadd\npop local 0",
+			"    push constant 5\n    pop local 0",
+		},
+		{
+			"2. fold nested expression",
+			"push constant 2\npush constant 3\nadd\npush constant 4\ncall Math.multiply 2",
+			"    push constant 20",
+		},
+		{
+			"3. fold to negative constant",
+			"push constant 1\npush constant 3\nsub",
+			"    push constant 2\n    neg",
+		},
+		{
+			"4. 16-bit wraparound",
+			"push constant 32767\npush constant 1\nadd",
+			"    push constant 32767\n    not",
+		},
+		{
+			"5. canonical -1",
+			"push constant 0\nnot",
+			"    push constant 1\n    neg",
+		},
+		{
+			"6. keep division by zero",
+			"push constant 1\npush constant 0\ncall Math.divide 2",
+			"    push constant 1\n    push constant 0\n    call Math.divide 2",
+		},
+		{
+			"7. fold comparison",
+			"push constant 3\npush constant 2\ngt",
+			"    push constant 1\n    neg",
+		},
+		{
+			"8. x + 0",
+			"push local 0\npush constant 0\nadd\npop local 1",
+			"    push local 0\n    pop local 1",
+		},
+		{
+			"9. x * 1",
+			"push argument 0\npush constant 1\ncall Math.multiply 2",
+			"    push argument 0",
+		},
+		{
+			"10. 1 * x",
+			"push constant 1\npush argument 0\ncall Math.multiply 2",
+			"    push argument 0",
+		},
+		{
+			"11. ~~x",
+			"push local 0\nnot\nnot\npop local 0",
+			"    push local 0\n    pop local 0",
+		},
+		{
+			"12. x * 2",
+			"push argument 0\npush constant 2\ncall Math.multiply 2",
+			"    push argument 0\n    push argument 0\n    add",
+		},
+		{
+			"13. x * 4",
+			"push argument 0\npush constant 4\ncall Math.multiply 2",
+			"    push argument 0\n    push argument 0\n    add\n" +
+				"    pop temp 1\n    push temp 1\n    push temp 1\n    add",
+		},
+		{
+			"14. x * 64 is left to Math.multiply",
+			"push argument 0\npush constant 64\ncall Math.multiply 2",
+			"    push argument 0\n    push constant 64\n    call Math.multiply 2",
+		},
+		{
+			"15. if (false)",
+			"function Main.main 0\n" +
+				"push constant 0\nnot\nif-goto L1\n" +
+				"push constant 1\ncall Output.printInt 1\npop temp 0\n" +
+				"goto L0\nlabel L1\nlabel L0\n" +
+				"push constant 0\nreturn",
+			"function Main.main 0\n    push constant 0\n    return",
+		},
+		{
+			"16. if (true) else",
+			"function Main.main 0\n" +
+				"push constant 1\nneg\nnot\nif-goto L1\n" +
+				"push constant 1\ncall Output.printInt 1\npop temp 0\n" +
+				"goto L0\nlabel L1\n" +
+				"push constant 2\ncall Output.printInt 1\npop temp 0\n" +
+				"label L0\n" +
+				"push constant 0\nreturn",
+			"function Main.main 0\n" +
+				"    push constant 1\n    call Output.printInt 1\n    pop temp 0\n" +
+				"    push constant 0\n    return",
+		},
+		{
+			"17. while (false)",
+			"function Main.main 0\n" +
+				"label L0\npush constant 0\nnot\nif-goto L1\n" +
+				"push constant 1\ncall Output.printInt 1\npop temp 0\n" +
+				"goto L0\nlabel L1\n" +
+				"push constant 0\nreturn",
+			"function Main.main 0\n    push constant 0\n    return",
+		},
+		{
+			"18. keep loops with a variable condition",
+			"function Main.main 0\n" +
+				"label L0\npush local 0\nnot\nif-goto L1\n" +
+				"goto L0\nlabel L1\n" +
+				"push constant 0\nreturn",
+			"function Main.main 0\n" +
+				"label L0\n    push local 0\n    not\n    if-goto L1\n" +
+				"    goto L0\nlabel L1\n" +
+				"    push constant 0\n    return",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, pkg.Optimize(tt.vm))
+		})
+	}
+}
+
+func TestOptimize_Programs(t *testing.T) {
+	files, err := filepath.Glob("./test/*/*.jack")
+	assert.NoError(t, err)
+
+	for _, file := range files {
+		t.Run(file, func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			assert.NoError(t, err)
+			out, err := pkg.Compile(src, false)
+			assert.NoError(t, err)
+
+			before := pkg.CountCommands(out)
+			after := pkg.CountCommands(pkg.Optimize(out))
+			t.Logf("%v: %d -> %d VM commands", file, before, after)
+			assert.LessOrEqual(t, after, before)
+		})
+	}
+}
@@ -1,16 +1,20 @@
 package main_test
 
 import (
+	"fmt"
 	"os"
 	"strings"
 	"testing"
 
+	"hack/vmemu"
+	"jack"
+	"jack/vm"
 	pkg "project11"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func TestParser_ParseFile(t *testing.T) {
+func TestCompile(t *testing.T) {
 	type fields struct {
 		dest string
 		want string
@@ -137,11 +141,10 @@ func TestParser_ParseFile(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			src, err := os.ReadFile(tt.fields.dest)
 			assert.NoError(t, err)
-			var p pkg.Parser
-			p.Init(src)
-			p.ParseFile()
+			out, err := pkg.Compile(src, false)
+			assert.NoError(t, err)
 
-			err = os.WriteFile(tt.fields.out, []byte(p.VmOut()), 0644)
+			err = os.WriteFile(tt.fields.out, []byte(out), 0644)
 			assert.NoError(t, err)
 
 			want, err := os.ReadFile(tt.fields.want)
@@ -149,8 +152,390 @@ func TestParser_ParseFile(t *testing.T) {
 			assert.Equal(
 				t,
 				strings.TrimSuffix(string(want), "\n"),
-				p.VmOut(),
+				out,
 			)
 		})
 	}
 }
+
+func TestCompile_Scoping(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		wants []string
+	}{
+		{
+			"1. local shadows field",
+			`class Main {
+    field int x;
+    method void set() {
+        var int x;
+        let x = 1;
+        return;
+    }
+}`,
+			[]string{"push constant 1", "pop local 0"},
+		},
+		{
+			"2. argument shadows static",
+			`class Main {
+    static int x;
+    function int get(int x) {
+        return x;
+    }
+}`,
+			[]string{"push argument 0", "return"},
+		},
+		{
+			"3. constructor does not see the arguments of a previous function",
+			`class Main {
+    field int size;
+    function void f(int a) {
+        return;
+    }
+    constructor Main new(int s) {
+        let size = s;
+        return this;
+    }
+}`,
+			[]string{"push argument 0", "pop this 0"},
+		},
+		{
+			"4. method call on a local shadowing a field",
+			`class Main {
+    field int b;
+    function int get() {
+        var Ball b;
+        var int r;
+        let r = b.size();
+        return r;
+    }
+}`,
+			[]string{"push local 0", "call Ball.size 1", "pop local 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := pkg.Compile([]byte(tt.src), false)
+			assert.NoError(t, err)
+
+			lines := make([]string, 0)
+			for _, line := range strings.Split(out, "\n") {
+				lines = append(lines, strings.TrimSpace(line))
+			}
+			for _, want := range tt.wants {
+				assert.Contains(t, lines, want)
+			}
+			assert.NotContains(t, out, "static")
+		})
+	}
+}
+
+func TestGenerate_Warnings(t *testing.T) {
+	class, errs := jack.Parse([]byte(`class Main {
+    field int x, y;
+    static int z;
+    method void f(int z) {
+        var int a, x;
+        return;
+    }
+}`))
+	assert.NoError(t, errs.Err())
+
+	_, warnings := vm.Generate(class, vm.Options{})
+	gots := make([]string, 0)
+	for _, e := range warnings {
+		gots = append(gots, e.Error())
+	}
+	assert.Equal(t, []string{
+		"4:23: argument z shadows static z",
+		"5:20: local x shadows field x",
+	}, gots)
+}
+
+func TestGenerate_Extended(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			"1. for loop with continue",
+			"for (i = 0; i < 2; i += 1) { continue; }",
+			[]string{
+				"push constant 0", "pop local 0",
+				"label L0",
+				"push local 0", "push constant 2", "lt", "not", "if-goto L2",
+				"goto L1",
+				"label L1",
+				"push local 0", "push constant 1", "add", "pop local 0",
+				"goto L0",
+				"label L2",
+			},
+		},
+		{
+			"2. break in while",
+			"while (true) { break; }",
+			[]string{
+				"label L0",
+				"push constant 1", "neg", "not", "if-goto L1",
+				"goto L1",
+				"goto L0",
+				"label L1",
+			},
+		},
+		{
+			"3. compound array assignment evaluates the index once",
+			"let a[Main.f()] -= 2;",
+			[]string{
+				"call Main.f 0", "push local 1", "add",
+				"pop pointer 1", "push pointer 1", "push that 0",
+				"push constant 2", "sub",
+				"pop temp 0", "pop pointer 1", "push temp 0", "pop that 0",
+			},
+		},
+		{
+			"4. else if",
+			"if (i) { let i = 1; } else if (i) { let i = 2; }",
+			[]string{
+				"push local 0", "not", "if-goto L1",
+				"push constant 1", "pop local 0",
+				"goto L0",
+				"label L1",
+				"push local 0", "not", "if-goto L3",
+				"push constant 2", "pop local 0",
+				"goto L2",
+				"label L3",
+				"label L2",
+				"label L0",
+			},
+		},
+		{
+			"5. constants",
+			`let i = 'A' + 0x7FFF + 0b11 + 0xFFFF; do Output.printString("\"\n");`,
+			[]string{
+				"push constant 65", "push constant 32767", "add",
+				"push constant 3", "add",
+				"push constant 0", "not", "add",
+				"pop local 0",
+				"push constant 2", "call String.new 1",
+				"push constant 34", "call String.appendChar 2",
+				"push constant 128", "call String.appendChar 2",
+				"call Output.printString 1", "pop temp 0",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "class Main {\n  function int f() {\n    var int i;\n    var Array a;\n" + tt.body + "\n    return 0;\n  }\n}"
+			class, errs := jack.ParseMode([]byte(src), jack.Extended)
+			assert.NoError(t, errs.Err())
+
+			out, _ := vm.Generate(class, vm.Options{})
+			lines := make([]string, 0)
+			for _, line := range strings.Split(out, "\n")[1:] {
+				lines = append(lines, strings.TrimSpace(line))
+			}
+			assert.Equal(t, append(tt.want, "push constant 0", "return"), lines)
+		})
+	}
+}
+
+func TestGenerate_InternStrings(t *testing.T) {
+	class, errs := jack.Parse([]byte(`class Main {
+    static int n;
+    function void main() {
+        while (true) {
+            do Output.printString("hi");
+            do Output.printString("ok");
+            do Output.printString("hi");
+        }
+        return;
+    }
+}`))
+	assert.NoError(t, errs.Err())
+
+	out, _ := vm.Generate(class, vm.Options{InternStrings: true})
+	lines := make([]string, 0)
+	for _, line := range strings.Split(out, "\n") {
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	interned := func(label, static int, chars ...int) []string {
+		code := []string{
+			fmt.Sprintf("push static %d", static),
+			fmt.Sprintf("if-goto L%d", label),
+			fmt.Sprintf("push constant %d", len(chars)),
+			"call String.new 1",
+		}
+		for _, ch := range chars {
+			code = append(code, fmt.Sprintf("push constant %d", ch), "call String.appendChar 2")
+		}
+		return append(code,
+			fmt.Sprintf("pop static %d", static),
+			fmt.Sprintf("label L%d", label),
+			fmt.Sprintf("push static %d", static),
+			"call Output.printString 1",
+			"pop temp 0",
+		)
+	}
+
+	want := []string{"function Main.main 0", "label L0", "push constant 1", "neg", "not", "if-goto L1"}
+	want = append(want, interned(2, 1, 'h', 'i')...)
+	want = append(want, interned(3, 2, 'o', 'k')...)
+	want = append(want, interned(4, 1, 'h', 'i')...)
+	want = append(want, "goto L0", "label L1", "push constant 0", "return")
+	assert.Equal(t, want, lines)
+}
+
+func TestGenerate_InternStringsStatics(t *testing.T) {
+	// 241 strings, after a static: the last two do not fit in the static
+	// segment
+	var src strings.Builder
+	src.WriteString("class Main {\n    static int n;\n    function void main() {\n")
+	for i := 0; i < 241; i++ {
+		fmt.Fprintf(&src, "        do Output.printString(\"%d\");\n", i)
+	}
+	src.WriteString("        return;\n    }\n}")
+	class, errs := jack.Parse([]byte(src.String()))
+	assert.NoError(t, errs.Err())
+
+	out, _ := vm.Generate(class, vm.Options{InternStrings: true})
+	assert.Equal(t, 241, strings.Count(out, "call String.new 1"))
+	assert.Equal(t, 239, strings.Count(out, "pop static"))
+	assert.Contains(t, out, "pop static 239\n")
+	assert.NotContains(t, out, "static 240\n")
+}
+
+func TestGenerate_TailCalls(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			"1. function",
+			`class Main {
+    function int sum(int n, int acc) {
+        if (n = 0) {
+            return acc;
+        }
+        return Main.sum(n - 1, (acc + n));
+    }
+}`,
+			[]string{
+				"function Main.sum 0",
+				"label L0",
+				"push argument 0", "push constant 0", "eq", "not", "if-goto L2",
+				"push argument 1", "return",
+				"goto L1", "label L2", "label L1",
+				"push argument 0", "push constant 1", "sub",
+				"push argument 1", "push argument 0", "add",
+				"pop argument 1", "pop argument 0",
+				"goto L0",
+			},
+		},
+		{
+			"2. method with local variables",
+			`class List {
+    field List next;
+    method List last() {
+        var List l;
+        let l = next;
+        if (l = null) {
+            return this;
+        }
+        return (last());
+    }
+}`,
+			[]string{
+				"function List.last 1",
+				"push argument 0", "pop pointer 0",
+				"label L0",
+				"push this 0", "pop local 0",
+				"push local 0", "push constant 0", "eq", "not", "if-goto L2",
+				"push pointer 0", "return",
+				"goto L1", "label L2", "label L1",
+				"push constant 0", "pop local 0",
+				"goto L0",
+			},
+		},
+		{
+			"3. no tail calls",
+			`class Main {
+    method int f(int n) {
+        var Main m;
+        if (n = 0) {
+            return Main.f(n);
+        }
+        if (n = 1) {
+            return m.f(n);
+        }
+        return 1 + f(n - 1);
+    }
+}`,
+			[]string{
+				"function Main.f 1",
+				"push argument 0", "pop pointer 0",
+				"push argument 1", "push constant 0", "eq", "not", "if-goto L1",
+				"push argument 1", "call Main.f 1", "return",
+				"goto L0", "label L1", "label L0",
+				"push argument 1", "push constant 1", "eq", "not", "if-goto L3",
+				"push local 0", "push argument 1", "call Main.f 2", "return",
+				"goto L2", "label L3", "label L2",
+				"push constant 1", "push pointer 0", "push argument 1", "push constant 1", "sub", "call Main.f 2", "add",
+				"return",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class, errs := jack.Parse([]byte(tt.src))
+			assert.NoError(t, errs.Err())
+
+			out, _ := vm.Generate(class, vm.Options{TailCalls: true})
+			lines := make([]string, 0)
+			for _, line := range strings.Split(out, "\n") {
+				lines = append(lines, strings.TrimSpace(line))
+			}
+			assert.Equal(t, tt.want, lines)
+		})
+	}
+}
+
+// TestGenerate_TailCallsRun runs a recursion that is deeper than the RAM,
+// which only works with tail calls.
+func TestGenerate_TailCallsRun(t *testing.T) {
+	class, errs := jack.Parse([]byte(`class Main {
+    function int sum(int n, int acc) {
+        if (n = 0) {
+            return acc;
+        }
+        return Main.sum(n - 1, acc + n);
+    }
+}`))
+	assert.NoError(t, errs.Err())
+	sys := vmemu.File{Name: "Sys", Src: []byte("function Sys.init 0\n" +
+		"push constant 10000\npush constant 0\ncall Main.sum 2\npop temp 0\n" +
+		"push constant 0\nreturn")}
+	var want int16
+	for i := 1; i <= 10000; i++ {
+		want += int16(i)
+	}
+
+	for _, tailCalls := range []bool{false, true} {
+		out, _ := vm.Generate(class, vm.Options{TailCalls: tailCalls})
+		p, err := vmemu.Load(sys, vmemu.File{Name: "Main", Src: []byte(out)})
+		assert.NoError(t, err)
+		m := vmemu.New(p)
+		err = m.Run(10_000_000)
+		if !tailCalls {
+			assert.ErrorContains(t, err, "out of range")
+			continue
+		}
+		assert.NoError(t, err)
+		assert.True(t, m.Halted())
+		assert.Equal(t, want, m.RAM[5])
+	}
+}
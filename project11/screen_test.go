@@ -0,0 +1,73 @@
+package main_test
+
+import (
+	"flag"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"hack/keyboard"
+	"hack/screen"
+	"hack/vmemu"
+	pkg "project11"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update the golden images of the tests")
+
+// TestScreen_Golden runs test programs with the compiled project 12 OS
+// until they call Sys.halt, and compares their screen with the golden image
+// Main.png of their directory. The keys of keys.txt, if there is one, are
+// pressed during the run. go test -update writes the images again.
+func TestScreen_Golden(t *testing.T) {
+	lib, _ := compileOS(t)
+	for _, dir := range []string{"ScreenTest", "Square"} {
+		t.Run(dir, func(t *testing.T) {
+			dir := filepath.Join("./test", dir)
+			golden := filepath.Join(dir, "Main.png")
+			files, err := filepath.Glob(filepath.Join(dir, "*.jack"))
+			assert.NoError(t, err)
+			program := append([]vmemu.File(nil), lib...)
+			for _, file := range files {
+				src, err := os.ReadFile(file)
+				assert.NoError(t, err)
+				out, err := pkg.Compile(src, true)
+				assert.NoError(t, err)
+				program = append(program, vmemu.File{Name: strings.TrimSuffix(filepath.Base(file), ".jack"), Src: []byte(out)})
+			}
+			p, err := vmemu.Load(program...)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			timeline := &keyboard.Timeline{}
+			if src, err := os.ReadFile(filepath.Join(dir, "keys.txt")); err == nil {
+				timeline, err = keyboard.Parse("keys.txt", src, 0)
+				assert.NoError(t, err)
+			}
+
+			m := vmemu.New(p)
+			for i := 0; i < 20_000_000 && m.Function() != "Sys.halt"; i++ {
+				timeline.Apply(m.Steps, m.RAM[:])
+				if !assert.NoError(t, m.Step()) {
+					t.FailNow()
+				}
+			}
+			assert.Equal(t, "Sys.halt", m.Function())
+
+			if *update {
+				assert.NoError(t, screen.Save(golden, m.RAM[:]))
+				return
+			}
+			f, err := os.Open(golden)
+			assert.NoError(t, err)
+			defer f.Close()
+			want, err := png.Decode(f)
+			assert.NoError(t, err)
+			assert.Zero(t, screen.Diff(want, screen.Image(m.RAM[:])), "pixels that differ from %v", golden)
+		})
+	}
+}
@@ -1,3 +1,7 @@
 module github.com/bannnn511/nand2tetris
 
-go 1.22.4
+go 1.22.5
+
+require hack v0.0.0-00010101000000-000000000000
+
+replace hack => ../hack
@@ -1,177 +1,84 @@
 package main
 
 import (
+	"bytes"
+	"os"
 	"testing"
 )
 
-var sb = &SymbolTable{}
-
-func TestMain(t *testing.M) {
-	sb = NewSymbolTable()
-	t.Run()
-}
-
-func Test_parse(t *testing.T) {
-	type args struct {
-		instruction string
-	}
+func Test_assemble(t *testing.T) {
 	tests := []struct {
 		name string
-		args args
+		src  string
 		want string
 	}{
 		{
 			name: "1. test_A_instruction_@value",
-			args: args{
-				instruction: "@2",
-			},
-			want: "0000000000000010",
+			src:  "@2",
+			want: "0000000000000010\n",
+		},
+		{
+			name: "2. test_C_instruction_D=A",
+			src:  "D=A",
+			want: "1110110000010000\n",
 		},
 		{
-			name: "2. test_A_instruction_@value",
-			args: args{
-				instruction: "@3",
-			},
-			want: "0000000000000011",
+			name: "3. test_C_instruction_D=D+A",
+			src:  "D=D+A",
+			want: "1110000010010000\n",
 		},
 		{
-			name: "3. test_C_instruction_D=A",
-			args: args{
-				instruction: "D=A",
-			},
-			want: "1110110000010000",
+			name: "4. test_C_instruction_0;JMP",
+			src:  "0;JMP",
+			want: "1110101010000111\n",
 		},
 		{
-			name: "4. test_C_instruction_D=D+A",
-			args: args{
-				instruction: "D=D+A",
-			},
-			want: "1110000010010000",
+			name: "5. test_variables_and_labels",
+			src:  "(LOOP)\n@i\n@j\n@i\n@LOOP",
+			want: "0000000000010000\n0000000000010001\n0000000000010000\n0000000000000000\n",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got, err := parse(sb, tt.args.instruction); got != tt.want {
-				if err != nil {
-					t.Errorf("parse() error = %v", err)
-				}
-				t.Errorf("parse() = %v, want %v", got, tt.want)
+			got, err := assemble([]byte(tt.src))
+			if err != nil {
+				t.Fatalf("assemble() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("assemble() = %q, want %q", got, tt.want)
 			}
 		})
 	}
-}
 
-func Test_parseCInstruction(t *testing.T) {
-	type args struct {
-		instruction string
-	}
-	tests := []struct {
-		name     string
-		args     args
-		wantDest string
-		wantComp string
-		wantJmp  string
-	}{
-		{
-			name:     "1. test_C_instruction_D=A",
-			args:     args{instruction: "D=A"},
-			wantDest: "D",
-			wantComp: "A",
-			wantJmp:  "",
-		},
-		{
-			name:     "2. test_C_instruction_D=D+A",
-			args:     args{instruction: "D=D+A"},
-			wantDest: "D",
-			wantComp: "D+A",
-			wantJmp:  "",
-		},
-		{
-			name:     "3. test_C_instruction_M=D",
-			args:     args{instruction: "M=D"},
-			wantDest: "M",
-			wantComp: "D",
-			wantJmp:  "",
-		},
-		{
-			name:     "3. test_C_instruction_0;JMP",
-			args:     args{instruction: "0;JMP"},
-			wantDest: "",
-			wantComp: "0",
-			wantJmp:  "JMP",
-		},
-		{
-			name:     "4. test_C_instruction_D;JEQ",
-			args:     args{instruction: "D;JEQ"},
-			wantDest: "",
-			wantComp: "D",
-			wantJmp:  "JEQ",
-		},
+	if _, err := assemble([]byte("D=X")); err == nil {
+		t.Errorf("assemble() of an invalid instruction, want an error")
 	}
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			dest, comp, jmp, _ := parseCInstruction(tt.args.instruction)
-			if dest != tt.wantDest ||
-				comp != tt.wantComp ||
-				jmp != tt.wantJmp {
-				t.Errorf(
-					"parseCInstruction() = %v %v %v, want %v %v %v",
-					dest, comp, jmp, tt.wantDest, tt.wantComp, tt.wantJmp,
-				)
+func Test_assembleLabels(t *testing.T) {
+	// the programs with labels assemble to those without
+	for _, name := range []string{"Max", "Pong", "Rect"} {
+		t.Run(name, func(t *testing.T) {
+			got := assembleFile(t, "tests/"+name+".asm")
+			want := assembleFile(t, "tests/"+name+"L.asm")
+			if !bytes.Equal(got, want) {
+				t.Errorf("%v.asm and %vL.asm assemble to different code", name, name)
 			}
 		})
 	}
 }
 
-func Test_code(t *testing.T) {
-	type args struct {
-		dest, comp, jump string
+func assembleFile(t *testing.T, name string) []byte {
+	t.Helper()
+	src, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
 	}
-	tests := []struct {
-		name string
-		args args
-		want string
-	}{
-		{
-			name: "1. test_code_D=A",
-			args: args{
-				dest: "D",
-				comp: "A",
-				jump: "",
-			},
-			want: "1110110000010000",
-		},
-		{
-			name: "2. test_code_D=D+A",
-			args: args{
-				dest: "D",
-				comp: "D+A",
-				jump: "",
-			},
-			want: "1110000010010000",
-		},
-		{
-			name: "3. test_code_M=D",
-			args: args{
-				dest: "M",
-				comp: "D",
-				jump: "",
-			},
-			want: "1110001100001000",
-		},
+	code, err := assemble(src)
+	if err != nil {
+		t.Fatalf("%v: %v", name, err)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := code(tt.args.dest, tt.args.comp, tt.args.jump)
-			if tt.want != got {
-				t.Errorf(
-					"code() = %v, want %v",
-					got, tt.want,
-				)
-			}
-		})
-	}
+	return code
 }